@@ -0,0 +1,8 @@
+// Package cadence embeds the Cadence scripts the server runs, so they're
+// built into the binary instead of read from the working directory.
+package cadence
+
+import "embed"
+
+//go:embed scripts/*.cdc scripts/custom float/scripts
+var Scripts embed.FS
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// Buckets idle for longer than this are dropped on the next sweep.
+const rateLimitIdle = time.Hour
+
+// A budget of requests per window, e.g. "10/1m". Buckets hold up to Requests
+// tokens and refill over Window.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func ParseRateLimit(s string) (RateLimit, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected requests/window", s)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 1 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", s)
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid window in rate limit %q", s)
+	}
+	return RateLimit{Requests: requests, Window: window}, nil
+}
+
+// Checks every route's budget parses.
+func ValidateRateLimits(limits map[string]string) error {
+	for route, budget := range limits {
+		if _, err := ParseRateLimit(budget); err != nil {
+			return fmt.Errorf("%s: %w", route, err)
+		}
+	}
+	return nil
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// In memory token buckets, one per key. Each server instance keeps its own.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: map[string]*bucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+// Takes a token from the key's bucket. When the bucket is empty it returns
+// false and how long until the next token.
+func (rl *RateLimiter) Allow(key string, limit RateLimit) (bool, time.Duration) {
+	return rl.take(key, limit, true)
+}
+
+// Reports whether the key's bucket has a token, without taking it.
+func (rl *RateLimiter) Peek(key string, limit RateLimit) (bool, time.Duration) {
+	return rl.take(key, limit, false)
+}
+
+func (rl *RateLimiter) take(key string, limit RateLimit, spend bool) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		rl.buckets[key] = b
+	}
+
+	rate := float64(limit.Requests) / float64(limit.Window)
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+float64(now.Sub(b.updated))*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	if spend {
+		b.tokens--
+	}
+	return true, 0
+}
+
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rateLimitIdle {
+		return
+	}
+	for key, b := range rl.buckets {
+		if now.Sub(b.updated) > rateLimitIdle {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
+
+type signerLimitKey struct{}
+
+// Checks, and when spend is set charges, a signer's bucket on the route.
+type signerLimit func(addr string, spend bool) bool
+
+// Limits requests to a route per client IP, using the route's budget in
+// c.RateLimits. Handlers also limit the signer with SignerHasBudget before
+// verifying the signature, and charge the signer's bucket with AllowSigner
+// once it's verified. Requests over budget get a Retry-After header and are
+// answered by reject. Turned off with the useRateLimits feature.
+func UseRateLimit(
+	c shared.Config,
+	limiter *RateLimiter,
+	route string,
+	reject func(w http.ResponseWriter),
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !c.Features["useRateLimits"] || r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			budget, ok := c.RateLimits[route]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// budgets are validated at startup, so this is a misconfiguration
+			limit, err := ParseRateLimit(budget)
+			if err != nil {
+				log.Error().Err(err).Msgf("Rate limit for %s is invalid.", route)
+				reject(w)
+				return
+			}
+
+			check := func(key string, spend bool) bool {
+				allowed, retryAfter := limiter.take(key, limit, spend)
+				if !allowed {
+					seconds := int(math.Ceil(retryAfter.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					log.Warn().Msgf("Rate limited %s on %s.", key, route)
+				}
+				return allowed
+			}
+
+			ip := clientIP(r, c.Features["trustForwardedFor"], c.Forwarded_hops)
+			if !check(route+"|ip|"+ip, true) {
+				reject(w)
+				return
+			}
+
+			var limitSigner signerLimit = func(addr string, spend bool) bool {
+				return check(route+"|addr|"+strings.ToLower(addr), spend)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signerLimitKey{}, limitSigner)))
+		})
+	}
+}
+
+// Reports whether the signer has budget left on the route that limited the
+// request, without charging it, setting Retry-After when it doesn't. Call it
+// before verifying the signature, so signers over budget are turned away
+// before the expensive checks. Requests without a rate limit always have budget.
+func SignerHasBudget(r *http.Request, addr string) bool {
+	limitSigner, ok := r.Context().Value(signerLimitKey{}).(signerLimit)
+	if !ok {
+		return true
+	}
+	return limitSigner(addr, false)
+}
+
+// Takes a token from the signer's bucket on the route that limited the
+// request, setting Retry-After when it's empty. Only call it once the
+// signature is verified, so forged addresses can't spend another signer's
+// budget. Requests without a rate limit are always allowed.
+func AllowSigner(r *http.Request, addr string) bool {
+	limitSigner, ok := r.Context().Value(signerLimitKey{}).(signerLimit)
+	if !ok {
+		return true
+	}
+	return limitSigner(addr, true)
+}
+
+// Returns the client's IP. Behind proxies that append to X-Forwarded-For,
+// it's the entry added by the outermost of the trusted hops, since clients
+// can put anything in the entries before it. Without trusted proxies it's
+// the address of the connection.
+func clientIP(r *http.Request, trustForwardedFor bool, hops int) string {
+	if trustForwardedFor && hops > 0 {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(strings.Join(forwarded, ","), ",")
+			i := len(entries) - hops
+			if i < 0 {
+				i = 0
+			}
+			return strings.TrimSpace(entries[i])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	s "github.com/DapperCollectives/CAST/backend/main/shared"
@@ -22,26 +25,48 @@ type Balance struct {
 	Proposal_id             int       `json:"proposal_id"`
 	NFTCount                int       `json:"nftCount"`
 	CreatedAt               time.Time `json:"createdAt"`
+	// Per-contract breakdown of a composite strategy balance
+	Components *[]BalanceComponent `json:"components,omitempty"`
+	// Breakdown of a FLOW balance
+	FlowBalance *s.FlowBalance `json:"flowBalance,omitempty"`
+	// What the balance measures, see BalanceSource. Nil for balances
+	// stored before sources were recorded.
+	Source *string `json:"source,omitempty"`
 }
 
+// The part of a composite balance held in one contract. Balance is the
+// token amount, or the number of NFTs held.
+type BalanceComponent struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Balance    float64 `json:"balance"`
+	Multiplier float64 `json:"multiplier"`
+	Weight     float64 `json:"weight"`
+}
+
+// Gets the balance stored for the address at the block height, only if it
+// was stored for the same source as b.
 func (b *Balance) GetBalanceByAddressAndBlockHeight(db *s.Database) error {
 	sql := `
 	SELECT * from balances as b
 	WHERE b.addr = $1 and b.block_height = $2
+	and b.source IS NOT DISTINCT FROM $3
 	`
-	return pgxscan.Get(db.Context, db.Conn, b, sql, b.Addr, b.BlockHeight)
+	return pgxscan.Get(db.Context, db.Conn, b, sql, b.Addr, b.BlockHeight, b.Source)
 }
 
 func (b *Balance) CreateBalance(db *s.Database) error {
 	sql := `
 	INSERT INTO balances (addr, primary_account_balance, secondary_address,
-	    secondary_account_balance, staking_balance, script_result, stakes, block_height, id)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	    secondary_account_balance, staking_balance, script_result, stakes, block_height, id, components, flow_balance,
+	    source)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
 	_, err := db.Conn.Exec(db.Context, sql,
 		b.Addr, b.PrimaryAccountBalance, b.SecondaryAddress, b.SecondaryAccountBalance,
-		b.StakingBalance, b.ScriptResult, b.Stakes, b.BlockHeight, uuid.New(),
+		b.StakingBalance, b.ScriptResult, b.Stakes, b.BlockHeight, uuid.New(), b.Components, b.FlowBalance,
+		b.Source,
 	)
 
 	if err != nil {
@@ -51,3 +76,19 @@ func (b *Balance) CreateBalance(db *s.Database) error {
 
 	return nil
 }
+
+// Identifies what a balance measures: the strategy, and the contract settings
+// that change what it fetches. Balances at the same block height are only
+// shared by proposals with the same source, so a composite sum or a staked
+// FLOW balance is never read as another strategy's token balance.
+func BalanceSource(strategy string, contract s.Contract) string {
+	// thresholds and flags don't change the balance that is fetched
+	contract.Threshold = nil
+	contract.MaxWeight = nil
+	contract.Prefetch_balances = nil
+	contract.Latest_block_fallback = nil
+
+	encoded, _ := json.Marshal(contract)
+	sum := sha256.Sum256(append([]byte(strategy+":"), encoded...))
+	return hex.EncodeToString(sum[:])
+}
@@ -36,6 +36,9 @@ type Community struct {
 	Proposal_threshold       *string     `json:"proposalThreshold,omitempty"`
 	Slug                     *string     `json:"slug,omitempty"                  validate:"required"`
 	Is_featured              *bool       `json:"isFeatured,omitempty"`
+	Quorum                   *float64    `json:"quorum,omitempty"`
+	Quorum_type              *string     `json:"quorumType,omitempty"`
+	Pass_threshold           *float64    `json:"passThreshold,omitempty"`
 
 	Total *int `json:"total,omitempty"` // for search only
 
@@ -45,6 +48,7 @@ type Community struct {
 	Public_path   *string `json:"publicPath,omitempty"`
 
 	Timestamp            string                  `json:"timestamp"             validate:"required"`
+	Nonce                string                  `json:"nonce,omitempty"`
 	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures"`
 	Creator_addr         string                  `json:"creatorAddr"           validate:"required"`
 	Signing_addr         *string                 `json:"signingAddr,omitempty"`
@@ -77,6 +81,9 @@ type UpdateCommunityRequestPayload struct {
 	Proposal_validation      *string         `json:"proposalValidation,omitempty"`
 	Proposal_threshold       *string         `json:"proposalThreshold,omitempty"`
 	Only_authors_to_submit   *bool           `json:"onlyAuthorsToSubmit,omitempty"`
+	Quorum                   *float64        `json:"quorum,omitempty"`
+	Quorum_type              *string         `json:"quorumType,omitempty"`
+	Pass_threshold           *float64        `json:"passThreshold,omitempty"`
 	Voucher                  *shared.Voucher `json:"voucher,omitempty"`
 
 	//TODO dup fields in Community struct, make sub struct for both to use
@@ -149,10 +156,14 @@ const INSERT_COMMUNITY_SQL = `
 		contract_type, 
 		public_path, 
 		only_authors_to_submit, 
-		voucher)
+		voucher,
+		quorum,
+		quorum_type,
+		pass_threshold)
 	VALUES(
 		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, 
-		$14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24
+		$14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24,
+		$25, $26, $27
 	)
 	RETURNING id, created_at
 `
@@ -177,8 +188,11 @@ const UPDATE_COMMUNITY_SQL = `
 	contract_addr = COALESCE($17, contract_addr),
 	contract_type = COALESCE($18, contract_type),
 	public_path = COALESCE($19, public_path),
-	only_authors_to_submit = COALESCE($20, only_authors_to_submit)
-	WHERE id = $21
+	only_authors_to_submit = COALESCE($20, only_authors_to_submit),
+	quorum = COALESCE($21, quorum),
+	quorum_type = COALESCE($22, quorum_type),
+	pass_threshold = COALESCE($23, pass_threshold)
+	WHERE id = $24
 `
 const SEARCH_COMMUNITIES_SQL = `
 	SELECT id, name, body, logo, category, SIMILARITY(name, $1) as score	
@@ -285,18 +299,13 @@ func GetDefaultCommunities(
 		db.Conn.QueryRow(db.Context, countSql).Scan(&totalRecords)
 		return communities, totalRecords, nil
 	} else {
-		sql, err := addFiltersToSql(DEFAULT_SEARCH_SQL, "", filters)
-		if err != nil {
-			return nil, 0, err
-		}
-		
+		sql, args := addFiltersToSql(DEFAULT_SEARCH_SQL, filters, 3)
 		sql = sql + " LIMIT $1 OFFSET $2"
-		
+
 		rows, err := db.Conn.Query(
 			db.Context,
 			sql,
-			params.Count,
-			params.Start,
+			append([]interface{}{params.Count, params.Start}, args...)...,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -312,14 +321,10 @@ func GetDefaultCommunities(
 		//@TODO: Repeating logic here, refactor if checks into a function
 		//if filters are present generate a new sql query to get the total records
 		if filters[0] != "" {
-			countSql, err := generateDefaultFilterCountSql(filters)
-			if err != nil {
-				return nil, 0, err
-			}
+			countSql, args := generateDefaultFilterCountSql(filters)
 
-			fmt.Printf("count sql: %s \n", countSql)
 			var totalRecords int
-			db.Conn.QueryRow(db.Context, countSql).Scan(&totalRecords)
+			db.Conn.QueryRow(db.Context, countSql, args...).Scan(&totalRecords)
 
 			return communities, totalRecords, nil
 		} else {
@@ -361,7 +366,10 @@ func (c *Community) CreateCommunity(db *s.Database) error {
 		c.Contract_type,
 		c.Public_path,
 		c.Only_authors_to_submit,
-		c.Voucher).
+		c.Voucher,
+		c.Quorum,
+		c.Quorum_type,
+		c.Pass_threshold).
 		Scan(&c.ID, &c.Created_at)
 	return err
 }
@@ -390,21 +398,15 @@ func (c *Community) UpdateCommunity(db *s.Database, p *UpdateCommunityRequestPay
 		p.Contract_type,
 		p.Public_path,
 		p.Only_authors_to_submit,
+		p.Quorum,
+		p.Quorum_type,
+		p.Pass_threshold,
 		c.ID,
 	)
 
 	return err
 }
 
-func (c *Community) CanUpdateCommunity(db *s.Database, addr string) error {
-	// Check if address has admin role
-	admin := CommunityUser{Addr: addr, Community_id: c.ID, User_type: "admin"}
-	if err := admin.GetCommunityUser(db); err != nil {
-		return fmt.Errorf("address %s does not have permission to update community with ID %d", addr, c.ID)
-	}
-	return nil
-}
-
 func (c *Community) GetStrategy(name string) (Strategy, error) {
 	for _, s := range *c.Strategies {
 		if *s.Name == name {
@@ -421,19 +423,13 @@ func SearchForCommunity(
 	params shared.PageParams,
 ) ([]*Community, int, error) {
 
-	sql, err := addFiltersToSql(SEARCH_COMMUNITIES_SQL, query, filters)
-	if err != nil {
-		return nil, 0, err
-	}
-
+	sql, args := addFiltersToSql(SEARCH_COMMUNITIES_SQL, filters, 4)
 	sql = sql + " ORDER BY score DESC LIMIT $2 OFFSET $3"
 
 	rows, err := db.Conn.Query(
 		db.Context,
 		sql,
-		query,
-		params.Count,
-		params.Start,
+		append([]interface{}{query, params.Count, params.Start}, args...)...,
 	)
 
 	if err != nil {
@@ -450,12 +446,9 @@ func SearchForCommunity(
 	//@TODO: Repeating logic here, refactor if checks into a function
 	//if filters are present generate a new sql query to get the total records
 	if filters[0] != "" {
-		countSql, err := generateSearchFilterCountSql(filters)
-		if err != nil {
-			return nil, 0, err
-		}
+		countSql, args := generateSearchFilterCountSql(filters)
 		var totalRecords int
-		db.Conn.QueryRow(db.Context, countSql, query).Scan(&totalRecords)
+		db.Conn.QueryRow(db.Context, countSql, append([]interface{}{query}, args...)...).Scan(&totalRecords)
 
 		return communities, totalRecords, nil
 	} else {
@@ -503,47 +496,20 @@ func MatchStrategyByProposal(s []Strategy, strategyToMatch string) (Strategy, er
 }
 
 /// Generate SQL Functions////
-func generateSearchFilterCountSql(filters []string) (string, error) {
-	if len(filters) > 0 {
-		var sql string = `
-				SELECT COUNT(*) FROM communities
-        WHERE SIMILARITY(name, $1) > 0.1
-        AND category IS NOT NULL
-				AND category IN (`
-		for i, filter := range filters {
-			if i == len(filters)-1 {
-				sql += fmt.Sprintf("'%s')", filter)
-			} else {
-				sql += fmt.Sprintf("'%s',", filter)
-			}
-		}
-
-		return sql, nil
-	} else {
-		return "", fmt.Errorf("No filters provided")
-	}
+func generateSearchFilterCountSql(filters []string) (string, []interface{}) {
+	return addFiltersToSql(`
+		SELECT COUNT(*) FROM communities
+		WHERE SIMILARITY(name, $1) > 0.1
+		AND category IS NOT NULL`, filters, 2)
 }
 
-func generateDefaultFilterCountSql(filters []string) (string, error) {
-	if len(filters) > 0 {
-		var sql string = `
-				SELECT COUNT(*) FROM communities
-        WHERE category IS NOT NULL
-				AND is_featured = true
-				AND category IN (`
-		for i, filter := range filters {
-			if i == len(filters)-1 {
-				sql += fmt.Sprintf("'%s')", filter)
-			} else {
-				sql += fmt.Sprintf("'%s',", filter)
-			}
-		}
-
-		return sql, nil
-	} else {
-		return "", fmt.Errorf("No filters provided")
-	}
+func generateDefaultFilterCountSql(filters []string) (string, []interface{}) {
+	return addFiltersToSql(`
+		SELECT COUNT(*) FROM communities
+		WHERE category IS NOT NULL
+		AND is_featured = true`, filters, 1)
 }
+
 func GetCategoryCount(db *s.Database, search string) (map[string]int, error) {
 	var rows pgx.Rows
 	var err error
@@ -587,21 +553,9 @@ func GetCategoryCount(db *s.Database, search string) (map[string]int, error) {
 	return categoryCount, nil
 }
 
-func addFiltersToSql(query, search string, filters []string) (string, error) {
-	var sql string
-	if filters[0] != "" {
-		sql = query + " AND ("
-		for i, filter := range filters {
-			if i == 0 {
-				sql += fmt.Sprintf("category = '%s'", filter)
-			} else {
-				sql += fmt.Sprintf(" OR category = '%s'", filter)
-			}
-		}
-		sql += ")"
-	} else {
-		sql = query
-	}
-
-	return sql, nil
+// Filters the query by category. The categories are bound as parameter n,
+// so pass the returned arguments after the query's own.
+func addFiltersToSql(query string, filters []string, n int) (string, []interface{}) {
+	filter, args := categoryFilter(filters, n)
+	return query + filter, args
 }
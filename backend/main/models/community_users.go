@@ -38,8 +38,19 @@ type CommunityUserPayload struct {
 	CommunityUser
 	Signing_addr         string                  `json:"signingAddr"`
 	Timestamp            string                  `json:"timestamp"`
+	Nonce                string                  `json:"nonce,omitempty"`
 	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures"`
 	Voucher              *s.Voucher              `json:"voucher"`
+	Session_addr         string                  `json:"-"`
+}
+
+// Makes the session's address the signer, if there is a session.
+func (p *CommunityUserPayload) UseSession(addr string) {
+	if addr == "" {
+		return
+	}
+	p.Signing_addr = addr
+	p.Session_addr = addr
 }
 
 type UserAchievements = []struct {
@@ -217,21 +228,15 @@ func (u *CommunityUser) Remove(db *s.Database) error {
 }
 
 func GrantAdminRolesToAddress(db *s.Database, communityId int, addr string) error {
-	userTypes := UserTypes{"admin", "author", "member"}
-	for _, role := range userTypes {
-		userRole := CommunityUser{Addr: addr, Community_id: communityId, User_type: role}
-		if err := userRole.GetCommunityUser(db); err != nil {
-			if err := userRole.CreateCommunityUser(db); err != nil {
-				log.Error().Err(err).Msgf("Database error creating role %s for Address: %s and Communuity Id: %d.", role, addr, communityId)
-				return err
-			}
-		}
-	}
-	return nil
+	return GrantRolesToAddress(db, communityId, addr, UserTypes{"admin", "author", "member"})
 }
 
 func GrantAuthorRolesToAddress(db *s.Database, communityId int, addr string) error {
-	userTypes := UserTypes{"author", "member"}
+	return GrantRolesToAddress(db, communityId, addr, UserTypes{"author", "member"})
+}
+
+// Grants each role the address doesn't have yet.
+func GrantRolesToAddress(db *s.Database, communityId int, addr string, userTypes UserTypes) error {
 	for _, role := range userTypes {
 		userRole := CommunityUser{Addr: addr, Community_id: communityId, User_type: role}
 		if err := userRole.GetCommunityUser(db); err != nil {
@@ -272,12 +277,7 @@ func EnsureRoleForCommunity(db *s.Database, addr string, communityId int, userTy
 }
 
 func EnsureValidRole(userType string) bool {
-	for _, t := range USER_TYPES {
-		if t == userType {
-			return true
-		}
-	}
-	return false
+	return IsBuiltInRole(userType)
 }
 
 func getUserAchievements(db *s.Database, communityId int) (UserAchievements, error) {
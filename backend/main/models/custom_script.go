@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	s "github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// A Cadence script uploaded by a community admin for the custom-script
+// strategy. Src holds the script source, unlike the scripts in scripts.json
+// whose Src is a file name.
+type CustomScript struct {
+	ID           int        `json:"id"`
+	Community_id int        `json:"communityId"`
+	Key          string     `json:"key"          validate:"required"`
+	Name         string     `json:"name"         validate:"required"`
+	Description  *string    `json:"description,omitempty"`
+	Src          string     `json:"src"          validate:"required"`
+	Cid          *string    `json:"cid,omitempty"`
+	Creator_addr string     `json:"creatorAddr"`
+	Created_at   *time.Time `json:"createdAt,omitempty"`
+}
+
+type CustomScriptPayload struct {
+	CustomScript
+	// Contract whose placeholders are filled in for the dry run
+	Contract *s.Contract `json:"contract" validate:"required"`
+	// Address the script is dry run against, defaults to the signer
+	Sample_addr *string `json:"sampleAddr,omitempty"`
+	s.TimestampSignaturePayload
+}
+
+func GetCustomScriptsForCommunity(db *s.Database, communityId int) ([]CustomScript, error) {
+	scripts := []CustomScript{}
+	err := pgxscan.Select(db.Context, db.Conn, &scripts,
+		`SELECT * FROM custom_scripts WHERE community_id = $1 ORDER BY created_at DESC`,
+		communityId)
+
+	return scripts, err
+}
+
+func (cs *CustomScript) GetCustomScriptByKey(db *s.Database) error {
+	return pgxscan.Get(db.Context, db.Conn, cs, `
+		SELECT * FROM custom_scripts WHERE community_id = $1 AND key = $2
+	`, cs.Community_id, cs.Key)
+}
+
+func (cs *CustomScript) CreateCustomScript(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context,
+		`
+		INSERT INTO custom_scripts(community_id, key, name, description, src, cid, creator_addr)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, cs.Community_id, cs.Key, cs.Name, cs.Description, cs.Src, cs.Cid, cs.Creator_addr).Scan(&cs.ID, &cs.Created_at)
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	s "github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+)
+
+type Delegation struct {
+	ID                   int                     `json:"id,omitempty"`
+	Community_id         int                     `json:"communityId"`
+	Delegator_addr       string                  `json:"delegatorAddr" validate:"required"`
+	Delegate_addr        string                  `json:"delegateAddr"  validate:"required"`
+	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures"`
+	Voucher              *shared.Voucher         `json:"voucher,omitempty"`
+	Created_at           time.Time               `json:"createdAt"`
+	Revoked_at           *time.Time              `json:"revokedAt,omitempty"`
+}
+
+type DelegationPayload struct {
+	Delegation
+	Timestamp string `json:"timestamp"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+func (d *Delegation) CreateDelegation(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context,
+		`
+	INSERT INTO delegations(community_id, delegator_addr, delegate_addr, composite_signatures, voucher)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`,
+		d.Community_id,
+		d.Delegator_addr,
+		d.Delegate_addr,
+		d.Composite_signatures,
+		d.Voucher,
+	).Scan(&d.ID, &d.Created_at)
+}
+
+// Gets the delegator's unrevoked delegation in the community.
+func (d *Delegation) GetActiveDelegation(db *s.Database) error {
+	return pgxscan.Get(db.Context, db.Conn, d,
+		`
+		SELECT * FROM delegations
+		WHERE community_id = $1 AND delegator_addr = $2 AND revoked_at IS NULL
+		`, d.Community_id, d.Delegator_addr)
+}
+
+func (d *Delegation) RevokeDelegation(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context,
+		`
+		UPDATE delegations
+		SET revoked_at = (now() at time zone 'utc')
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING revoked_at
+		`, d.ID).Scan(&d.Revoked_at)
+}
+
+func GetDelegationsForCommunity(
+	db *s.Database,
+	communityId int,
+	pageParams shared.PageParams,
+) ([]*Delegation, int, error) {
+	var delegations = []*Delegation{}
+	err := pgxscan.Select(db.Context, db.Conn, &delegations,
+		`
+		SELECT * FROM delegations
+		WHERE community_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+		`, communityId, pageParams.Count, pageParams.Start)
+
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, 0, err
+	} else if err != nil && err.Error() == pgx.ErrNoRows.Error() {
+		return []*Delegation{}, 0, nil
+	}
+
+	var totalRecords int
+	countSql := `SELECT COUNT(*) FROM delegations WHERE community_id = $1 AND revoked_at IS NULL`
+	_ = db.Conn.QueryRow(db.Context, countSql, communityId).Scan(&totalRecords)
+
+	return delegations, totalRecords, nil
+}
+
+// Returns a copy of the delegate's vote for each delegator who delegated to a
+// voter on the proposal but did not vote themselves. Each copy carries the
+// delegator's address in Delegator_addr and the delegator's snapshotted
+// balance, which is nil if the balance has not been fetched yet.
+// Delegations count if they were in effect when the proposal ended, or now
+// if it is still open. Delegations aren't transitive.
+func GetDelegatedVotesForProposal(db *s.Database, proposalId int) ([]*VoteWithBalance, error) {
+	var votes []*VoteWithBalance
+
+	sql := `select v.*,
+		d.delegator_addr,
+		b.primary_account_balance,
+		b.secondary_account_balance,
+		b.staking_balance,
+		b.components,
+		b.flow_balance,
+		COALESCE(p.block_height, 0) as block_height
+	from delegations d
+	join proposals p on p.id = $1
+	join votes v on v.proposal_id = p.id and v.addr = d.delegate_addr
+	left join balances b on b.addr = d.delegator_addr
+		and p.block_height = b.block_height
+		and b.source IS NOT DISTINCT FROM p.balance_source
+	where d.community_id = p.community_id
+	and d.created_at <= LEAST(p.end_time, (now() at time zone 'utc'))
+	and (d.revoked_at IS NULL OR d.revoked_at > LEAST(p.end_time, (now() at time zone 'utc')))
+	and NOT EXISTS (
+		select 1 from votes dv where dv.proposal_id = p.id and dv.addr = d.delegator_addr
+	)
+	`
+	err := pgxscan.Select(db.Context, db.Conn, &votes, sql, proposalId)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	} else if err != nil && err.Error() == pgx.ErrNoRows.Error() {
+		return []*VoteWithBalance{}, nil
+	}
+
+	return votes, nil
+}
+
+// Gets the delegators whose delegation counts on the proposal, as in
+// GetDelegatedVotesForProposal, but whose balance at the proposal's snapshot
+// hasn't been stored yet. Delegators who voted have their own balance.
+func GetDelegatorsWithoutBalances(db *s.Database, proposalId int) ([]string, error) {
+	var addrs []string
+
+	sql := `select d.delegator_addr
+	from delegations d
+	join proposals p on p.id = $1
+	where d.community_id = p.community_id
+	and d.created_at <= LEAST(p.end_time, (now() at time zone 'utc'))
+	and (d.revoked_at IS NULL OR d.revoked_at > LEAST(p.end_time, (now() at time zone 'utc')))
+	and NOT EXISTS (
+		select 1 from votes dv where dv.proposal_id = p.id and dv.addr = d.delegator_addr
+	)
+	and NOT EXISTS (
+		select 1 from balances b where b.addr = d.delegator_addr and b.block_height = p.block_height
+		and b.source IS NOT DISTINCT FROM p.balance_source
+	)
+	`
+	err := pgxscan.Select(db.Context, db.Conn, &addrs, sql, proposalId)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	return addrs, nil
+}
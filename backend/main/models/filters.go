@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Builders for the filters list endpoints accept. Request input only picks
+// SQL from the whitelists here or is bound as a parameter, it's never
+// formatted into a query.
+
+// Conditions for each proposal status a request can filter by.
+var proposalStatusFilters = map[string]string{
+	"pending":    ` AND status = 'published' AND start_time > (now() at time zone 'utc')`,
+	"active":     ` AND status = 'published' AND start_time < (now() at time zone 'utc') AND end_time > (now() at time zone 'utc')`,
+	"closed":     ` AND status = 'published' AND end_time < (now() at time zone 'utc')`,
+	"cancelled":  ` AND status = 'cancelled'`,
+	"terminated": ` AND (status = 'cancelled' OR (status = 'published' AND end_time < (now() at time zone 'utc')))`,
+	"inprogress": ` AND status = 'published' AND end_time > (now() at time zone 'utc')`,
+}
+
+// Returns the condition for the proposal status, or "" for unknown statuses.
+func proposalStatusFilter(status string) string {
+	return proposalStatusFilters[status]
+}
+
+// Returns ASC for "asc" and DESC for anything else.
+func orderDirection(order string) string {
+	if strings.ToLower(order) == "asc" {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// Returns the categories to filter by, leaving out empty ones.
+func categoryFilters(filters []string) []string {
+	categories := []string{}
+	for _, filter := range filters {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			categories = append(categories, filter)
+		}
+	}
+	return categories
+}
+
+// Returns a condition matching any of the categories, which are bound as
+// parameter n, and the arguments to add for it.
+func categoryFilter(filters []string, n int) (string, []interface{}) {
+	categories := categoryFilters(filters)
+	if len(categories) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf(" AND category = ANY($%d)", n), []interface{}{categories}
+}
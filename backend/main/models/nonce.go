@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	s "github.com/DapperCollectives/CAST/backend/main/shared"
+)
+
+// Actions a signed request can authorize. A nonce is issued for one action
+// on one resource and is used up by the request it authorizes.
+const (
+	ActionSignIn           = "session.sign-in"
+	ActionCommunityCreate  = "community.create"
+	ActionCommunityUpdate  = "community.update"
+	ActionProposalCreate   = "proposal.create"
+	ActionProposalCancel   = "proposal.cancel"
+	ActionListCreate       = "list.create"
+	ActionListEdit         = "list.edit"
+	ActionRoleGrant        = "role.grant"
+	ActionRoleRemove       = "role.remove"
+	ActionRoleManage       = "role.manage"
+	ActionDelegationCreate = "delegation.create"
+	ActionDelegationRevoke = "delegation.revoke"
+	ActionScriptCreate     = "script.create"
+)
+
+var NonceActions = []string{
+	ActionSignIn,
+	ActionCommunityCreate,
+	ActionCommunityUpdate,
+	ActionProposalCreate,
+	ActionProposalCancel,
+	ActionListCreate,
+	ActionListEdit,
+	ActionRoleGrant,
+	ActionRoleRemove,
+	ActionRoleManage,
+	ActionDelegationCreate,
+	ActionDelegationRevoke,
+	ActionScriptCreate,
+}
+
+var ErrInvalidNonce = errors.New("Nonce is invalid, expired or already used.")
+
+type Nonce struct {
+	Nonce      string     `json:"nonce"`
+	Action     string     `json:"action"   validate:"required"`
+	Resource   string     `json:"resource" validate:"required"`
+	Expires_at time.Time  `json:"expiresAt"`
+	Used_at    *time.Time `json:"usedAt,omitempty"`
+	Created_at *time.Time `json:"createdAt,omitempty"`
+}
+
+func (n *Nonce) CreateNonce(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context, `
+		INSERT INTO nonces(nonce, action, resource, expires_at)
+		VALUES($1, $2, $3, $4)
+		RETURNING created_at
+	`, n.Nonce, n.Action, n.Resource, n.Expires_at).Scan(&n.Created_at)
+}
+
+// Marks the nonce used. Fails with ErrInvalidNonce unless the nonce was
+// issued for the action and resource, and is unused and unexpired.
+func ConsumeNonce(db *s.Database, nonce, action, resource string) error {
+	cmd, err := db.Conn.Exec(db.Context, `
+		UPDATE nonces
+		SET used_at = (now() at time zone 'utc')
+		WHERE nonce = $1 AND action = $2 AND resource = $3
+		AND used_at IS NULL AND expires_at > (now() at time zone 'utc')
+	`, nonce, action, resource)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrInvalidNonce
+	}
+	return nil
+}
+
+func DeleteExpiredNonces(db *s.Database) error {
+	_, err := db.Conn.Exec(db.Context,
+		`DELETE FROM nonces WHERE expires_at < (now() at time zone 'utc')`)
+	return err
+}
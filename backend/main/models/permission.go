@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	s "github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/thoas/go-funk"
+)
+
+const ModeratorRole = "moderator"
+
+var builtInRoles = UserTypes{"member", "author", ModeratorRole, "admin"}
+
+// Actions each built in role allows. Roles defined by a community's admins
+// are kept in community_roles.
+var RolePermissions = map[string][]string{
+	"member":      {},
+	"author":      {ActionProposalCreate, ActionProposalCancel},
+	ModeratorRole: {ActionProposalCancel, ActionListEdit},
+	"admin": {
+		ActionCommunityUpdate,
+		ActionProposalCancel,
+		ActionListCreate,
+		ActionListEdit,
+		ActionRoleGrant,
+		ActionRoleRemove,
+		ActionRoleManage,
+		ActionScriptCreate,
+	},
+}
+
+// Actions a custom role can allow. Granting and managing roles is left to
+// admins, so a custom role can't be used to escalate itself.
+var CustomRolePermissions = []string{
+	ActionCommunityUpdate,
+	ActionProposalCreate,
+	ActionProposalCancel,
+	ActionListCreate,
+	ActionListEdit,
+	ActionScriptCreate,
+}
+
+type CommunityRole struct {
+	Community_id int        `json:"communityId"`
+	Name         string     `json:"name" validate:"required,alpha,max=32"`
+	Permissions  []string   `json:"permissions" validate:"required,dive,required"`
+	Built_in     bool       `json:"builtIn"`
+	Created_at   *time.Time `json:"createdAt,omitempty"`
+}
+
+type CommunityRolePayload struct {
+	CommunityRole
+	s.TimestampSignaturePayload
+}
+
+func IsBuiltInRole(name string) bool {
+	_, ok := RolePermissions[name]
+	return ok
+}
+
+// Returns the built in roles followed by the community's custom roles.
+func GetRolesForCommunity(db *s.Database, communityId int) ([]CommunityRole, error) {
+	var roles = []CommunityRole{}
+	for _, name := range builtInRoles {
+		roles = append(roles, CommunityRole{
+			Community_id: communityId,
+			Name:         name,
+			Permissions:  RolePermissions[name],
+			Built_in:     true,
+		})
+	}
+
+	var custom = []CommunityRole{}
+	err := pgxscan.Select(db.Context, db.Conn, &custom,
+		`SELECT * FROM community_roles WHERE community_id = $1 ORDER BY name`,
+		communityId)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	return append(roles, custom...), nil
+}
+
+func (r *CommunityRole) GetCommunityRole(db *s.Database) error {
+	return pgxscan.Get(db.Context, db.Conn, r,
+		`SELECT * FROM community_roles WHERE community_id = $1 AND name = $2`,
+		r.Community_id, r.Name)
+}
+
+func (r *CommunityRole) CreateCommunityRole(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context, `
+		INSERT INTO community_roles(community_id, name, permissions)
+		VALUES($1, $2, $3)
+		RETURNING created_at
+	`, r.Community_id, r.Name, r.Permissions).Scan(&r.Created_at)
+}
+
+func (r *CommunityRole) UpdateCommunityRole(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context, `
+		UPDATE community_roles SET permissions = $1
+		WHERE community_id = $2 AND name = $3
+		RETURNING created_at
+	`, r.Permissions, r.Community_id, r.Name).Scan(&r.Created_at)
+}
+
+// Removes the role and takes it away from everyone who has it.
+func (r *CommunityRole) Remove(db *s.Database) error {
+	tx, err := db.Conn.Begin(db.Context)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Context)
+
+	if _, err := tx.Exec(db.Context,
+		`DELETE FROM community_users WHERE community_id = $1 AND user_type = $2`,
+		r.Community_id, r.Name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(db.Context,
+		`DELETE FROM community_roles WHERE community_id = $1 AND name = $2`,
+		r.Community_id, r.Name); err != nil {
+		return err
+	}
+
+	return tx.Commit(db.Context)
+}
+
+// Returns true if the role is built in or defined by the community.
+func IsRoleForCommunity(db *s.Database, communityId int, name string) bool {
+	if IsBuiltInRole(name) {
+		return true
+	}
+	role := CommunityRole{Community_id: communityId, Name: name}
+	return role.GetCommunityRole(db) == nil
+}
+
+// Returns an error unless one of the address's roles in the community allows
+// the action.
+func EnsurePermissionForCommunity(db *s.Database, addr string, communityId int, action string) error {
+	roles, err := GetAllRolesForUserInCommunity(db, addr, communityId)
+	if err != nil {
+		return err
+	}
+
+	var custom []string
+	for _, role := range roles {
+		permissions, ok := RolePermissions[role.User_type]
+		if !ok {
+			custom = append(custom, role.User_type)
+		} else if funk.ContainsString(permissions, action) {
+			return nil
+		}
+	}
+
+	if len(custom) > 0 {
+		var allowed bool
+		err := db.Conn.QueryRow(db.Context, `
+			SELECT EXISTS (
+				SELECT 1 FROM community_roles
+				WHERE community_id = $1 AND name = ANY($2) AND $3 = ANY(permissions)
+			)
+		`, communityId, custom, action).Scan(&allowed)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("address %s does not have permission to %s in community %d", addr, action, communityId)
+}
@@ -5,10 +5,9 @@ package models
 ///////////////
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/DapperCollectives/CAST/backend/main/shared"
@@ -36,11 +35,26 @@ type Proposal struct {
 	Block_height         *uint64                 `json:"block_height"`
 	Total_votes          int                     `json:"total_votes"`
 	Timestamp            string                  `json:"timestamp" validate:"required"`
+	Nonce                string                  `json:"nonce,omitempty"`
 	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures"`
 	Computed_status      *string                 `json:"computedStatus,omitempty"`
 	Snapshot_status      *string                 `json:"snapshotStatus,omitempty"`
 	Voucher              *shared.Voucher         `json:"voucher,omitempty"`
 	Achievements_done    bool                    `json:"achievementsDone"`
+	Voting_type          *string                 `json:"votingType,omitempty"`
+	Quorum               *float64                `json:"quorum,omitempty"`
+	Quorum_type          *string                 `json:"quorumType,omitempty"`
+	Pass_threshold       *float64                `json:"passThreshold,omitempty"`
+	Total_supply         *float64                `json:"totalSupply,omitempty"`
+	Lifecycle_status     string                  `json:"lifecycleStatus"`
+	Decimals             *int                    `json:"decimals,omitempty"`
+	Balance_buckets      *[]string               `json:"balanceBuckets,omitempty"`
+	// Source of the balances votes are weighed with, see BalanceSource
+	Balance_source *string `json:"balanceSource,omitempty"`
+	// Progress of prefetching the allowlist's balances, nil when the
+	// balances are fetched as votes come in
+	Snapshot_total   *int `json:"snapshotTotal,omitempty"`
+	Snapshot_fetched *int `json:"snapshotFetched,omitempty"`
 }
 
 type UpdateProposalRequestPayload struct {
@@ -50,6 +64,30 @@ type UpdateProposalRequestPayload struct {
 	s.TimestampSignaturePayload
 }
 
+const (
+	SingleChoice string = "single-choice"
+	RankedChoice        = "ranked-choice"
+	Approval            = "approval"
+	Split               = "split"
+)
+
+const (
+	QuorumAbsolute   string = "absolute"
+	QuorumPercentage        = "percentage"
+)
+
+// Lifecycle statuses are the last computed status the scheduler
+// ran the transition hooks for.
+const (
+	LifecyclePending string = "pending"
+	LifecycleActive         = "active"
+	LifecycleClosed         = "closed"
+)
+
+type VotingTypes []string
+
+var VOTING_TYPES = VotingTypes{SingleChoice, RankedChoice, Approval, Split}
+
 var computedStatusSQL = `
 	CASE
 		WHEN status = 'published' AND start_time > (now() at time zone 'utc') THEN 'pending'
@@ -71,26 +109,10 @@ func GetProposalsForCommunity(
 
 	// Get Proposals
 	sql := fmt.Sprintf(`SELECT *, %s FROM proposals WHERE community_id = $3`, computedStatusSQL)
-	statusFilter := ""
-
-	// Generate SQL based on computed status
-	// status: { pending | active | closed | cancelled }
-	switch status {
-	case "pending":
-		statusFilter = ` AND status = 'published' AND start_time > (now() at time zone 'utc')`
-	case "active":
-		statusFilter = ` AND status = 'published' AND start_time < (now() at time zone 'utc') AND end_time > (now() at time zone 'utc')`
-	case "closed":
-		statusFilter = ` AND status = 'published' AND end_time < (now() at time zone 'utc')`
-	case "cancelled":
-		statusFilter = ` AND status = 'cancelled'`
-	case "terminated":
-		statusFilter = ` AND (status = 'cancelled' OR (status = 'published' AND end_time < (now() at time zone 'utc')))`
-	case "inprogress":
-		statusFilter = ` AND status = 'published' AND end_time > (now() at time zone 'utc')`
-	}
+	// status: { pending | active | closed | cancelled | terminated | inprogress }
+	statusFilter := proposalStatusFilter(status)
 
-	orderBySql := fmt.Sprintf(` ORDER BY created_at %s`, params.Order)
+	orderBySql := ` ORDER BY created_at ` + orderDirection(params.Order)
 	limitOffsetSql := ` LIMIT $1 OFFSET $2`
 	sql = sql + statusFilter + orderBySql + limitOffsetSql
 
@@ -139,9 +161,17 @@ func (p *Proposal) CreateProposal(db *s.Database) error {
 	block_height, 
 	cid, 
 	composite_signatures,
-	voucher
+	voucher,
+	voting_type,
+	quorum,
+	quorum_type,
+	pass_threshold,
+	total_supply,
+	decimals,
+	balance_buckets,
+	balance_source
 	)
-	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 	RETURNING id, created_at
 	`,
 		p.Community_id,
@@ -159,6 +189,14 @@ func (p *Proposal) CreateProposal(db *s.Database) error {
 		p.Cid,
 		p.Composite_signatures,
 		p.Voucher,
+		p.Voting_type,
+		p.Quorum,
+		p.Quorum_type,
+		p.Pass_threshold,
+		p.Total_supply,
+		p.Decimals,
+		p.Balance_buckets,
+		p.Balance_source,
 	).Scan(&p.ID, &p.Created_at)
 
 	return err
@@ -186,11 +224,178 @@ func (p *Proposal) UpdateProposal(db *s.Database) error {
 	return err
 }
 
+// Returns up to limit published proposals whose start or end time has passed
+// since the scheduler last persisted their lifecycle status, those that ended
+// first first.
+func GetProposalsDueForTransition(db *s.Database, limit int) ([]*Proposal, error) {
+	var proposals []*Proposal
+
+	sql := fmt.Sprintf(`
+		SELECT *, %s FROM proposals
+		WHERE status = 'published'
+		AND (
+			(lifecycle_status = 'pending' AND start_time < (now() at time zone 'utc')) OR
+			(lifecycle_status != 'closed' AND end_time < (now() at time zone 'utc'))
+		)
+		ORDER BY end_time ASC
+		LIMIT $1
+		`, computedStatusSQL)
+
+	err := pgxscan.Select(db.Context, db.Conn, &proposals, sql, limit)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	return proposals, nil
+}
+
+// Gets the community's published proposals that haven't ended.
+func GetOpenProposalsForCommunity(db *s.Database, communityId int) ([]*Proposal, error) {
+	var proposals []*Proposal
+
+	sql := fmt.Sprintf(`
+		SELECT *, %s FROM proposals
+		WHERE community_id = $1
+		AND status = 'published'
+		AND end_time > (now() at time zone 'utc')
+		`, computedStatusSQL)
+
+	err := pgxscan.Select(db.Context, db.Conn, &proposals, sql, communityId)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	return proposals, nil
+}
+
+const (
+	SnapshotProcessing = "processing"
+	SnapshotComplete   = "complete"
+	SnapshotFailed     = "failed"
+)
+
+func (p *Proposal) UpdateSnapshotStatus(db *s.Database, status string, fetched, total int) error {
+	_, err := db.Conn.Exec(db.Context, `
+		UPDATE proposals
+		SET snapshot_status = $1, snapshot_fetched = $2, snapshot_total = $3
+		WHERE id = $4
+	`, status, fetched, total, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.Snapshot_status = &status
+	p.Snapshot_fetched = &fetched
+	p.Snapshot_total = &total
+	return nil
+}
+
+// Whether the voters' balances were prefetched when the proposal was created.
+func (p *Proposal) HasPrefetchedBalances() bool {
+	return p.Snapshot_total != nil
+}
+
+// Moves the proposal's lifecycle status forward. Only succeeds if the status
+// is still the one that was read, returns false if the transition already ran.
+func (p *Proposal) UpdateLifecycleStatus(db *s.Database, status string) (bool, error) {
+	tag, err := db.Conn.Exec(db.Context, `
+		UPDATE proposals
+		SET lifecycle_status = $1
+		WHERE id = $2 AND lifecycle_status = $3
+	`, status, p.ID, p.Lifecycle_status)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	p.Lifecycle_status = status
+	return true, nil
+}
+
 func (p *Proposal) IsLive() bool {
 	now := time.Now().UTC()
 	return now.After(p.Start_time) && now.Before(p.End_time)
 }
 
+func (p *Proposal) IsRankedChoice() bool {
+	return p.Voting_type != nil && *p.Voting_type == RankedChoice
+}
+
+func (p *Proposal) IsApproval() bool {
+	return p.Voting_type != nil && *p.Voting_type == Approval
+}
+
+func (p *Proposal) IsSplit() bool {
+	return p.Voting_type != nil && *p.Voting_type == Split
+}
+
+// Ranked choice, approval and split ballots carry more than one choice.
+func (p *Proposal) AcceptsMultipleChoices() bool {
+	return p.IsRankedChoice() || p.IsApproval() || p.IsSplit()
+}
+
+func (p *Proposal) HasPercentageQuorum() bool {
+	return p.Quorum != nil && p.Quorum_type != nil && *p.Quorum_type == QuorumPercentage
+}
+
+// Returns the vote weight needed to reach quorum, or nil if the
+// quorum can't be known because the supply was never snapshotted.
+func (p *Proposal) QuorumWeight() *float64 {
+	if !p.HasPercentageQuorum() {
+		return p.Quorum
+	}
+	if p.Total_supply == nil {
+		return nil
+	}
+
+	weight := *p.Total_supply * *p.Quorum / 100
+	return &weight
+}
+
+// Quorum and pass threshold rules must be in range,
+// percentages are between 0 and 100. Percentage quorums are
+// only allowed for token weighted strategies.
+func (p *Proposal) ValidateOutcomeRules() error {
+	if p.Quorum != nil {
+		if *p.Quorum < 0 {
+			return errors.New("quorum must not be negative")
+		}
+		if p.Quorum_type == nil {
+			quorumType := QuorumAbsolute
+			p.Quorum_type = &quorumType
+		}
+		if *p.Quorum_type != QuorumAbsolute && *p.Quorum_type != QuorumPercentage {
+			return fmt.Errorf("invalid quorum type: %s", *p.Quorum_type)
+		}
+		if p.HasPercentageQuorum() && *p.Quorum > 100 {
+			return errors.New("quorum percentage must not be greater than 100")
+		}
+		// a share of the supply can't be compared with weights in other units,
+		// like square roots of balances, headcounts or NFTs
+		if p.HasPercentageQuorum() && (p.Strategy == nil || !IsTokenWeightedStrategy(*p.Strategy)) {
+			return errors.New("percentage quorums require a token weighted strategy")
+		}
+	}
+
+	if p.Pass_threshold != nil && (*p.Pass_threshold <= 0 || *p.Pass_threshold > 100) {
+		return errors.New("pass threshold must be greater than 0 and at most 100")
+	}
+
+	return nil
+}
+
+func EnsureValidVotingType(votingType string) bool {
+	for _, t := range VOTING_TYPES {
+		if t == votingType {
+			return true
+		}
+	}
+	return false
+}
+
 // Validations
 
 // Returns an error if the account's balance is insufficient to cast
@@ -219,43 +424,38 @@ func (p *Proposal) ValidateBalance(weight float64) error {
 	return nil
 }
 
-func (p *Proposal) EnforceMaxWeight(balance float64) float64 {
-	if p.Max_weight == nil {
-		return balance
+// Decimals of the strategy's token, snapshotted from the contract
+// when the proposal was created.
+func (p *Proposal) TokenDecimals() int {
+	if p.Decimals == nil {
+		return shared.DefaultDecimals
 	}
+	return *p.Decimals
+}
 
-	var allowedBalance float64
-	var maxWeight = *p.Max_weight
-
-	//inversions is used to correctly shift Max_weight x amount of
-	//decimal places, depending on how many decimal places it originally is
-	var inversions = map[int]int{
-		1: 8,
-		2: 7,
-		3: 6,
-		4: 5,
-		5: 4,
-		6: 3,
-		7: 2,
-		8: 1,
+// Sums the FLOW balance buckets the proposal counts toward vote weight,
+// snapshotted from the contract when the proposal was created. Returns
+// defaultBalance when the proposal doesn't choose buckets or the balance
+// has no breakdown.
+func (p *Proposal) BucketBalance(flowBalance *shared.FlowBalance, defaultBalance uint64) uint64 {
+	if p.Balance_buckets == nil || flowBalance == nil {
+		return defaultBalance
 	}
+	return flowBalance.Sum(*p.Balance_buckets)
+}
 
-	//we shift the maxWeight up by x decimal places so that the
-	//comparison block works as expected
-	//first, get the number of decimal places left side of . for maxWeight
-	maxLimitLength := len(strings.Split(fmt.Sprintf("%v", maxWeight), ".")[0])
-
-	minuend := inversions[maxLimitLength]
-	powerToShift := minuend - maxLimitLength
-	shiftedMaxWeight := maxWeight * math.Pow(10, float64(powerToShift))
+// Caps a fixed-point token balance at the proposal's max weight.
+func (p *Proposal) EnforceMaxWeight(balance uint64) uint64 {
+	if p.Max_weight == nil {
+		return balance
+	}
 
-	if balance >= shiftedMaxWeight {
-		allowedBalance = shiftedMaxWeight
-	} else {
-		allowedBalance = balance
+	maxBalance := shared.ToFixedPoint(*p.Max_weight, p.TokenDecimals())
+	if balance > maxBalance {
+		return maxBalance
 	}
 
-	return allowedBalance
+	return balance
 }
 
 func GetActiveStrategiesForCommunity(db *s.Database, communityId int) ([]string, error) {
@@ -8,12 +8,25 @@ import (
 )
 
 type ProposalResults struct {
-	Proposal_id       int                `json:"proposalId" validate:"required"`
-	Results       	  map[string]int     `json:"results" validate:"required"`
-	Results_float 	   map[string]float64  `json:"resultsFloat" validate:"required"`
-	Updated_at        time.Time          `json:"updatedAt" validate:"required"`
-	Cid           	  *string            `json:"cid,omitempty"`
-	Achievements_done bool               `json:"achievementsDone"`
+	Proposal_id       int                 `json:"proposalId" validate:"required"`
+	Results           map[string]int      `json:"results" validate:"required"`
+	Results_float     map[string]float64  `json:"resultsFloat" validate:"required"`
+	Rounds            []RankedChoiceRound `json:"rounds,omitempty"`
+	Approvals         map[string]int      `json:"approvals,omitempty"`
+	Total_weight      float64             `json:"totalWeight"`
+	Quorum_met        bool                `json:"quorumMet"`
+	Passed            bool                `json:"passed"`
+	Winning_choice    *string             `json:"winningChoice,omitempty"`
+	Updated_at        time.Time           `json:"updatedAt" validate:"required"`
+	Cid               *string             `json:"cid,omitempty"`
+	Achievements_done bool                `json:"achievementsDone"`
+}
+
+type RankedChoiceRound struct {
+	Round      int                `json:"round"`
+	Results    map[string]float64 `json:"results"`
+	Eliminated []string           `json:"eliminated,omitempty"`
+	Exhausted  float64            `json:"exhausted"`
 }
 
 func NewProposalResults(id int, choices []s.Choice) *ProposalResults {
@@ -39,3 +52,211 @@ func (r *ProposalResults) GetLatestProposalResultsById(db *s.Database) error {
 		LIMIT 1
 		`, r.Proposal_id)
 }
+
+// Stores the final results of a closed proposal. Results are only
+// written once, returns false if the proposal already has results.
+func (r *ProposalResults) CreateProposalResults(db *s.Database) (bool, error) {
+	tag, err := db.Conn.Exec(db.Context,
+		`
+		INSERT INTO proposal_results(
+			proposal_id,
+			results,
+			results_float,
+			rounds,
+			approvals,
+			total_weight,
+			quorum_met,
+			passed,
+			winning_choice,
+			cid,
+			updated_at
+		)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (proposal_id) DO NOTHING
+		`,
+		r.Proposal_id,
+		r.Results,
+		r.Results_float,
+		r.Rounds,
+		r.Approvals,
+		r.Total_weight,
+		r.Quorum_met,
+		r.Passed,
+		r.Winning_choice,
+		r.Cid,
+		r.Updated_at,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// TallyRankedChoice runs instant-runoff rounds over the ranked ballots.
+// Votes must already have their Weight set by the proposal's strategy.
+// Each round counts a ballot's weight towards its highest ranked choice
+// still in the running. If no choice holds a majority of the counted
+// weight, the choice with the least weight is eliminated; ties are broken
+// by eliminating the choice listed last on the proposal.
+// The final round is reported in Results/Results_float.
+func (r *ProposalResults) TallyRankedChoice(votes []*VoteWithBalance, p *Proposal) {
+	remaining := make(map[string]bool)
+	for _, choice := range p.Choices {
+		remaining[choice.Choice_text] = true
+	}
+
+	r.Rounds = []RankedChoiceRound{}
+
+	for len(remaining) > 0 {
+		round := RankedChoiceRound{
+			Round:   len(r.Rounds) + 1,
+			Results: make(map[string]float64),
+		}
+		for choice := range remaining {
+			round.Results[choice] = 0.0
+		}
+
+		var total float64
+		for _, vote := range votes {
+			if vote.Weight == nil {
+				continue
+			}
+			counted := false
+			for _, choice := range vote.Choices {
+				if remaining[choice] {
+					round.Results[choice] += *vote.Weight
+					counted = true
+					break
+				}
+			}
+			if counted {
+				total += *vote.Weight
+			} else {
+				round.Exhausted += *vote.Weight
+			}
+		}
+
+		majority := false
+		for _, count := range round.Results {
+			if count > total/2 {
+				majority = true
+			}
+		}
+
+		if round.Round == 1 {
+			r.Total_weight = total + round.Exhausted
+		}
+
+		if majority || len(remaining) == 1 || total == 0 {
+			r.Rounds = append(r.Rounds, round)
+			break
+		}
+
+		// walk choices in reverse so ties eliminate the last listed choice
+		var eliminated string
+		for i := len(p.Choices) - 1; i >= 0; i-- {
+			choice := p.Choices[i].Choice_text
+			if !remaining[choice] {
+				continue
+			}
+			if eliminated == "" || round.Results[choice] < round.Results[eliminated] {
+				eliminated = choice
+			}
+		}
+
+		round.Eliminated = []string{eliminated}
+		delete(remaining, eliminated)
+		r.Rounds = append(r.Rounds, round)
+	}
+
+	if len(r.Rounds) == 0 {
+		return
+	}
+
+	final := r.Rounds[len(r.Rounds)-1]
+	for _, choice := range p.Choices {
+		r.Results_float[choice.Choice_text] = final.Results[choice.Choice_text]
+		r.Results[choice.Choice_text] = int(final.Results[choice.Choice_text])
+	}
+}
+
+// TallyApproval gives each ballot's full weight to every choice it approves.
+// Votes must already have their Weight set by the proposal's strategy.
+// Approvals reports the number of ballots approving each choice.
+func (r *ProposalResults) TallyApproval(votes []*VoteWithBalance, p *Proposal) {
+	r.Approvals = make(map[string]int)
+	for _, choice := range p.Choices {
+		r.Approvals[choice.Choice_text] = 0
+	}
+
+	for _, vote := range votes {
+		if vote.Weight == nil {
+			continue
+		}
+		r.Total_weight += *vote.Weight
+		for _, choice := range vote.Choices {
+			if _, ok := r.Approvals[choice]; !ok {
+				continue
+			}
+			r.Approvals[choice]++
+			r.Results_float[choice] += *vote.Weight
+		}
+	}
+
+	for choice, weight := range r.Results_float {
+		r.Results[choice] = int(weight)
+	}
+}
+
+// ComputeOutcome decides whether the tallied proposal reached quorum
+// and passed. The winning choice is the one with the most weight, a tie
+// has no winner. Without a pass threshold the winning choice passes;
+// with one, its share of all weight cast must meet the threshold.
+func (r *ProposalResults) ComputeOutcome(p *Proposal) {
+	totals := make(map[string]float64)
+	var sum float64
+	for _, choice := range p.Choices {
+		// strategies counting whole votes only report integer results
+		total := r.Results_float[choice.Choice_text]
+		if total == 0 {
+			total = float64(r.Results[choice.Choice_text])
+		}
+		totals[choice.Choice_text] = total
+		sum += total
+	}
+
+	// ranked choice and approval tallies record the weight cast themselves
+	if r.Total_weight == 0 {
+		r.Total_weight = sum
+	}
+
+	r.Quorum_met = true
+	if p.Quorum != nil {
+		quorumWeight := p.QuorumWeight()
+		r.Quorum_met = quorumWeight != nil && r.Total_weight >= *quorumWeight
+	}
+
+	r.Winning_choice = nil
+	var max float64
+	tied := false
+	for _, choice := range p.Choices {
+		total := totals[choice.Choice_text]
+		if total > max {
+			max = total
+			winner := choice.Choice_text
+			r.Winning_choice = &winner
+			tied = false
+		} else if total == max && max > 0 {
+			tied = true
+		}
+	}
+	if tied {
+		r.Winning_choice = nil
+	}
+
+	r.Passed = r.Quorum_met && r.Winning_choice != nil
+	if r.Passed && p.Pass_threshold != nil {
+		r.Passed = max/r.Total_weight*100 >= *p.Pass_threshold
+	}
+}
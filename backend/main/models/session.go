@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	s "github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+)
+
+// A session started by signing in with a wallet. Session tokens carry its ID,
+// so revoking it ends every token issued for it.
+type Session struct {
+	ID                 string     `json:"id"`
+	Addr               string     `json:"addr"`
+	Nonce              string     `json:"-"`
+	Refresh_token_hash string     `json:"-"`
+	Expires_at         time.Time  `json:"expiresAt"`
+	Revoked_at         *time.Time `json:"revokedAt,omitempty"`
+	Created_at         *time.Time `json:"createdAt,omitempty"`
+}
+
+type SignInPayload struct {
+	Addr                 string                  `json:"addr"                validate:"required"`
+	Nonce                string                  `json:"nonce"               validate:"required,min=16,max=255"`
+	Timestamp            string                  `json:"timestamp"           validate:"required"`
+	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures" validate:"required"`
+}
+
+type RefreshSessionPayload struct {
+	Refresh_token string `json:"refreshToken" validate:"required"`
+}
+
+type SessionResponse struct {
+	Addr               string    `json:"addr"`
+	Token              string    `json:"token"`
+	Expires_at         time.Time `json:"expiresAt"`
+	Refresh_token      string    `json:"refreshToken"`
+	Refresh_expires_at time.Time `json:"refreshExpiresAt"`
+}
+
+// Returns pgx.ErrNoRows if the nonce already started a session.
+func (ss *Session) CreateSession(db *s.Database) error {
+	ss.ID = uuid.New().String()
+	return db.Conn.QueryRow(db.Context, `
+		INSERT INTO sessions(id, addr, nonce, refresh_token_hash, expires_at)
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (addr, nonce) DO NOTHING
+		RETURNING created_at
+	`, ss.ID, ss.Addr, ss.Nonce, ss.Refresh_token_hash, ss.Expires_at).Scan(&ss.Created_at)
+}
+
+func (ss *Session) GetSessionById(db *s.Database) error {
+	return pgxscan.Get(db.Context, db.Conn, ss, `SELECT * FROM sessions WHERE id = $1`, ss.ID)
+}
+
+func (ss *Session) GetSessionByRefreshTokenHash(db *s.Database) error {
+	return pgxscan.Get(db.Context, db.Conn, ss,
+		`SELECT * FROM sessions WHERE refresh_token_hash = $1`,
+		ss.Refresh_token_hash)
+}
+
+// Replaces the refresh token and extends the session. Fails if the session
+// was refreshed or revoked since it was read, so a refresh token works once.
+func (ss *Session) Refresh(db *s.Database, refreshTokenHash string, expiresAt time.Time) error {
+	cmd, err := db.Conn.Exec(db.Context, `
+		UPDATE sessions
+		SET refresh_token_hash = $1, expires_at = $2
+		WHERE id = $3 AND refresh_token_hash = $4 AND revoked_at IS NULL
+	`, refreshTokenHash, expiresAt, ss.ID, ss.Refresh_token_hash)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return errors.New("session was refreshed or revoked")
+	}
+
+	ss.Refresh_token_hash = refreshTokenHash
+	ss.Expires_at = expiresAt
+	return nil
+}
+
+func (ss *Session) Revoke(db *s.Database) error {
+	return db.Conn.QueryRow(db.Context, `
+		UPDATE sessions
+		SET revoked_at = (now() at time zone 'utc')
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING revoked_at
+	`, ss.ID).Scan(&ss.Revoked_at)
+}
+
+func (ss *Session) IsActive() bool {
+	return ss.Revoked_at == nil && time.Now().UTC().Before(ss.Expires_at)
+}
@@ -34,5 +34,16 @@ func GetVotingStrategies(db *s.Database) ([]*VotingStrategy, error) {
 }
 
 func IsNFTStrategy(name string) bool {
-	return name == "balance-of-nfts" || name == "float-nfts" || name == "custom-script"
+	return name == "balance-of-nfts" ||
+		name == "float-nfts" ||
+		name == "custom-script" ||
+		name == "trait-weighted-nfts"
+}
+
+// Strategies whose vote weight is the voter's token balance, so their
+// tallies are in the units of the token's total supply.
+func IsTokenWeightedStrategy(name string) bool {
+	return name == "token-weighted-default" ||
+		name == "total-token-weighted-default" ||
+		name == "staked-token-weighted-default"
 }
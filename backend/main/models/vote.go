@@ -14,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/rs/zerolog/log"
+	"github.com/thoas/go-funk"
 )
 
 type Vote struct {
@@ -21,8 +22,11 @@ type Vote struct {
 	Proposal_id          int                     `json:"proposalId"`
 	Addr                 string                  `json:"addr"                validate:"required"`
 	Choice               string                  `json:"choice"              validate:"required"`
+	Choices              []string                `json:"choices,omitempty"`
+	Allocations          map[string]int          `json:"allocations,omitempty"`
 	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures" validate:"required"`
 	Created_at           time.Time               `json:"createdAt,omitempty"`
+	Updated_at           *time.Time              `json:"updatedAt,omitempty"`
 	Cid                  *string                 `json:"cid"`
 	Message              string                  `json:"message"`
 	Voucher              *shared.Voucher         `json:"voucher,omitempty"`
@@ -41,15 +45,43 @@ type VoteWithBalance struct {
 	SecondaryAccountBalance *uint64  `json:"secondaryAccountBalance"`
 	StakingBalance          *uint64  `json:"stakingBalance"`
 	Weight                  *float64 `json:"weight"`
+	// Weight allocated to each choice on a split ballot
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// Set when the vote is a delegate's ballot counted for a delegator
+	Delegator_addr *string `json:"delegatorAddr,omitempty"`
+	// Weight delegated to the voter, not included in Weight
+	Delegated_weight *float64 `json:"delegatedWeight,omitempty"`
+	// Per-contract breakdown of the balance for composite strategies
+	Components *[]BalanceComponent `json:"components,omitempty"`
+	// Breakdown of a FLOW balance
+	FlowBalance *s.FlowBalance `json:"flowBalance,omitempty"`
 
 	NFTs []*NFT
 }
 
+// A ballot that was replaced when the voter changed their vote.
+type VoteHistory struct {
+	ID                   int                     `json:"id"`
+	Vote_id              int                     `json:"voteId"`
+	Proposal_id          int                     `json:"proposalId"`
+	Addr                 string                  `json:"addr"`
+	Choice               string                  `json:"choice"`
+	Choices              []string                `json:"choices,omitempty"`
+	Allocations          map[string]int          `json:"allocations,omitempty"`
+	Composite_signatures *[]s.CompositeSignature `json:"compositeSignatures"`
+	Message              string                  `json:"message"`
+	Cid                  *string                 `json:"cid"`
+	Cast_at              time.Time               `json:"castAt"`
+	Replaced_at          time.Time               `json:"replacedAt"`
+}
+
 type NFT struct {
 	ID             interface{} `json:"id"`
 	Contract_addr  string      `json:"contract_addr"`
 	Created_at     time.Time   `json:"created_at"`
 	Float_event_id uint64      `json:"event_id,omitempty"`
+	// Vote weight of the NFT, 1 unless set by the strategy
+	Weight *float64 `json:"weight,omitempty"`
 }
 
 type VotingStreak struct {
@@ -85,14 +117,19 @@ func GetVotesForAddress(
 	sql := `select v.*, 
 		b.primary_account_balance,
 		b.secondary_account_balance,
-		b.staking_balance
+		b.staking_balance,
+		b.components,
+		b.flow_balance
 		from votes v
+		join proposals p on p.id = v.proposal_id
 		left join balances b on b.addr = v.addr
+			and p.block_height = b.block_height
+			and b.source IS NOT DISTINCT FROM p.balance_source
 		WHERE v.addr = $3`
 
 	// Conditionally add proposal_id condition
 	if len(*proposalIds) > 0 {
-		sql = sql + " AND proposal_id = ANY($4)"
+		sql = sql + " AND v.proposal_id = ANY($4) "
 		sql = sql + "LIMIT $1 OFFSET $2 "
 		err = pgxscan.Select(db.Context, db.Conn, &votes,
 			sql, pageParams.Count, pageParams.Start, address, *proposalIds)
@@ -125,11 +162,14 @@ func GetAllVotesForProposal(db *s.Database, proposalId int, strategy string) ([]
 		b.primary_account_balance,
 		b.secondary_account_balance,
 		b.staking_balance,
+		b.components,
+		b.flow_balance,
 		COALESCE(p.block_height, 0) as block_height
     from votes v
     join proposals p on p.id = $1
   	left join balances b on b.addr = v.addr 
 		and p.block_height = b.block_height
+		and b.source IS NOT DISTINCT FROM p.balance_source
     where proposal_id = $1
 `
 	err := pgxscan.Select(db.Context, db.Conn, &votes, sql, proposalId)
@@ -169,11 +209,14 @@ func GetVotesForProposal(
 	sql := `select v.*, p.block_height, 
 		b.primary_account_balance,
 		b.secondary_account_balance,
-		b.staking_balance
+		b.staking_balance,
+		b.components,
+		b.flow_balance
     from votes v
     join proposals p on p.id = v.proposal_id
   	left join balances b on b.addr = v.addr 
 		and p.block_height = b.block_height
+		and b.source IS NOT DISTINCT FROM p.balance_source
     where v.proposal_id = $3`
 
 	sql = sql + " " + orderBySql
@@ -223,9 +266,14 @@ func (vb *VoteWithBalance) GetVote(db *s.Database) error {
 		`select v.*, 
 		b.primary_account_balance,
 		b.secondary_account_balance,
-		b.staking_balance
+		b.staking_balance,
+		b.components,
+		b.flow_balance
 		from votes v
+		join proposals p on p.id = v.proposal_id
 		left join balances b on b.addr = v.addr
+			and p.block_height = b.block_height
+			and b.source IS NOT DISTINCT FROM p.balance_source
 		WHERE proposal_id = $1 AND v.addr = $2`,
 		vb.Proposal_id, vb.Addr)
 
@@ -270,51 +318,345 @@ func (v *Vote) CreateVote(db *s.Database) error {
 	return nil
 }
 
-func ValidateVoteMessage(message string, proposal Proposal) error {
+// Replaces the ballot of an existing vote, keeping the previous ballot in the
+// vote history. The vote keeps its id and created_at, so achievements like
+// early votes stay tied to when the voter first voted.
+func (v *Vote) UpdateVote(db *s.Database) error {
+	tx, err := db.Conn.Begin(db.Context)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.Context)
+
+	_, err = tx.Exec(db.Context,
+		`
+		INSERT INTO vote_history(vote_id, proposal_id, addr, choice, choices, allocations, composite_signatures, message, cid, cast_at)
+		SELECT id, proposal_id, addr, choice, choices, allocations, composite_signatures, message, cid, COALESCE(updated_at, created_at)
+		FROM votes WHERE id = $1
+		`, v.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(db.Context,
+		`
+		UPDATE votes
+		SET choice = $2, choices = $3, allocations = $4, composite_signatures = $5, message = $6, cid = $7,
+			updated_at = (now() at time zone 'utc')
+		WHERE id = $1
+		RETURNING updated_at
+		`, v.ID, v.Choice, v.Choices, v.Allocations, v.Composite_signatures, v.Message, v.Cid).Scan(&v.Updated_at)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(db.Context)
+}
+
+// Checks whether the signed ballot message was already cast by the address,
+// either as its current vote or a replaced one.
+func IsBallotCast(db *s.Database, proposalId int, addr string, message string) (bool, error) {
+	var cast bool
+	err := db.Conn.QueryRow(db.Context,
+		`
+		SELECT EXISTS (
+			SELECT 1 FROM votes WHERE proposal_id = $1 AND addr = $2 AND message = $3
+			UNION ALL
+			SELECT 1 FROM vote_history WHERE proposal_id = $1 AND addr = $2 AND message = $3
+		)
+		`, proposalId, addr, message).Scan(&cast)
+
+	return cast, err
+}
+
+// Returns the ballots the address replaced on the proposal, latest first.
+func GetVoteHistory(db *s.Database, proposalId int, addr string) ([]*VoteHistory, error) {
+	var history = []*VoteHistory{}
+	err := pgxscan.Select(db.Context, db.Conn, &history,
+		`
+		SELECT * FROM vote_history
+		WHERE proposal_id = $1 AND addr = $2
+		ORDER BY replaced_at DESC, id DESC
+		`, proposalId, addr)
+
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// The choices a voter signed, decoded from the vote message.
+type Ballot struct {
+	Choices     []string
+	Allocations map[string]int
+}
+
+func ValidateVoteMessage(message string, proposal Proposal) (Ballot, error) {
 	log.Info().Msgf("validating message: %s", message)
 	vars := strings.Split(message, ":")
 
-	// check proposal choices to see if choice is valid
-	encodedChoice := vars[1]
-	choiceBytes, err := hex.DecodeString(encodedChoice)
+	if len(vars) != 3 {
+		return Ballot{}, errors.New("invalid vote message format")
+	}
 
-	if err != nil {
-		return errors.New("couldnt decode choice in message from hex string")
+	// check proposal choices to see if choice is valid
+	// ranked choice and approval ballots encode each choice, comma separated:
+	// <proposalId>:<choice>,<choice>,...:<timestamp>
+	// split ballots add the percentage allocated to each choice:
+	// <proposalId>:<choice>=<percentage>,<choice>=<percentage>,...:<timestamp>
+	var ballot Ballot
+	encodedChoices := strings.Split(vars[1], ",")
+	if !proposal.AcceptsMultipleChoices() && len(encodedChoices) != 1 {
+		return Ballot{}, errors.New("multiple choices are only allowed on ranked choice, approval or split proposals")
 	}
 
-	validChoice := false
-	for _, choice := range proposal.Choices {
-		if choice.Choice_text == string(choiceBytes) {
-			validChoice = true
-			break
+	if proposal.IsSplit() {
+		allocations, err := decodeAllocations(encodedChoices)
+		if err != nil {
+			return Ballot{}, err
+		}
+		if err := validateSplitBallot(allocations, proposal); err != nil {
+			return Ballot{}, err
+		}
+		ballot.Allocations = allocations
+	} else {
+		for _, encodedChoice := range encodedChoices {
+			choiceBytes, err := hex.DecodeString(encodedChoice)
+			if err != nil {
+				return Ballot{}, errors.New("couldnt decode choice in message from hex string")
+			}
+			ballot.Choices = append(ballot.Choices, string(choiceBytes))
+		}
+
+		switch {
+		case proposal.IsRankedChoice():
+			if err := validateRankedBallot(ballot.Choices, proposal); err != nil {
+				return Ballot{}, err
+			}
+		case proposal.IsApproval():
+			if err := validateApprovalBallot(ballot.Choices, proposal); err != nil {
+				return Ballot{}, err
+			}
+		default:
+			if !isProposalChoice(ballot.Choices[0], proposal) {
+				return Ballot{}, errors.New("invalid choice for proposal")
+			}
 		}
-	}
-	if !validChoice {
-		return errors.New("invalid choice for proposal")
 	}
 
 	// check timestamp and ensure no longer than 60 seconds has passed
-	timestamp, _ := strconv.ParseInt(vars[2], 10, 64)
+	timestamp, err := strconv.ParseInt(vars[2], 10, 64)
+	if err != nil {
+		return Ballot{}, errors.New("couldnt parse timestamp in message")
+	}
 	uxTime := time.Unix(timestamp/1000, (timestamp%1000)*1000*1000)
 	diff := time.Now().UTC().Sub(uxTime).Seconds()
 	if diff > timestampExpiry {
-		return errors.New("timestamp on request has expired")
+		return Ballot{}, errors.New("timestamp on request has expired")
+	}
+
+	return ballot, nil
+}
+
+// Checks the choices in the request body are the ones the voter signed.
+// The tally reads the vote's choices, so a body that differs from the signed
+// message would count a ballot the voter never agreed to.
+func (v *Vote) MatchesBallot(ballot Ballot, proposal Proposal) error {
+	switch {
+	case proposal.IsRankedChoice():
+		if !equalChoices(v.Choices, ballot.Choices) {
+			return errors.New("ranking does not match the signed ballot")
+		}
+	case proposal.IsApproval():
+		if !equalChoices(v.Choices, ballot.Choices) {
+			return errors.New("approved choices do not match the signed ballot")
+		}
+	case proposal.IsSplit():
+		if !equalAllocations(v.Allocations, ballot.Allocations) {
+			return errors.New("allocations do not match the signed ballot")
+		}
+	default:
+		if v.Choice != ballot.Choices[0] {
+			return errors.New("choice does not match the signed ballot")
+		}
 	}
 
 	return nil
 }
 
 func (v *Vote) ValidateChoice(proposal Proposal) error {
-	validChoice := false
-	for _, choice := range proposal.Choices {
-		if choice.Choice_text == v.Choice {
-			validChoice = true
-			break
+	var err error
+	switch {
+	case proposal.IsRankedChoice():
+		err = validateRankedBallot(v.Choices, proposal)
+	case proposal.IsApproval():
+		err = validateApprovalBallot(v.Choices, proposal)
+	case proposal.IsSplit():
+		if err := validateSplitBallot(v.Allocations, proposal); err != nil {
+			return err
+		}
+		if _, ok := v.Allocations[v.Choice]; !ok {
+			return errors.New("choice must be allocated a percentage on the ballot")
+		}
+		return nil
+	default:
+		if !isProposalChoice(v.Choice, proposal) {
+			return errors.New("invalid choice for proposal")
+		}
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	if v.Choice != v.Choices[0] {
+		return errors.New("choice must match the first choice on the ballot")
+	}
+	return nil
+}
+
+// Allocate divides a vote's weight between its choices.
+// Split ballots divide it by the percentage allocated to each choice,
+// any other ballot gives the whole weight to the vote's choice.
+func (v *Vote) Allocate(weight float64) map[string]float64 {
+	if len(v.Allocations) == 0 {
+		return map[string]float64{v.Choice: weight}
+	}
+
+	weights := make(map[string]float64)
+	for choice, percentage := range v.Allocations {
+		weights[choice] = weight * float64(percentage) / 100
+	}
+	return weights
+}
+
+// The choice given the largest share of a split ballot,
+// ties go to the choice listed first on the proposal.
+func (v *Vote) LargestAllocation(proposal Proposal) string {
+	var largest string
+	for _, c := range proposal.Choices {
+		if v.Allocations[c.Choice_text] > v.Allocations[largest] {
+			largest = c.Choice_text
+		}
+	}
+	return largest
+}
+
+func isProposalChoice(choice string, proposal Proposal) bool {
+	for _, c := range proposal.Choices {
+		if c.Choice_text == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// A ranked ballot must rank every choice on the proposal exactly once.
+func validateRankedBallot(choices []string, proposal Proposal) error {
+	if len(choices) != len(proposal.Choices) {
+		return errors.New("ranked ballot must rank every choice on the proposal")
+	}
+
+	return validateDistinctChoices(choices, proposal)
+}
+
+// An approval ballot may approve any non-empty subset of the proposal's choices.
+func validateApprovalBallot(choices []string, proposal Proposal) error {
+	if len(choices) == 0 {
+		return errors.New("approval ballot must approve at least one choice")
+	}
+
+	return validateDistinctChoices(choices, proposal)
+}
+
+// A split ballot allocates whole percentages to distinct choices, summing to 100.
+func validateSplitBallot(allocations map[string]int, proposal Proposal) error {
+	if len(allocations) == 0 {
+		return errors.New("split ballot must allocate at least one choice")
+	}
+
+	total := 0
+	for choice, percentage := range allocations {
+		if !isProposalChoice(choice, proposal) {
+			return errors.New("invalid choice for proposal")
+		}
+		if percentage <= 0 {
+			return fmt.Errorf("percentage allocated to choice %s must be positive", choice)
+		}
+		total += percentage
+	}
+
+	if total != 100 {
+		return fmt.Errorf("split ballot percentages must sum to 100, got %d", total)
+	}
+
+	return nil
+}
+
+func decodeAllocations(encodedAllocations []string) (map[string]int, error) {
+	allocations := make(map[string]int)
+	for _, encodedAllocation := range encodedAllocations {
+		parts := strings.Split(encodedAllocation, "=")
+		if len(parts) != 2 {
+			return nil, errors.New("split ballot choices must be formatted as <choice>=<percentage>")
+		}
+
+		choiceBytes, err := hex.DecodeString(parts[0])
+		if err != nil {
+			return nil, errors.New("couldnt decode choice in message from hex string")
+		}
+
+		percentage, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentage for choice %s", string(choiceBytes))
 		}
+
+		if _, ok := allocations[string(choiceBytes)]; ok {
+			return nil, fmt.Errorf("choice %s appears more than once on the ballot", string(choiceBytes))
+		}
+		allocations[string(choiceBytes)] = percentage
 	}
-	if !validChoice {
-		return errors.New("invalid choice for proposal")
+
+	return allocations, nil
+}
+
+func equalChoices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
+	return true
+}
+
+func equalAllocations(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for choice, percentage := range a {
+		if p, ok := b[choice]; !ok || p != percentage {
+			return false
+		}
+	}
+	return true
+}
+
+func validateDistinctChoices(choices []string, proposal Proposal) error {
+	seen := make(map[string]bool)
+	for _, choice := range choices {
+		if !isProposalChoice(choice, proposal) {
+			return errors.New("invalid choice for proposal")
+		}
+		if seen[choice] {
+			return fmt.Errorf("choice %s appears more than once on the ballot", choice)
+		}
+		seen[choice] = true
+	}
+
 	return nil
 }
 
@@ -332,7 +674,7 @@ func getUsersNFTs(db *s.Database, votes []*VoteWithBalance) ([]*VoteWithBalance,
 
 func GetUserNFTs(db *s.Database, vote *VoteWithBalance) ([]*NFT, error) {
 	var ids []*NFT
-	sql := `select id from nfts
+	sql := `select id, weight from nfts
 	where proposal_id = $1 and owner_addr = $2
 	`
 
@@ -350,9 +692,9 @@ func CreateUserNFTRecord(db *s.Database, v *VoteWithBalance) error {
 	for _, nft := range v.NFTs {
 		_, err := db.Conn.Exec(db.Context,
 			`
-		INSERT INTO nfts(uuid, proposal_id, owner_addr, id)
-		VALUES($1, $2, $3, $4)
-	`, uuid.New(), v.Proposal_id, v.Addr, nft.ID)
+		INSERT INTO nfts(uuid, proposal_id, owner_addr, id, weight)
+		VALUES($1, $2, $3, $4, COALESCE($5, 1))
+	`, uuid.New(), v.Proposal_id, v.Addr, nft.ID, nft.Weight)
 		if err != nil {
 			return err
 		}
@@ -382,10 +724,10 @@ func createVote(db *s.Database, v *Vote) error {
 	// Create Vote
 	err := db.Conn.QueryRow(db.Context,
 		`
-			INSERT INTO votes(proposal_id, addr, choice, composite_signatures, cid, message)
-			VALUES($1, $2, $3, $4, $5, $6)
+			INSERT INTO votes(proposal_id, addr, choice, choices, allocations, composite_signatures, cid, message)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING id, created_at
-		`, v.Proposal_id, v.Addr, v.Choice, v.Composite_signatures, v.Cid, v.Message).Scan(&v.ID, &v.Created_at)
+		`, v.Proposal_id, v.Addr, v.Choice, v.Choices, v.Allocations, v.Composite_signatures, v.Cid, v.Message).Scan(&v.ID, &v.Created_at)
 
 	return err
 }
@@ -410,20 +752,25 @@ func AddEarlyVoteAchievement(db *s.Database, v *Vote) error {
 	return nil
 }
 
-func AddWinningVoteAchievement(db *s.Database, votes []*VoteWithBalance, p ProposalResults) error {
-	maxVotes := 0
-	winningChoice := ""
-	for k, v := range p.Results {
-		if v > maxVotes {
-			maxVotes = v
-			winningChoice = k
-		}
+// Whether the vote backs the choice. Approval ballots back every choice
+// they approve, other ballots back the vote's choice.
+func (v *Vote) Backs(choice string, proposal Proposal) bool {
+	if proposal.IsApproval() {
+		return funk.ContainsString(v.Choices, choice)
 	}
-	for _, v := range votes {
-		if v.Choice == winningChoice {
-			_, err := db.Conn.Exec(db.Context, `UPDATE votes SET is_winning = 'true' WHERE id = $1`, v.ID)
-			if err != nil {
-				return err
+	return v.Choice == choice
+}
+
+// Marks the votes for the winning choice once the proposal has closed,
+// only proposals that passed have winning votes.
+func AddWinningVoteAchievement(db *s.Database, votes []*VoteWithBalance, proposal Proposal, p ProposalResults) error {
+	if p.Passed && p.Winning_choice != nil {
+		for _, v := range votes {
+			if v.Backs(*p.Winning_choice, proposal) {
+				_, err := db.Conn.Exec(db.Context, `UPDATE votes SET is_winning = 'true' WHERE id = $1`, v.ID)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -470,19 +817,19 @@ func getUserVotes(db *s.Database, addr string, communityId int) ([]VotingStreak,
 	// Determine if this vote is part of a streak
 	// Proposals with the user address count as a vote for that proposal
 	// NULL means the user did not vote
-	sql := fmt.Sprintf(`
+	sql := `
 		SELECT 
 			p.id as proposal_id, 
 			COALESCE(v.is_cancelled, 'false') as is_cancelled,
 			COALESCE(v.addr, '') as addr
 		FROM proposals p 
 		LEFT OUTER JOIN (
-			SELECT * FROM votes where addr = '%s'
+			SELECT * FROM votes where addr = $2
 		) v ON v.proposal_id = p.id 
 		where p.community_id = $1
 		ORDER BY start_time ASC
-	`, addr)
+	`
 	var votingStreak []VotingStreak
-	err := pgxscan.Select(db.Context, db.Conn, &votingStreak, sql, communityId)
+	err := pgxscan.Select(db.Context, db.Conn, &votingStreak, sql, communityId, addr)
 	return votingStreak, err
 }
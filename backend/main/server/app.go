@@ -7,10 +7,13 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/DapperCollectives/CAST/backend/main/middleware"
 	"github.com/DapperCollectives/CAST/backend/main/models"
@@ -44,36 +47,55 @@ type App struct {
 	Router      *mux.Router
 	DB          *shared.Database
 	IpfsClient  *shared.IpfsClient
-	FlowAdapter *shared.FlowAdapter
+	FlowAdapter shared.ChainReader
 
 	TxOptionsAddresses []string
 	Env                string
 	AdminAllowlist     shared.Allowlist
 	CommunityBlocklist shared.Allowlist
 	Config             shared.Config
+	Scheduler          *Scheduler
+	BalancePrefetcher  *BalancePrefetcher
+	Sessions           *shared.SessionTokens
+	NonceTTL           time.Duration
+	RateLimiter        *middleware.RateLimiter
 }
 
 type Strategy interface {
 	TallyVotes(votes []*models.VoteWithBalance, p *models.ProposalResults, proposal *models.Proposal) (models.ProposalResults, error)
 	GetVotes(votes []*models.VoteWithBalance, proposal *models.Proposal) ([]*models.VoteWithBalance, error)
 	GetVoteWeightForBalance(vote *models.VoteWithBalance, proposal *models.Proposal) (float64, error)
-	InitStrategy(f *shared.FlowAdapter, db *shared.Database)
+	InitStrategy(f shared.ChainReader, db *shared.Database)
 	FetchBalance(b *models.Balance, p *models.Proposal) (*models.Balance, error)
 	RequiresSnapshot() bool
 }
 
+// Strategies whose vote weight is not the voter's balance
+// check the proposal's Min_balance against the balance themselves.
+type BalanceValidator interface {
+	ValidateBalance(vote *models.VoteWithBalance, proposal *models.Proposal) error
+}
+
 var strategyMap = map[string]Strategy{
 	"token-weighted-default":        &strategies.TokenWeightedDefault{},
+	"quadratic-token-weighted":      &strategies.QuadraticTokenWeighted{},
 	"total-token-weighted-default":  &strategies.TotalTokenWeightedDefault{},
 	"staked-token-weighted-default": &strategies.StakedTokenWeightedDefault{},
 	"one-address-one-vote":          &strategies.OneAddressOneVote{},
 	"balance-of-nfts":               &strategies.BalanceOfNfts{},
 	"float-nfts":                    &strategies.FloatNFTs{},
 	"custom-script":                 &strategies.CustomScript{},
+	"composite-weighted":            &strategies.CompositeWeighted{},
+	"trait-weighted-nfts":           &strategies.TraitWeightedNfts{},
 }
 
 var customScripts []shared.CustomScript
 
+const defaultNonceTTL = 5 * time.Minute
+
+// How long requests in progress get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 var helpers Helpers
 
 //////////////////////
@@ -153,7 +175,7 @@ func (a *App) Initialize() {
 	// Flow
 
 	// Load custom scripts for strategies
-	scripts, err := ioutil.ReadFile("./main/cadence/scripts/custom/scripts.json")
+	scripts, err := shared.ReadScript(shared.CustomScriptsJSON)
 	if err != nil {
 		log.Error().Err(err).Msg("Error Reading Custom Strategy scripts.")
 	}
@@ -172,11 +194,41 @@ func (a *App) Initialize() {
 	if os.Getenv("FLOW_ENV") == "" {
 		os.Setenv("FLOW_ENV", "emulator")
 	}
-	a.FlowAdapter = shared.NewFlowClient(os.Getenv("FLOW_ENV"), customScriptsMap)
+	// Reads chain state from fixtures instead of an access node
+	if os.Getenv("FLOW_ENV") == "fake" {
+		fixtures, err := shared.LoadChainFixtures(os.Getenv("FLOW_FIXTURES"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error loading chain fixtures.")
+		}
+		a.FlowAdapter = shared.NewFakeChainReader(fixtures, customScriptsMap)
+	} else {
+		a.FlowAdapter = shared.NewFlowClient(os.Getenv("FLOW_ENV"), customScriptsMap)
+	}
+
+	// Sessions
+	a.Sessions, err = shared.SessionTokensFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error configuring sessions.")
+	}
+
+	// Nonces
+	a.NonceTTL = defaultNonceTTL
+	if os.Getenv("NONCE_TTL") != "" {
+		a.NonceTTL, err = time.ParseDuration(os.Getenv("NONCE_TTL"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid NONCE_TTL")
+		}
+	}
 
 	// Snapshot
 	a.TxOptionsAddresses = strings.Fields(os.Getenv("TX_OPTIONS_ADDRS"))
 
+	// Rate limits
+	if err := middleware.ValidateRateLimits(a.Config.RateLimits); err != nil {
+		log.Fatal().Err(err).Msg("Invalid FVT_RATE_LIMITS")
+	}
+	a.RateLimiter = middleware.NewRateLimiter()
+
 	// Router
 	a.Router = mux.NewRouter()
 	a.initializeRoutes()
@@ -187,12 +239,56 @@ func (a *App) Initialize() {
 	a.Router.Use(middleware.UseCors(a.Config))
 
 	helpers.Initialize(a)
+
+	// Scheduler
+	interval := defaultSchedulerInterval
+	if os.Getenv("SCHEDULER_INTERVAL") != "" {
+		interval, err = time.ParseDuration(os.Getenv("SCHEDULER_INTERVAL"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid SCHEDULER_INTERVAL")
+		}
+	}
+	a.Scheduler = NewScheduler(a, interval)
+	// Tests run the scheduler themselves
+	if os.Getenv("APP_ENV") != "TEST" {
+		a.Scheduler.Start()
+	}
+
+	// Balance prefetching
+	workers := defaultPrefetchWorkers
+	if os.Getenv("SNAPSHOT_WORKERS") != "" {
+		workers, err = strconv.Atoi(os.Getenv("SNAPSHOT_WORKERS"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid SNAPSHOT_WORKERS")
+		}
+	}
+	a.BalancePrefetcher = NewBalancePrefetcher(a, workers)
 }
 
+// Run serves the API until the process is interrupted, then lets requests
+// and the scheduler run in progress finish.
 func (a *App) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	addr := fmt.Sprintf(":%s", os.Getenv("API_PORT"))
-	log.Info().Msgf("Starting server on %s ...", addr)
-	log.Fatal().Err(http.ListenAndServe(addr, a.Router)).Msgf("Server at %s crashed!", addr)
+	server := &http.Server{Addr: addr, Handler: a.Router}
+	go func() {
+		log.Info().Msgf("Starting server on %s ...", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msgf("Server at %s crashed!", addr)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info().Msg("Shutting down ...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Error shutting down the server.")
+	}
+	a.Scheduler.Stop()
 }
 
 func (a *App) ConnectDB(username, password, host, port, dbname string) {
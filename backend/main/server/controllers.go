@@ -107,6 +107,41 @@ var (
 		Details:    "There was an error creating the vote.",
 	}
 
+	errCreateDelegation = errorResponse{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  "ERR_1013",
+		Message:    "Error",
+		Details:    "There was an error trying to create your delegation.",
+	}
+
+	errCreateCustomScript = errorResponse{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  "ERR_1014",
+		Message:    "Error",
+		Details:    "There was an error trying to register your custom script.",
+	}
+
+	errInvalidSession = errorResponse{
+		StatusCode: http.StatusUnauthorized,
+		ErrorCode:  "ERR_1015",
+		Message:    "Invalid Session",
+		Details:    "Your session is invalid or has expired, please sign in again.",
+	}
+
+	errTooManyRequests = errorResponse{
+		StatusCode: http.StatusTooManyRequests,
+		ErrorCode:  "ERR_1016",
+		Message:    "Too Many Requests",
+		Details:    "Too many requests, please try again later.",
+	}
+
+	errSnapshotUnavailable = errorResponse{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  "ERR_1017",
+		Message:    "Snapshot Unavailable",
+		Details:    "This proposal's snapshot is no longer available, so votes can't be counted.",
+	}
+
 	nilErr = errorResponse{}
 )
 
@@ -136,6 +171,24 @@ func (a *App) upload(w http.ResponseWriter, r *http.Request) {
 func (a *App) getResultsForProposal(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	proposal, err := helpers.fetchProposal(vars, "proposalId")
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Proposal ID.")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	// closed proposals are tallied once and served from the stored results
+	if *proposal.Computed_status == "closed" {
+		results, err := helpers.finalizeProposalResults(proposal)
+		if err != nil {
+			log.Error().Err(err).Msg("Error finalizing proposal results.")
+			respondWithError(w, errIncompleteRequest)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, results)
+		return
+	}
 
 	votes, err := models.GetAllVotesForProposal(a.DB, proposal.ID, *proposal.Strategy)
 	if err != nil {
@@ -151,13 +204,6 @@ func (a *App) getResultsForProposal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if *proposal.Computed_status == "closed" && !proposal.Achievements_done {
-		if err := models.AddWinningVoteAchievement(a.DB, votes, results); err != nil {
-			log.Error().Err(err).Msg("Error calculating winning votes")
-			respondWithError(w, errIncompleteRequest)
-		}
-	}
-
 	respondWithJSON(w, http.StatusOK, results)
 }
 
@@ -184,6 +230,12 @@ func (a *App) getVotesForProposal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := helpers.setDelegatedWeights(proposal, votesWithWeights); err != nil {
+		log.Error().Err(err).Msg("error setting delegated weights")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
 	response := shared.GetPaginatedResponseWithPayload(votesWithWeights, order)
 	respondWithJSON(w, http.StatusOK, response)
 }
@@ -255,6 +307,45 @@ func (a *App) createVoteForProposal(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, vote)
 }
 
+func (a *App) updateVoteForProposal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	proposal, err := helpers.fetchProposal(vars, "proposalId")
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Proposal ID.")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	vote, errResponse := helpers.updateVote(r, proposal, vars["addr"])
+	if errResponse != nilErr {
+		log.Error().Msg("Error updating vote.")
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, vote)
+}
+
+func (a *App) getVoteHistoryForAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	proposalId, err := strconv.Atoi(vars["proposalId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Proposal ID.")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	history, err := models.GetVoteHistory(a.DB, proposalId, vars["addr"])
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting vote history.")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, history)
+}
+
 // Proposals
 func (a *App) getProposalsForCommunity(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -357,6 +448,10 @@ func (a *App) updateProposal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	// Check that status update is valid
 	// For now we are assuming proposals are creating with
 	// status 'published' and may be cancelled.
@@ -366,24 +461,21 @@ func (a *App) updateProposal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	action := signedAction{models.ActionProposalCancel, resourcePath("proposals", p.ID), payload.Nonce}
 	if payload.Voucher != nil {
-		if err := helpers.validateUserWithRoleViaVoucher(
-			payload.Signing_addr,
-			payload.Voucher,
-			p.Community_id,
-			"author"); err != nil {
-			log.Error().Err(err).Msg("Error validating user with role via voucher")
+		if err := helpers.validateUserViaVoucher(payload.Signing_addr, payload.Voucher, action); err != nil {
+			log.Error().Err(err).Msg("Error validating user via voucher")
 			respondWithError(w, errForbidden)
 			return
 		}
 	} else {
-		if err := helpers.validateUserWithRole(
+		if err := helpers.validateUserOrSession(
+			payload.Session_addr,
 			payload.Signing_addr,
 			payload.Timestamp,
 			payload.Composite_signatures,
-			p.Community_id,
-			"author"); err != nil {
-			log.Error().Err(err).Msg("Error validating user with role")
+			action); err != nil {
+			log.Error().Err(err).Msg("Error validating user")
 			respondWithError(w, errForbidden)
 			return
 		}
@@ -548,6 +640,10 @@ func (a *App) updateCommunity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	//Validate Contract Thresholds
 	if payload.Strategies != nil {
 		err = validateContractThreshold(*payload.Strategies)
@@ -691,13 +787,17 @@ func (a *App) createListForCommunity(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := models.ListPayload{}
-	payload.Community_id = communityId
-
 	if err := validatePayload(r.Body, &payload); err != nil {
 		log.Error().Err(err).Msg("Error validating payload")
 		respondWithError(w, errIncompleteRequest)
 		return
 	}
+	// permissions are checked for the route's community
+	payload.Community_id = communityId
+
+	if !useSession(w, r, &payload) {
+		return
+	}
 
 	l, httpStatus, err := helpers.createListForCommunity(payload)
 	if err != nil {
@@ -726,6 +826,10 @@ func (a *App) addAddressesToList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	httpStatus, err := helpers.updateAddressesInList(id, payload, "add")
 	if err != nil {
 		log.Error().Err(err).Msg("Error adding addresses to list")
@@ -753,6 +857,10 @@ func (a *App) removeAddressesFromList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	httpStatus, err := helpers.updateAddressesInList(id, payload, "remove")
 	if err != nil {
 		log.Error().Err(err).Msg("Error removing addresses from list")
@@ -826,6 +934,10 @@ func (a *App) createCommunityUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	httpStatus, err := helpers.createCommunityUser(payload)
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating community user")
@@ -874,7 +986,7 @@ func (a *App) getCommunityUsersByType(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userType := vars["userType"]
-	if !models.EnsureValidRole(userType) {
+	if !models.IsRoleForCommunity(a.DB, communityId, userType) {
 		log.Error().Err(err).Msg("Invalid User Type")
 		respondWithError(w, errIncompleteRequest)
 		return
@@ -968,6 +1080,10 @@ func (a *App) removeUserRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !useSession(w, r, &payload) {
+		return
+	}
+
 	_, err = helpers.removeUserRole(payload)
 	if err != nil {
 		log.Error().Err(err).Msg("Error removing user role")
@@ -978,10 +1094,363 @@ func (a *App) removeUserRole(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, "OK")
 }
 
+func (a *App) getCommunityRoles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	roles, err := models.GetRolesForCommunity(a.DB, communityId)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting community roles")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+func (a *App) createCommunityRole(w http.ResponseWriter, r *http.Request) {
+	a.saveCommunityRole(w, r, false)
+}
+
+func (a *App) updateCommunityRole(w http.ResponseWriter, r *http.Request) {
+	a.saveCommunityRole(w, r, true)
+}
+
+func (a *App) saveCommunityRole(w http.ResponseWriter, r *http.Request, update bool) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	payload := models.CommunityRolePayload{}
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+	payload.Community_id = communityId
+	if update {
+		payload.Name = vars["name"]
+	}
+
+	if !useSession(w, r, &payload) {
+		return
+	}
+
+	role, httpStatus, err := helpers.saveCommunityRole(payload, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Error saving community role")
+		errResponse := errIncompleteRequest
+		errResponse.StatusCode = httpStatus
+		respondWithError(w, errResponse)
+		return
+	}
+
+	if update {
+		respondWithJSON(w, http.StatusOK, role)
+	} else {
+		respondWithJSON(w, http.StatusCreated, role)
+	}
+}
+
+func (a *App) removeCommunityRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	payload := models.CommunityRolePayload{}
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+	payload.Community_id = communityId
+	payload.Name = vars["name"]
+
+	if !useSession(w, r, &payload) {
+		return
+	}
+
+	httpStatus, err := helpers.removeCommunityRole(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Error removing community role")
+		errResponse := errIncompleteRequest
+		errResponse.StatusCode = httpStatus
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "OK")
+}
+
+func (a *App) getDelegationsForCommunity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	pageParams := getPageParams(*r, 100)
+
+	delegations, totalRecords, err := models.GetDelegationsForCommunity(a.DB, communityId, pageParams)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting community delegations")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	pageParams.TotalRecords = totalRecords
+
+	response := shared.GetPaginatedResponseWithPayload(delegations, pageParams)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *App) getDelegationForAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	d := models.Delegation{Community_id: communityId, Delegator_addr: vars["addr"]}
+	if err := d.GetActiveDelegation(a.DB); err != nil {
+		log.Error().Err(err).Msg("Error getting delegation")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, d)
+}
+
+func (a *App) createDelegation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	payload := models.DelegationPayload{}
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+	payload.Community_id = communityId
+
+	d, httpStatus, err := helpers.createDelegation(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating delegation")
+		errResponse := errCreateDelegation
+		errResponse.StatusCode = httpStatus
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, d)
+}
+
+func (a *App) revokeDelegation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	payload := models.DelegationPayload{}
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+	payload.Community_id = communityId
+	payload.Delegator_addr = vars["addr"]
+
+	d, _, err := helpers.revokeDelegation(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Error revoking delegation")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, d)
+}
+
+func (a *App) getCustomScriptsForCommunity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	scripts, err := models.GetCustomScriptsForCommunity(a.DB, communityId)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting custom scripts for community")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scripts)
+}
+
+// Checks and dry runs the script, then stores it unless ?dryRun=true is set.
+func (a *App) createCustomScript(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	communityId, err := strconv.Atoi(vars["communityId"])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid Community ID")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	payload := models.CustomScriptPayload{}
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	if !useSession(w, r, &payload) {
+		return
+	}
+	payload.Community_id = communityId
+	dryRun := r.FormValue("dryRun") == "true"
+
+	script, nftIds, httpStatus, err := helpers.createCustomScript(payload, dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating custom script")
+		errResponse := errCreateCustomScript
+		errResponse.StatusCode = httpStatus
+		if httpStatus == http.StatusBadRequest {
+			errResponse.Details = err.Error()
+		}
+		respondWithError(w, errResponse)
+		return
+	}
+
+	if dryRun {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"nftIds": nftIds})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, script)
+}
+
+// Issues a nonce for a signed request, bound to its action and resource.
+func (a *App) createNonce(w http.ResponseWriter, r *http.Request) {
+	var payload models.Nonce
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	nonce, errResponse := helpers.createNonce(payload)
+	if errResponse != nilErr {
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, nonce)
+}
+
+// Starts a session from a signed sign in message.
+func (a *App) signIn(w http.ResponseWriter, r *http.Request) {
+	var payload models.SignInPayload
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	session, errResponse := helpers.signIn(payload)
+	if errResponse != nilErr {
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, session)
+}
+
+func (a *App) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var payload models.RefreshSessionPayload
+	if err := validatePayload(r.Body, &payload); err != nil {
+		log.Error().Err(err).Msg("Error validating payload")
+		respondWithError(w, errIncompleteRequest)
+		return
+	}
+
+	session, errResponse := helpers.refreshSession(payload)
+	if errResponse != nilErr {
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, session)
+}
+
+// Ends the session of the bearer token, or of the refresh token in the body.
+func (a *App) revokeSession(w http.ResponseWriter, r *http.Request) {
+	var payload models.RefreshSessionPayload
+	if r.ContentLength != 0 {
+		if err := validatePayload(r.Body, &payload); err != nil {
+			log.Error().Err(err).Msg("Error validating payload")
+			respondWithError(w, errIncompleteRequest)
+			return
+		}
+	}
+
+	if errResponse := helpers.revokeSession(r, payload); errResponse != nilErr {
+		respondWithError(w, errResponse)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, "OK")
+}
+
 /////////////
 // HELPERS //
 /////////////
 
+type sessionPayload interface {
+	UseSession(addr string)
+}
+
+// Makes the session of the request's session token, if it carries one, the
+// payload's signer. Responds with an error and returns false if the token is
+// invalid.
+func useSession(w http.ResponseWriter, r *http.Request, payload sessionPayload) bool {
+	addr, err := helpers.sessionAddr(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid session token")
+		respondWithError(w, errInvalidSession)
+		return false
+	}
+	payload.UseSession(addr)
+	return true
+}
+
 func respondWithError(w http.ResponseWriter, err errorResponse) {
 	respondWithJSON(w, err.StatusCode, map[string]string{
 		"statusCode": strconv.Itoa(err.StatusCode),
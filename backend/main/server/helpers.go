@@ -1,7 +1,6 @@
 package server
 
 import (
-	"context"
 	"encoding/hex"
 	"errors"
 	"flag"
@@ -12,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DapperCollectives/CAST/backend/main/middleware"
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
 	"github.com/go-playground/validator/v10"
@@ -44,12 +44,220 @@ func (h *Helpers) useStrategyTally(
 		return models.ProposalResults{}, errors.New("Strategy not found.")
 	}
 
+	delegated, err := h.getDelegatedVotes(p, s)
+	if err != nil {
+		return models.ProposalResults{}, err
+	}
+	v = append(append([]*models.VoteWithBalance{}, v...), delegated...)
+
 	proposalInitialized := models.NewProposalResults(p.ID, p.Choices)
+
+	// ranked choice and approval proposals use the strategy's vote weights
+	// and run their own tally over each ballot's choices
+	if p.IsRankedChoice() || p.IsApproval() {
+		votesWithWeights, err := s.GetVotes(v, &p)
+		if err != nil {
+			return models.ProposalResults{}, err
+		}
+		if p.IsRankedChoice() {
+			proposalInitialized.TallyRankedChoice(votesWithWeights, &p)
+		} else {
+			proposalInitialized.TallyApproval(votesWithWeights, &p)
+		}
+		proposalInitialized.ComputeOutcome(&p)
+		return *proposalInitialized, nil
+	}
+
 	results, err := s.TallyVotes(v, proposalInitialized, &p)
 	if err != nil {
 		return models.ProposalResults{}, err
 	}
 
+	results.ComputeOutcome(&p)
+
+	return results, nil
+}
+
+// Delegated votes are counted with the delegator's balance at the proposal's
+// snapshot, as fetched by fetchDelegatorBalances. Reads never call the chain,
+// delegators without a stored balance are left out until it is fetched, as are
+// delegators whose balance is too low to vote on the proposal themselves.
+// NFT strategies don't support delegation.
+func (h *Helpers) getDelegatedVotes(p models.Proposal, s Strategy) ([]*models.VoteWithBalance, error) {
+	if models.IsNFTStrategy(*p.Strategy) {
+		return []*models.VoteWithBalance{}, nil
+	}
+
+	votes, err := models.GetDelegatedVotesForProposal(h.A.DB, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var delegated []*models.VoteWithBalance
+	for _, vote := range votes {
+		if s.RequiresSnapshot() && vote.PrimaryAccountBalance == nil {
+			continue
+		}
+
+		weight, err := s.GetVoteWeightForBalance(vote, &p)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error getting vote weight for delegator %s.", *vote.Delegator_addr)
+			continue
+		}
+		if err := validateVoteBalance(p, s, vote, weight); err != nil {
+			continue
+		}
+
+		delegated = append(delegated, vote)
+	}
+
+	return delegated, nil
+}
+
+// Fetches the snapshot balance of each delegator on the proposal that isn't
+// stored yet, so tallies and vote lists only read stored balances. Runs when
+// the proposal opens and closes, and when a delegation is created.
+func (h *Helpers) fetchDelegatorBalances(p models.Proposal) error {
+	if models.IsNFTStrategy(*p.Strategy) || p.Block_height == nil {
+		return nil
+	}
+
+	s := h.initStrategy(*p.Strategy)
+	if s == nil {
+		return errors.New("Strategy not found.")
+	}
+	if !s.RequiresSnapshot() {
+		return nil
+	}
+
+	addrs, err := models.GetDelegatorsWithoutBalances(h.A.DB, p.ID)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, addr := range addrs {
+		emptyBalance := &models.Balance{
+			Addr:        addr,
+			Proposal_id: p.ID,
+			BlockHeight: *p.Block_height,
+			Source:      p.Balance_source,
+		}
+		if _, err := s.FetchBalance(emptyBalance, &p); err != nil {
+			log.Error().Err(err).Msgf("Error fetching balance for delegator %s.", addr)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not fetch %d of %d delegator balances for proposal %d", failed, len(addrs), p.ID)
+	}
+
+	return nil
+}
+
+// Checks the balance behind a vote is enough to vote on the proposal.
+func validateVoteBalance(p models.Proposal, s Strategy, v *models.VoteWithBalance, weight float64) error {
+	if bv, ok := s.(BalanceValidator); ok {
+		return bv.ValidateBalance(v, &p)
+	}
+	return p.ValidateBalance(weight)
+}
+
+// Sets the weight delegated to each voter, kept apart from the weight
+// of the voter's own balance.
+func (h *Helpers) setDelegatedWeights(p models.Proposal, votes []*models.VoteWithBalance) error {
+	s := h.initStrategy(*p.Strategy)
+	if s == nil {
+		return errors.New("Strategy not found.")
+	}
+
+	delegated, err := h.getDelegatedVotes(p, s)
+	if err != nil {
+		return err
+	}
+
+	delegatedWithWeights, err := s.GetVotes(delegated, &p)
+	if err != nil {
+		return err
+	}
+
+	weights := make(map[string]float64)
+	for _, vote := range delegatedWithWeights {
+		if vote.Weight != nil {
+			weights[vote.Addr] += *vote.Weight
+		}
+	}
+
+	for _, vote := range votes {
+		if weight, ok := weights[vote.Addr]; ok {
+			vote.Delegated_weight = &weight
+		}
+	}
+
+	return nil
+}
+
+// Tallies a closed proposal a single time, storing the results and
+// pinning them to IPFS. Once stored, results are served from the database.
+// Winning vote achievements are added the first time results are finalized.
+func (h *Helpers) finalizeProposalResults(p models.Proposal) (models.ProposalResults, error) {
+	results := models.ProposalResults{Proposal_id: p.ID}
+	err := results.GetLatestProposalResultsById(h.A.DB)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return models.ProposalResults{}, err
+	} else if err != nil {
+		results, err = h.storeProposalResults(p)
+		if err != nil {
+			return models.ProposalResults{}, err
+		}
+	}
+
+	if !p.Achievements_done {
+		votes, err := models.GetAllVotesForProposal(h.A.DB, p.ID, *p.Strategy)
+		if err != nil {
+			return models.ProposalResults{}, err
+		}
+		if err := models.AddWinningVoteAchievement(h.A.DB, votes, p, results); err != nil {
+			return models.ProposalResults{}, err
+		}
+	}
+	results.Achievements_done = true
+
+	return results, nil
+}
+
+func (h *Helpers) storeProposalResults(p models.Proposal) (models.ProposalResults, error) {
+	votes, err := models.GetAllVotesForProposal(h.A.DB, p.ID, *p.Strategy)
+	if err != nil {
+		return models.ProposalResults{}, err
+	}
+
+	results, err := h.useStrategyTally(p, votes)
+	if err != nil {
+		return models.ProposalResults{}, err
+	}
+
+	results.Updated_at = time.Now().UTC()
+	results.Cid, err = h.pinJSONToIpfs(results)
+	if err != nil {
+		return models.ProposalResults{}, err
+	}
+
+	created, err := results.CreateProposalResults(h.A.DB)
+	if err != nil {
+		return models.ProposalResults{}, err
+	}
+
+	// results were stored by another request first, serve those instead
+	if !created {
+		stored := models.ProposalResults{Proposal_id: p.ID}
+		if err := stored.GetLatestProposalResultsById(h.A.DB); err != nil {
+			return models.ProposalResults{}, err
+		}
+		return stored, nil
+	}
+
 	return results, nil
 }
 
@@ -68,6 +276,14 @@ func (h *Helpers) useStrategyGetVotes(
 		return nil, err
 	}
 
+	if p.IsSplit() {
+		for _, vote := range votesWithWeights {
+			if vote.Weight != nil {
+				vote.Weights = vote.Allocate(*vote.Weight)
+			}
+		}
+	}
+
 	return votesWithWeights, nil
 }
 
@@ -97,12 +313,19 @@ func (h *Helpers) useStrategyFetchBalance(
 	emptyBalance := &models.Balance{
 		Addr:        v.Addr,
 		Proposal_id: p.ID,
+		Source:      p.Balance_source,
 	}
 
 	if p.Block_height != nil {
 		emptyBalance.BlockHeight = *p.Block_height
 	}
 
+	// a zero block height reads the latest block, which isn't a snapshot
+	if s.RequiresSnapshot() && emptyBalance.BlockHeight == 0 {
+		log.Error().Msgf("Proposal %d has no snapshot block height.", p.ID)
+		return models.VoteWithBalance{}, errSnapshotUnavailable
+	}
+
 	c := models.Community{ID: p.Community_id}
 	if err := c.GetCommunityByProposalId(h.A.DB, p.ID); err != nil {
 		return models.VoteWithBalance{}, errGetCommunity
@@ -113,7 +336,14 @@ func (h *Helpers) useStrategyFetchBalance(
 		return models.VoteWithBalance{}, errStrategyNotFound
 	}
 
-	balance, err := s.FetchBalance(emptyBalance, &p)
+	balance, err := h.prefetchedBalance(emptyBalance, p)
+	if err != nil {
+		balance, err = s.FetchBalance(emptyBalance, &p)
+	}
+	if errors.Is(err, shared.ErrSnapshotUnavailable) {
+		log.Error().Err(err).Msgf("Can't read the snapshot for %v.", v.Addr)
+		return models.VoteWithBalance{}, errSnapshotUnavailable
+	}
 	if err != nil {
 		log.Error().Err(err).Msgf("User does not have the required balance %v.", v.Addr)
 		errResponse := errInsufficientBalance
@@ -126,11 +356,25 @@ func (h *Helpers) useStrategyFetchBalance(
 		PrimaryAccountBalance:   &balance.PrimaryAccountBalance,
 		SecondaryAccountBalance: &balance.SecondaryAccountBalance,
 		StakingBalance:          &balance.StakingBalance,
+		Components:              balance.Components,
+		FlowBalance:             balance.FlowBalance,
 	}
 
 	return vb, nilErr
 }
 
+// Returns the voter's balance if it was stored for the proposal's source
+// when the proposal was created, so the vote doesn't fetch it again.
+func (h *Helpers) prefetchedBalance(b *models.Balance, p models.Proposal) (*models.Balance, error) {
+	if !p.HasPrefetchedBalances() {
+		return nil, errors.New("balances weren't prefetched")
+	}
+	if err := b.GetBalanceByAddressAndBlockHeight(h.A.DB); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 func (h *Helpers) fetchProposal(vars map[string]string, query string) (models.Proposal, error) {
 	proposalId, err := strconv.Atoi(vars[query])
 	if err != nil {
@@ -307,6 +551,7 @@ func (h *Helpers) createVote(r *http.Request, p models.Proposal) (*models.VoteWi
 	}
 
 	v.Proposal_id = p.ID
+	setPrimaryChoice(&v, p)
 
 	// validate user hasn't already voted
 	existingVote := models.Vote{Proposal_id: v.Proposal_id, Addr: v.Addr}
@@ -324,10 +569,20 @@ func (h *Helpers) createVote(r *http.Request, p models.Proposal) (*models.VoteWi
 		}
 	}
 
-	if errResponse := h.validateVote(p, v); errResponse != nilErr {
+	// turn away signers over budget before verifying the signature, but only
+	// charge them once it's verified
+	if !middleware.SignerHasBudget(r, v.Addr) {
+		return nil, errTooManyRequests
+	}
+
+	if errResponse := h.validateVote(p, &v); errResponse != nilErr {
 		return nil, errResponse
 	}
 
+	if !middleware.AllowSigner(r, v.Addr) {
+		return nil, errTooManyRequests
+	}
+
 	v.Proposal_id = p.ID
 
 	s := h.initStrategy(*p.Strategy)
@@ -364,20 +619,15 @@ func (h *Helpers) insertVote(v models.VoteWithBalance, p models.Proposal) errorR
 		return errStrategyNotFound
 	}
 
-	if err = p.ValidateBalance(weight); err != nil {
+	if err := validateVoteBalance(p, h.initStrategy(*p.Strategy), &v, weight); err != nil {
 		log.Error().Err(err).Msg("Account balance is too low to vote on this proposal.")
 		errResponse := errInsufficientBalance
 		errResponse.Details = fmt.Sprintf(errResponse.Details, *strategy.Threshold, *strategy.Contract.Name)
 		return errResponse
 	}
 
-	// Include voucher in vote data when pinning
-	ipfsVote := map[string]interface{}{
-		"vote": v,
-	}
-	v.Cid, err = h.pinJSONToIpfs(ipfsVote)
-	if err != nil {
-		log.Error().Err(err).Msg("Error pinning proposal to IPFS.")
+	if err := h.pinVote(&v, p, weight); err != nil {
+		log.Error().Err(err).Msg("Error pinning vote to IPFS.")
 		return errCreateVote
 	}
 
@@ -390,7 +640,117 @@ func (h *Helpers) insertVote(v models.VoteWithBalance, p models.Proposal) errorR
 	return nilErr
 }
 
-func (h *Helpers) validateVote(p models.Proposal, v models.Vote) errorResponse {
+// Recasts an existing vote with a newly signed ballot while the proposal is live.
+// The vote keeps the weight of the balance fetched when it was first cast.
+func (h *Helpers) updateVote(r *http.Request, p models.Proposal, addr string) (*models.VoteWithBalance, errorResponse) {
+	var v models.Vote
+	if err := validatePayload(r.Body, &v); err != nil {
+		log.Error().Err(err).Msg("Invalid request payload.")
+		return nil, errIncompleteRequest
+	}
+
+	if v.Addr != addr {
+		log.Error().Msgf("Vote address %s does not match %s.", v.Addr, addr)
+		return nil, errIncompleteRequest
+	}
+
+	v.Proposal_id = p.ID
+	setPrimaryChoice(&v, p)
+
+	existing, err := h.fetchVote(addr, p.ID)
+	if err != nil {
+		log.Error().Err(err).Msgf("No vote to update for address %s.", addr)
+		return nil, errIncompleteRequest
+	}
+
+	// check that proposal is live
+	if os.Getenv("APP_ENV") != "DEV" {
+		if !p.IsLive() {
+			return nil, errInactiveProposal
+		}
+	}
+
+	// turn away signers over budget before verifying the signature, but only
+	// charge them once it's verified
+	if !middleware.SignerHasBudget(r, v.Addr) {
+		return nil, errTooManyRequests
+	}
+
+	if errResponse := h.validateVote(p, &v); errResponse != nilErr {
+		return nil, errResponse
+	}
+
+	if !middleware.AllowSigner(r, v.Addr) {
+		return nil, errTooManyRequests
+	}
+
+	// a previously signed ballot can't be replayed, checked against the
+	// message validateVote derived, which for vouchers is the signed transaction
+	cast, err := models.IsBallotCast(h.A.DB, p.ID, addr, v.Message)
+	if err != nil {
+		return nil, errIncompleteRequest
+	} else if cast {
+		log.Error().Msgf("Ballot for address %s was already cast.", addr)
+		return nil, errIncompleteRequest
+	}
+
+	vb := *existing
+	vb.Choice = v.Choice
+	vb.Choices = v.Choices
+	vb.Allocations = v.Allocations
+	vb.Composite_signatures = v.Composite_signatures
+	vb.Message = v.Message
+	vb.Voucher = v.Voucher
+
+	weight, err := h.useStrategyGetVoteWeight(p, &vb)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error getting vote weight for address %s.", v.Addr)
+		return nil, errIncompleteRequest
+	}
+
+	if err := h.pinVote(&vb, p, weight); err != nil {
+		log.Error().Err(err).Msg("Error pinning vote to IPFS.")
+		return nil, errCreateVote
+	}
+
+	if err := vb.UpdateVote(h.A.DB); err != nil {
+		log.Error().Err(err).Msgf("Error updating vote for address %s.", v.Addr)
+		return nil, errCreateVote
+	}
+
+	return &vb, nilErr
+}
+
+// The first choice on a ranked or approval ballot is recorded as the vote's choice,
+// split ballots record the choice given the largest share.
+func setPrimaryChoice(v *models.Vote, p models.Proposal) {
+	if (p.IsRankedChoice() || p.IsApproval()) && len(v.Choices) > 0 {
+		v.Choice = v.Choices[0]
+	} else if p.IsSplit() && len(v.Allocations) > 0 {
+		v.Choice = v.LargestAllocation(p)
+	}
+}
+
+func (h *Helpers) pinVote(v *models.VoteWithBalance, p models.Proposal, weight float64) error {
+	// Include the weight given to each choice when pinning split ballots
+	if p.IsSplit() {
+		v.Weight = &weight
+		v.Weights = v.Allocate(weight)
+	}
+
+	// Include voucher in vote data when pinning
+	ipfsVote := map[string]interface{}{
+		"vote": v,
+	}
+
+	var err error
+	v.Cid, err = h.pinJSONToIpfs(ipfsVote)
+	return err
+}
+
+// Validates the signed ballot. For vouchers the vote's message and composite
+// signatures are replaced by the ones derived from the signed transaction.
+func (h *Helpers) validateVote(p models.Proposal, v *models.Vote) errorResponse {
 
 	// validate the user is not on community's blocklist
 	if err := h.validateBlocklist(v.Addr, p.Community_id); err != nil {
@@ -419,6 +779,10 @@ func (h *Helpers) validateVote(p models.Proposal, v models.Vote) errorResponse {
 			return errIncompleteRequest
 		}
 
+		if len(voucher.Arguments) == 0 {
+			log.Error().Msg("Voucher is missing its vote message argument.")
+			return errIncompleteRequest
+		}
 		message := voucher.Arguments[0]["value"]
 
 		messageBytes, err := hex.DecodeString(message)
@@ -429,11 +793,17 @@ func (h *Helpers) validateVote(p models.Proposal, v models.Vote) errorResponse {
 
 		// validate proper message format
 		//<proposalId>:<choice>:<timestamp>
-		if err := models.ValidateVoteMessage(string(messageBytes), p); err != nil {
+		ballot, err := models.ValidateVoteMessage(string(messageBytes), p)
+		if err != nil {
 			log.Error().Err(err)
 			return errIncompleteRequest
 		}
 
+		if err := v.MatchesBallot(ballot, p); err != nil {
+			log.Error().Err(err).Msgf("Vote from %s does not match the signed ballot.", v.Addr)
+			return errIncompleteRequest
+		}
+
 		// re-build message & composite signatures for validation
 		// set v.Message as the encoded message, rather than the colon(:) delimited message above.
 		// we can do this because we can always recover the tx arguments that make up the
@@ -446,11 +816,17 @@ func (h *Helpers) validateVote(p models.Proposal, v models.Vote) errorResponse {
 	} else {
 		// validate proper message format
 		// hex decode before validating
-		if err := models.ValidateVoteMessage(v.Message, p); err != nil {
+		ballot, err := models.ValidateVoteMessage(v.Message, p)
+		if err != nil {
 			log.Error().Err(err)
 			return errIncompleteRequest
 		}
 
+		if err := v.MatchesBallot(ballot, p); err != nil {
+			log.Error().Err(err).Msgf("Vote from %s does not match the signed ballot.", v.Addr)
+			return errIncompleteRequest
+		}
+
 		if err := h.validateUserSignature(v.Addr, v.Message, v.Composite_signatures); err != nil {
 			return errIncompleteRequest
 		}
@@ -527,12 +903,21 @@ func (h *Helpers) createProposal(p models.Proposal) (models.Proposal, errorRespo
 		return models.Proposal{}, errStrategyNotFound
 	}
 
+	if p.Voting_type == nil {
+		votingType := models.SingleChoice
+		p.Voting_type = &votingType
+	} else if !models.EnsureValidVotingType(*p.Voting_type) {
+		log.Error().Msgf("Invalid voting type: %s", *p.Voting_type)
+		return models.Proposal{}, errIncompleteRequest
+	}
+
+	action := signedAction{models.ActionProposalCreate, resourcePath("communities", p.Community_id, "proposals"), p.Nonce}
 	if p.Voucher != nil {
-		if err := h.validateUserViaVoucher(p.Creator_addr, p.Voucher); err != nil {
+		if err := h.validateUserViaVoucher(p.Creator_addr, p.Voucher, action); err != nil {
 			return models.Proposal{}, errForbidden
 		}
 	} else {
-		if err := h.validateUser(p.Creator_addr, p.Timestamp, p.Composite_signatures); err != nil {
+		if err := h.validateUser(p.Creator_addr, p.Timestamp, p.Composite_signatures, action); err != nil {
 			return models.Proposal{}, errForbidden
 		}
 	}
@@ -555,13 +940,45 @@ func (h *Helpers) createProposal(p models.Proposal) (models.Proposal, errorRespo
 	if p.Max_weight == nil {
 		p.Max_weight = strategy.Contract.MaxWeight
 	}
+	p.Decimals = strategy.Contract.Decimals
+	p.Balance_buckets = strategy.Contract.Buckets
+	source := models.BalanceSource(*p.Strategy, strategy.Contract)
+	p.Balance_source = &source
 
-	header, err := h.A.FlowAdapter.LiveClient.GetLatestBlockHeader(context.Background(), true)
+	// Set Quorum/Pass Threshold to community defaults if not provided
+	if p.Quorum == nil {
+		p.Quorum = community.Quorum
+		p.Quorum_type = community.Quorum_type
+	}
+	if p.Pass_threshold == nil {
+		p.Pass_threshold = community.Pass_threshold
+	}
+	if err := p.ValidateOutcomeRules(); err != nil {
+		log.Error().Err(err).Msg("Invalid quorum or pass threshold.")
+		return models.Proposal{}, errIncompleteRequest
+	}
+
+	blockHeight, err := h.A.FlowAdapter.GetCurrentBlockHeight()
 	if err != nil {
-		log.Error().Err(err).Msg("Couldn't get block header")
+		log.Error().Err(err).Msg("Couldn't get block height")
 		return models.Proposal{}, errIncompleteRequest
 	}
-	p.Block_height = &header.Height
+	height := uint64(blockHeight)
+	p.Block_height = &height
+
+	// percentage quorums are measured against the supply at the snapshot
+	if p.HasPercentageQuorum() {
+		if strategy.Contract.Name == nil || strategy.Contract.Addr == nil {
+			log.Error().Msg("Percentage quorum requires a strategy with a token contract.")
+			return models.Proposal{}, errIncompleteRequest
+		}
+		totalSupply, err := h.A.FlowAdapter.GetTotalSupplyAtBlockHeight(&strategy.Contract, *p.Block_height)
+		if err != nil {
+			log.Error().Err(err).Msg("Couldn't get total supply for percentage quorum")
+			return models.Proposal{}, errIncompleteRequest
+		}
+		p.Total_supply = &totalSupply
+	}
 
 	if err := h.enforceCommunityRestrictions(community, p, strategy); err != nil {
 		return models.Proposal{}, errIncompleteRequest
@@ -590,6 +1007,13 @@ func (h *Helpers) createProposal(p models.Proposal) (models.Proposal, errorRespo
 		return models.Proposal{}, errIncompleteRequest
 	}
 
+	addresses, err := h.A.BalancePrefetcher.Addresses(p, strategy)
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't get addresses to prefetch balances for.")
+	} else if len(addresses) > 0 {
+		h.A.BalancePrefetcher.Prefetch(p, addresses)
+	}
+
 	return p, nilErr
 }
 
@@ -616,8 +1040,8 @@ func (h *Helpers) enforceCommunityRestrictions(
 ) error {
 
 	if *c.Only_authors_to_submit {
-		if err := models.EnsureRoleForCommunity(h.A.DB, p.Creator_addr, c.ID, "author"); err != nil {
-			errMsg := fmt.Sprintf("Account %s is not an author for community %d.", p.Creator_addr, p.Community_id)
+		if err := models.EnsurePermissionForCommunity(h.A.DB, p.Creator_addr, c.ID, models.ActionProposalCreate); err != nil {
+			errMsg := fmt.Sprintf("Account %s can't submit proposals to community %d.", p.Creator_addr, p.Community_id)
 			log.Error().Err(err).Msg(errMsg)
 			return errors.New(errMsg)
 		}
@@ -656,13 +1080,14 @@ func (h *Helpers) enforceCommunityRestrictions(
 func (h *Helpers) createCommunity(payload models.CreateCommunityRequestPayload) (models.Community, error) {
 	c := payload.Community
 
+	action := signedAction{models.ActionCommunityCreate, "communities", c.Nonce}
 	if c.Voucher != nil {
 		log.Info().Msgf("validate user via voucher %v \n", c.Voucher)
-		if err := h.validateUserViaVoucher(c.Creator_addr, c.Voucher); err != nil {
+		if err := h.validateUserViaVoucher(c.Creator_addr, c.Voucher, action); err != nil {
 			return models.Community{}, err
 		}
 	} else {
-		if err := h.validateUser(c.Creator_addr, c.Timestamp, c.Composite_signatures); err != nil {
+		if err := h.validateUser(c.Creator_addr, c.Timestamp, c.Composite_signatures, action); err != nil {
 			return models.Community{}, err
 		}
 	}
@@ -731,20 +1156,20 @@ func (h *Helpers) updateCommunity(id int, payload models.UpdateCommunityRequestP
 		return models.Community{}, err
 	}
 
-	// validate is community creator
-	// TODO: update to validating address is admin
-	if err := c.CanUpdateCommunity(h.A.DB, payload.Signing_addr); err != nil {
-		log.Error().Err(err)
-		return models.Community{}, err
-	}
-
+	action := signedAction{models.ActionCommunityUpdate, resourcePath("communities", id), payload.Nonce}
 	if payload.Voucher != nil {
-		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher); err != nil {
+		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher, action); err != nil {
 			log.Error().Err(err)
 			return models.Community{}, err
 		}
 	} else {
-		if err := h.validateUser(payload.Signing_addr, payload.Timestamp, payload.Composite_signatures); err != nil {
+		if err := h.validateUserOrSession(
+			payload.Session_addr,
+			payload.Signing_addr,
+			payload.Timestamp,
+			payload.Composite_signatures,
+			action,
+		); err != nil {
 			log.Error().Err(err)
 			return models.Community{}, err
 		}
@@ -764,13 +1189,20 @@ func (h *Helpers) updateCommunity(id int, payload models.UpdateCommunityRequestP
 }
 
 func (h *Helpers) removeUserRole(payload models.CommunityUserPayload) (int, error) {
+	action := signedAction{models.ActionRoleRemove, resourcePath("communities", payload.Community_id, "users", payload.Addr, payload.User_type), payload.Nonce}
 	if payload.Voucher != nil {
-		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher); err != nil {
+		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher, action); err != nil {
 			log.Error().Err(err)
 			return http.StatusForbidden, err
 		}
 	} else {
-		if err := h.validateUser(payload.Signing_addr, payload.Timestamp, payload.Composite_signatures); err != nil {
+		if err := h.validateUserOrSession(
+			payload.Session_addr,
+			payload.Signing_addr,
+			payload.Timestamp,
+			payload.Composite_signatures,
+			action,
+		); err != nil {
 			log.Error().Err(err)
 			return http.StatusForbidden, err
 		}
@@ -800,15 +1232,15 @@ func (h *Helpers) removeUserRole(payload models.CommunityUserPayload) (int, erro
 
 	u := payload.CommunityUser
 
-	if payload.User_type == "admin" {
-		// validate signer is admin
-		var adminUser = models.CommunityUser{Addr: payload.Signing_addr, Community_id: payload.Community_id, User_type: "admin"}
-		if err := adminUser.GetCommunityUser(h.A.DB); err != nil {
-			USER_MUST_BE_ADMIN_ERR := errors.New("User must be community admin.")
-			log.Error().Err(err).Msg("Database error.")
-			log.Error().Err(USER_MUST_BE_ADMIN_ERR)
-			return http.StatusForbidden, USER_MUST_BE_ADMIN_ERR
+	// anyone can give up their own roles, removing someone else's takes permission
+	if payload.User_type != "member" && payload.Addr != payload.Signing_addr {
+		if err := models.EnsurePermissionForCommunity(h.A.DB, payload.Signing_addr, payload.Community_id, models.ActionRoleRemove); err != nil {
+			log.Error().Err(err).Msg("User can't remove roles.")
+			return http.StatusForbidden, err
 		}
+	}
+
+	if payload.User_type == "admin" {
 		// If the admin role is being removed, remove author role as well
 		author := models.CommunityUser{Addr: u.Addr, Community_id: u.Community_id, User_type: "author"}
 		if err := author.Remove(h.A.DB); err != nil {
@@ -835,6 +1267,11 @@ func (h *Helpers) createCommunityUser(payload models.CommunityUserPayload) (int,
 		log.Error().Err(vErr).Msg(errMsg)
 		return http.StatusBadRequest, errors.New(errMsg)
 	}
+	if !models.IsRoleForCommunity(h.A.DB, payload.Community_id, payload.User_type) {
+		errMsg := fmt.Sprintf("Community %d has no %s role.", payload.Community_id, payload.User_type)
+		log.Error().Msg(errMsg)
+		return http.StatusBadRequest, errors.New(errMsg)
+	}
 	// validate user is allowed to create this user
 	if payload.User_type != "member" {
 		if payload.Signing_addr == payload.Addr {
@@ -842,11 +1279,10 @@ func (h *Helpers) createCommunityUser(payload models.CommunityUserPayload) (int,
 			log.Error().Err(CANNOT_GRANT_SELF_ERR)
 			return http.StatusForbidden, CANNOT_GRANT_SELF_ERR
 		}
-		// If signing address is not user address, verify they have admin status in this community
-		var communityAdmin = models.CommunityUser{Community_id: payload.Community_id, Addr: payload.Signing_addr, User_type: "admin"}
-		if err := communityAdmin.GetCommunityUser(h.A.DB); err != nil {
-			USER_MUST_BE_ADMIN_ERR := errors.New("User must be community admin to grant privileges.")
-			log.Error().Err(err).Msg("Database error.")
+		// If signing address is not user address, verify they can grant roles in this community
+		if err := models.EnsurePermissionForCommunity(h.A.DB, payload.Signing_addr, payload.Community_id, models.ActionRoleGrant); err != nil {
+			USER_MUST_BE_ADMIN_ERR := errors.New("User must be allowed to grant roles to grant privileges.")
+			log.Error().Err(err).Msg("Permission denied.")
 			log.Error().Err(USER_MUST_BE_ADMIN_ERR)
 			return http.StatusForbidden, USER_MUST_BE_ADMIN_ERR
 		}
@@ -861,13 +1297,20 @@ func (h *Helpers) createCommunityUser(payload models.CommunityUserPayload) (int,
 		return http.StatusForbidden, CANNOT_ADD_MEMBER_ERR
 	}
 
+	action := signedAction{models.ActionRoleGrant, resourcePath("communities", payload.Community_id, "users", payload.Addr, payload.User_type), payload.Nonce}
 	if payload.Voucher != nil {
-		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher); err != nil {
+		if err := h.validateUserViaVoucher(payload.Signing_addr, payload.Voucher, action); err != nil {
 			log.Error().Err(err)
 			return http.StatusForbidden, err
 		}
 	} else {
-		if err := h.validateUser(payload.Signing_addr, payload.Timestamp, payload.Composite_signatures); err != nil {
+		if err := h.validateUserOrSession(
+			payload.Session_addr,
+			payload.Signing_addr,
+			payload.Timestamp,
+			payload.Composite_signatures,
+			action,
+		); err != nil {
 			log.Error().Err(err)
 			return http.StatusForbidden, err
 		}
@@ -892,17 +1335,194 @@ func (h *Helpers) createCommunityUser(payload models.CommunityUserPayload) (int,
 		if err := models.GrantAuthorRolesToAddress(h.A.DB, u.Community_id, u.Addr); err != nil {
 			return http.StatusInternalServerError, err
 		}
-	} else {
+	} else if u.User_type == "member" {
 		// grant member role
 		if err := u.CreateCommunityUser(h.A.DB); err != nil {
 			log.Error().Err(err)
 			return http.StatusInternalServerError, err
 		}
+	} else {
+		// moderators and custom roles are members too
+		if err := models.GrantRolesToAddress(h.A.DB, u.Community_id, u.Addr, models.UserTypes{u.User_type, "member"}); err != nil {
+			return http.StatusInternalServerError, err
+		}
 	}
 
 	return http.StatusCreated, nil
 }
 
+// Creates or updates one of the community's custom roles.
+func (h *Helpers) saveCommunityRole(payload models.CommunityRolePayload, update bool) (models.CommunityRole, int, error) {
+	validate := validator.New()
+	if vErr := validate.Struct(payload.CommunityRole); vErr != nil {
+		errMsg := "Invalid community role."
+		log.Error().Err(vErr).Msg(errMsg)
+		return models.CommunityRole{}, http.StatusBadRequest, errors.New(errMsg)
+	}
+	if models.IsBuiltInRole(payload.Name) {
+		errMsg := fmt.Sprintf("%s is a built in role.", payload.Name)
+		return models.CommunityRole{}, http.StatusBadRequest, errors.New(errMsg)
+	}
+	for _, permission := range payload.Permissions {
+		if !funk.ContainsString(models.CustomRolePermissions, permission) {
+			errMsg := fmt.Sprintf("Custom roles can't allow %s.", permission)
+			return models.CommunityRole{}, http.StatusBadRequest, errors.New(errMsg)
+		}
+	}
+
+	resource := resourcePath("communities", payload.Community_id, "roles")
+	if update {
+		resource = resourcePath(resource, payload.Name)
+	}
+	if err := h.validateUserOrSession(
+		payload.Session_addr,
+		payload.Signing_addr,
+		payload.Timestamp,
+		payload.Composite_signatures,
+		signedAction{models.ActionRoleManage, resource, payload.Nonce},
+	); err != nil {
+		log.Error().Err(err)
+		return models.CommunityRole{}, http.StatusForbidden, err
+	}
+
+	role := payload.CommunityRole
+	role.Permissions = funk.UniqString(role.Permissions)
+	if update {
+		if err := role.UpdateCommunityRole(h.A.DB); err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				errMsg := fmt.Sprintf("Community %d has no %s role.", role.Community_id, role.Name)
+				return models.CommunityRole{}, http.StatusNotFound, errors.New(errMsg)
+			}
+			return models.CommunityRole{}, http.StatusInternalServerError, err
+		}
+		return role, http.StatusOK, nil
+	}
+
+	if err := role.GetCommunityRole(h.A.DB); err == nil {
+		errMsg := fmt.Sprintf("Community %d already has a %s role.", role.Community_id, role.Name)
+		return models.CommunityRole{}, http.StatusBadRequest, errors.New(errMsg)
+	}
+	if err := role.CreateCommunityRole(h.A.DB); err != nil {
+		return models.CommunityRole{}, http.StatusInternalServerError, err
+	}
+	return role, http.StatusCreated, nil
+}
+
+// Removes one of the community's custom roles along with its grants.
+func (h *Helpers) removeCommunityRole(payload models.CommunityRolePayload) (int, error) {
+	if err := h.validateUserOrSession(
+		payload.Session_addr,
+		payload.Signing_addr,
+		payload.Timestamp,
+		payload.Composite_signatures,
+		signedAction{models.ActionRoleManage, resourcePath("communities", payload.Community_id, "roles", payload.Name), payload.Nonce},
+	); err != nil {
+		log.Error().Err(err)
+		return http.StatusForbidden, err
+	}
+
+	role := payload.CommunityRole
+	if err := role.GetCommunityRole(h.A.DB); err != nil {
+		errMsg := fmt.Sprintf("Community %d has no %s role.", role.Community_id, role.Name)
+		return http.StatusNotFound, errors.New(errMsg)
+	}
+	if err := role.Remove(h.A.DB); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (h *Helpers) validateDelegationSigner(payload models.DelegationPayload, action string) error {
+	signed := signedAction{action, resourcePath("communities", payload.Community_id, "delegations"), payload.Nonce}
+	if action == models.ActionDelegationRevoke {
+		signed.Resource = resourcePath(signed.Resource, payload.Delegator_addr)
+	}
+
+	if payload.Voucher != nil {
+		return h.validateUserViaVoucher(payload.Delegator_addr, payload.Voucher, signed)
+	}
+
+	return h.validateUser(payload.Delegator_addr, payload.Timestamp, payload.Composite_signatures, signed)
+}
+
+func (h *Helpers) createDelegation(payload models.DelegationPayload) (models.Delegation, int, error) {
+	validate := validator.New()
+	if vErr := validate.Struct(payload.Delegation); vErr != nil {
+		errMsg := "Invalid delegation."
+		log.Error().Err(vErr).Msg(errMsg)
+		return models.Delegation{}, http.StatusBadRequest, errors.New(errMsg)
+	}
+
+	if payload.Delegator_addr == payload.Delegate_addr {
+		CANNOT_DELEGATE_SELF_ERR := errors.New("An account cannot delegate to itself.")
+		log.Error().Err(CANNOT_DELEGATE_SELF_ERR)
+		return models.Delegation{}, http.StatusBadRequest, CANNOT_DELEGATE_SELF_ERR
+	}
+
+	// the delegator signs the delegation
+	if err := h.validateDelegationSigner(payload, models.ActionDelegationCreate); err != nil {
+		log.Error().Err(err)
+		return models.Delegation{}, http.StatusForbidden, err
+	}
+
+	if err := h.validateBlocklist(payload.Delegator_addr, payload.Community_id); err != nil {
+		return models.Delegation{}, http.StatusForbidden, err
+	}
+
+	// an account must revoke its delegation before delegating again
+	existing := models.Delegation{Community_id: payload.Community_id, Delegator_addr: payload.Delegator_addr}
+	if err := existing.GetActiveDelegation(h.A.DB); err == nil {
+		ALREADY_DELEGATED_ERR := fmt.Errorf(
+			"Address %s has already delegated to %s.", existing.Delegator_addr, existing.Delegate_addr,
+		)
+		log.Error().Err(ALREADY_DELEGATED_ERR)
+		return models.Delegation{}, http.StatusBadRequest, ALREADY_DELEGATED_ERR
+	} else if err.Error() != pgx.ErrNoRows.Error() {
+		return models.Delegation{}, http.StatusInternalServerError, err
+	}
+
+	d := payload.Delegation
+	if err := d.CreateDelegation(h.A.DB); err != nil {
+		log.Error().Err(err).Msg("Error creating delegation in the database.")
+		return models.Delegation{}, http.StatusInternalServerError, err
+	}
+
+	// the scheduler retries balances that can't be fetched now
+	proposals, err := models.GetOpenProposalsForCommunity(h.A.DB, d.Community_id)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting open proposals for delegation.")
+	}
+	for _, p := range proposals {
+		if err := h.fetchDelegatorBalances(*p); err != nil {
+			log.Error().Err(err).Msgf("Error fetching delegator balances for proposal %d.", p.ID)
+		}
+	}
+
+	return d, http.StatusCreated, nil
+}
+
+func (h *Helpers) revokeDelegation(payload models.DelegationPayload) (models.Delegation, int, error) {
+	if err := h.validateDelegationSigner(payload, models.ActionDelegationRevoke); err != nil {
+		log.Error().Err(err)
+		return models.Delegation{}, http.StatusForbidden, err
+	}
+
+	d := models.Delegation{Community_id: payload.Community_id, Delegator_addr: payload.Delegator_addr}
+	if err := d.GetActiveDelegation(h.A.DB); err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return models.Delegation{}, http.StatusNotFound, errors.New("Delegation not found.")
+		}
+		return models.Delegation{}, http.StatusInternalServerError, err
+	}
+
+	if err := d.RevokeDelegation(h.A.DB); err != nil {
+		log.Error().Err(err).Msg("Error revoking delegation.")
+		return models.Delegation{}, http.StatusInternalServerError, err
+	}
+
+	return d, http.StatusOK, nil
+}
+
 func (h *Helpers) updateAddressesInList(id int, payload models.ListUpdatePayload, action string) (int, error) {
 	l := models.List{ID: id}
 
@@ -923,7 +1543,13 @@ func (h *Helpers) updateAddressesInList(id int, payload models.ListUpdatePayload
 		return http.StatusBadRequest, errors.New(errMsg)
 	}
 
-	if err := h.validateUserWithRole(payload.Signing_addr, payload.Timestamp, payload.Composite_signatures, l.Community_id, "admin"); err != nil {
+	if err := h.validateUserOrSession(
+		payload.Session_addr,
+		payload.Signing_addr,
+		payload.Timestamp,
+		payload.Composite_signatures,
+		signedAction{models.ActionListEdit, resourcePath("lists", id), payload.Nonce},
+	); err != nil {
 		log.Error().Err(err)
 		return http.StatusForbidden, err
 	}
@@ -964,7 +1590,13 @@ func (h *Helpers) createListForCommunity(payload models.ListPayload) (models.Lis
 		return models.List{}, http.StatusBadRequest, errors.New(errMsg)
 	}
 
-	if err := h.validateUserWithRole(payload.Signing_addr, payload.Timestamp, payload.Composite_signatures, payload.Community_id, "admin"); err != nil {
+	if err := h.validateUserOrSession(
+		payload.Session_addr,
+		payload.Signing_addr,
+		payload.Timestamp,
+		payload.Composite_signatures,
+		signedAction{models.ActionListCreate, resourcePath("communities", payload.Community_id, "lists"), payload.Nonce},
+	); err != nil {
 		log.Error().Err(err)
 		return models.List{}, http.StatusForbidden, err
 	}
@@ -986,6 +1618,82 @@ func (h *Helpers) createListForCommunity(payload models.ListPayload) (models.Lis
 	return l, http.StatusCreated, nil
 }
 
+// Registers a community admin's script for the custom-script strategy once it
+// passes the static signature check and a dry run against the sample address.
+// Returns the NFT IDs found by the dry run.
+func (h *Helpers) createCustomScript(
+	payload models.CustomScriptPayload,
+	dryRun bool,
+) (models.CustomScript, []interface{}, int, error) {
+	validate := validator.New()
+	if vErr := validate.Struct(payload); vErr != nil {
+		errMsg := "Validation error in custom script payload."
+		log.Error().Err(vErr).Msg(errMsg)
+		return models.CustomScript{}, nil, http.StatusBadRequest, errors.New(errMsg)
+	}
+
+	c := payload.Contract
+	if c.Name == nil || c.Addr == nil || c.Public_path == nil {
+		errMsg := "Contract name, addr and publicPath are required."
+		return models.CustomScript{}, nil, http.StatusBadRequest, errors.New(errMsg)
+	}
+
+	if err := h.validateUserOrSession(
+		payload.Session_addr,
+		payload.Signing_addr,
+		payload.Timestamp,
+		payload.Composite_signatures,
+		signedAction{models.ActionScriptCreate, resourcePath("communities", payload.Community_id, "scripts"), payload.Nonce},
+	); err != nil {
+		log.Error().Err(err)
+		return models.CustomScript{}, nil, http.StatusForbidden, err
+	}
+
+	script := payload.CustomScript
+	script.Creator_addr = payload.Signing_addr
+
+	if _, ok := h.A.FlowAdapter.GetCustomScript(script.Key); ok {
+		errMsg := fmt.Sprintf("Script key %s is reserved.", script.Key)
+		return models.CustomScript{}, nil, http.StatusBadRequest, errors.New(errMsg)
+	}
+	existing := models.CustomScript{Community_id: script.Community_id, Key: script.Key}
+	if err := existing.GetCustomScriptByKey(h.A.DB); err == nil {
+		errMsg := fmt.Sprintf("Script with key %s already exists for community %d.", script.Key, script.Community_id)
+		return models.CustomScript{}, nil, http.StatusBadRequest, errors.New(errMsg)
+	}
+
+	if err := shared.ValidateNFTIdsScript(script.Src); err != nil {
+		return models.CustomScript{}, nil, http.StatusBadRequest, err
+	}
+
+	sampleAddr := payload.Signing_addr
+	if payload.Sample_addr != nil {
+		sampleAddr = *payload.Sample_addr
+	}
+	nftIds, err := h.A.FlowAdapter.GetNFTIdsFromScript(sampleAddr, c, []byte(script.Src), 0)
+	if err != nil {
+		errMsg := fmt.Sprintf("Dry run against %s failed: %s", sampleAddr, err.Error())
+		return models.CustomScript{}, nil, http.StatusBadRequest, errors.New(errMsg)
+	}
+
+	if dryRun {
+		return script, nftIds, http.StatusOK, nil
+	}
+
+	cid, err := h.pinJSONToIpfs(script)
+	if err != nil {
+		log.Error().Err(err).Msg("IPFS error: " + err.Error())
+		return models.CustomScript{}, nil, http.StatusInternalServerError, errors.New("Error pinning JSON to IPFS.")
+	}
+	script.Cid = cid
+
+	if err := script.CreateCustomScript(h.A.DB); err != nil {
+		return models.CustomScript{}, nil, http.StatusInternalServerError, err
+	}
+
+	return script, nftIds, http.StatusCreated, nil
+}
+
 func (h *Helpers) validateUserSignature(addr string, message string, sigs *[]shared.CompositeSignature) error {
 	shouldValidateSignature := h.A.Config.Features["validateSigs"]
 
@@ -1030,13 +1738,34 @@ func (h *Helpers) validateBlocklist(addr string, communityId int) error {
 	return nil
 }
 
+// What a signed request authorizes. Server issued nonces are bound to the
+// action and resource, so a signature can't be replayed or used for another
+// action.
+type signedAction struct {
+	Action   string
+	Resource string
+	Nonce    string
+}
+
+func resourcePath(parts ...interface{}) string {
+	path := make([]string, len(parts))
+	for i, part := range parts {
+		path[i] = fmt.Sprint(part)
+	}
+	return strings.Join(path, "/")
+}
+
 // Need to move this to conditional middleware
 func (h *Helpers) validateTimestamp(timestamp string, expiry int) error {
 	if !h.A.Config.Features["validateTimestamps"] {
 		return nil
 	}
 	// check timestamp and ensure no longer than expiry seconds has passed
-	stamp, _ := strconv.ParseInt(timestamp, 10, 64)
+	stamp, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		log.Error().Err(err).Msgf("Invalid timestamp: %s", timestamp)
+		return errors.New("Timestamp on request is invalid.")
+	}
 	uxTime := time.Unix(stamp/1000, (stamp%1000)*1000*1000)
 	diff := time.Now().UTC().Sub(uxTime).Seconds()
 	if diff > float64(expiry) {
@@ -1047,24 +1776,59 @@ func (h *Helpers) validateTimestamp(timestamp string, expiry int) error {
 	return nil
 }
 
-func (h *Helpers) validateUser(addr, timestamp string, compositeSignatures *[]shared.CompositeSignature) error {
+// Returns the message the signer must have signed. With nonces the message
+// names the action, otherwise it's the timestamp alone.
+func (h *Helpers) signedMessage(timestamp string, action signedAction) string {
+	if !h.A.Config.Features["validateNonces"] {
+		return timestamp
+	}
+	return shared.ActionMessage(action.Action, action.Resource, action.Nonce, timestamp)
+}
+
+// Uses up the request's nonce. Call only once the signature is valid, so
+// unsigned requests can't burn nonces.
+func (h *Helpers) consumeNonce(action signedAction) error {
+	if !h.A.Config.Features["validateNonces"] {
+		return nil
+	}
+	if action.Nonce == "" {
+		return errors.New("Nonce is required.")
+	}
+	return models.ConsumeNonce(h.A.DB, action.Nonce, action.Action, action.Resource)
+}
 
+func (h *Helpers) validateUser(
+	addr, timestamp string,
+	compositeSignatures *[]shared.CompositeSignature,
+	action signedAction,
+) error {
 	if err := h.validateTimestamp(timestamp, 60); err != nil {
 		return err
 	}
 
-	if err := h.validateUserSignature(addr, timestamp, compositeSignatures); err != nil {
+	message := h.signedMessage(timestamp, action)
+	if err := h.validateUserSignature(addr, message, compositeSignatures); err != nil {
 		return err
 	}
 
-	return nil
+	return h.consumeNonce(action)
 }
 
-func (h *Helpers) validateUserViaVoucher(addr string, voucher *shared.Voucher) error {
+// Vouchers carry the timestamp as their first argument and the nonce as
+// their second. The nonce must come from the signed arguments, a nonce in
+// the request body isn't covered by the voucher's signature.
+func (h *Helpers) validateUserViaVoucher(addr string, voucher *shared.Voucher, action signedAction) error {
+	if len(voucher.Arguments) == 0 {
+		return errors.New("Voucher is missing its timestamp argument.")
+	}
 	timestamp := voucher.Arguments[0]["value"]
 	if err := h.validateTimestamp(timestamp, 60); err != nil {
 		return err
 	}
+	action.Nonce = ""
+	if len(voucher.Arguments) > 1 {
+		action.Nonce = voucher.Arguments[1]["value"]
+	}
 
 	compositeSignatures := shared.GetUserCompositeSignatureFromVoucher(voucher)
 	// Validate authorizer
@@ -1080,70 +1844,221 @@ func (h *Helpers) validateUserViaVoucher(addr string, voucher *shared.Voucher) e
 		return err
 	}
 
-	return nil
+	return h.consumeNonce(action)
 }
 
-func (h *Helpers) validateUserWithRole(addr, timestamp string, compositeSignatures *[]shared.CompositeSignature, communityId int, role string) error {
-	//print out all the params
-	if err := h.validateTimestamp(timestamp, 60); err != nil {
-		return err
+func (h *Helpers) processTokenThreshold(address string, c shared.Contract, contractType string) (bool, error) {
+	var scriptPath string
+
+	if contractType == "nft" {
+		scriptPath = shared.ScriptGetNFTIds
+	} else {
+		scriptPath = shared.ScriptGetBalance
 	}
-	if err := h.validateUserSignature(addr, timestamp, compositeSignatures); err != nil {
-		return err
+
+	hasBalance, err := h.A.FlowAdapter.EnforceTokenThreshold(scriptPath, address, &c)
+	if err != nil {
+		return false, err
 	}
-	if err := models.EnsureRoleForCommunity(h.A.DB, addr, communityId, role); err != nil {
-		errMsg := fmt.Sprintf("Account %s is not an author for community %d.", addr, communityId)
-		log.Error().Err(err).Msg(errMsg)
-		return err
+
+	return hasBalance, nil
+}
+
+// Validates the signer by their session when the request carried a session
+// token, otherwise by their signed timestamp.
+func (h *Helpers) validateUserOrSession(
+	sessionAddr, addr, timestamp string,
+	compositeSignatures *[]shared.CompositeSignature,
+	action signedAction,
+) error {
+	if sessionAddr != "" {
+		return validateSessionSigner(sessionAddr, addr)
 	}
+	return h.validateUser(addr, timestamp, compositeSignatures, action)
+}
 
+func validateSessionSigner(sessionAddr, addr string) error {
+	if sessionAddr != addr {
+		return errors.New("Signing address does not match the session.")
+	}
 	return nil
 }
 
-func (h *Helpers) validateUserWithRoleViaVoucher(addr string, voucher *shared.Voucher, communityId int, role string) error {
-	timestamp := voucher.Arguments[0]["value"]
-	if err := h.validateTimestamp(timestamp, 60); err != nil {
-		return err
+// Returns the address of the session whose token the request carries in its
+// Authorization header, or "" if it carries none.
+func (h *Helpers) sessionAddr(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", nil
 	}
 
-	compositeSignatures := shared.GetUserCompositeSignatureFromVoucher(voucher)
-	// Validate authorizer
-	authorizer := voucher.Authorizers[0]
-	if authorizer != addr || authorizer != (*compositeSignatures)[0].Addr {
-		err := errors.New("authorizer address must match voter address and envelope signer")
-		log.Error().Err(err)
-		return err
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header {
+		return "", errors.New("Authorization header must be a bearer token.")
 	}
 
-	// validate signature using encoded transaction payload as message
-	message := shared.EncodeMessageFromVoucher(voucher)
-	if err := h.validateTxSignature(addr, message, compositeSignatures); err != nil {
-		return err
+	session, err := h.activeSession(token)
+	if err != nil {
+		return "", err
 	}
-	if err := models.EnsureRoleForCommunity(h.A.DB, addr, communityId, role); err != nil {
-		errMsg := fmt.Sprintf("Account %s is not an author for community %d.", addr, communityId)
-		log.Error().Err(err).Msg(errMsg)
-		return err
+	return session.Addr, nil
+}
+
+func (h *Helpers) activeSession(token string) (models.Session, error) {
+	claims, err := h.A.Sessions.Parse(token)
+	if err != nil {
+		return models.Session{}, err
 	}
 
-	return nil
+	session := models.Session{ID: claims.Session_id}
+	if err := session.GetSessionById(h.A.DB); err != nil {
+		return models.Session{}, err
+	}
+	if !session.IsActive() || session.Addr != claims.Addr {
+		return models.Session{}, errors.New("Session has ended.")
+	}
+	return session, nil
 }
 
-func (h *Helpers) processTokenThreshold(address string, c shared.Contract, contractType string) (bool, error) {
-	var scriptPath string
+// Starts a session for the signer of the sign in message. Each nonce starts
+// one session only.
+func (h *Helpers) signIn(payload models.SignInPayload) (models.SessionResponse, errorResponse) {
+	validate := validator.New()
+	if vErr := validate.Struct(payload); vErr != nil {
+		log.Error().Err(vErr).Msg("Sign in validation error.")
+		return models.SessionResponse{}, errIncompleteRequest
+	}
 
-	if contractType == "nft" {
-		scriptPath = "./main/cadence/scripts/get_nfts_ids.cdc"
+	if err := h.validateTimestamp(payload.Timestamp, 60); err != nil {
+		return models.SessionResponse{}, errForbidden
+	}
+	message := shared.SignInMessage(payload.Addr, payload.Nonce, payload.Timestamp)
+	if err := h.validateUserSignature(payload.Addr, message, payload.Composite_signatures); err != nil {
+		log.Error().Err(err).Msgf("Invalid sign in signature for %s.", payload.Addr)
+		return models.SessionResponse{}, errForbidden
+	}
+	if err := h.consumeNonce(signedAction{models.ActionSignIn, payload.Addr, payload.Nonce}); err != nil {
+		log.Error().Err(err).Msgf("Invalid sign in nonce for %s.", payload.Addr)
+		return models.SessionResponse{}, errForbidden
+	}
+
+	refreshToken, err := shared.NewRefreshToken()
+	if err != nil {
+		return models.SessionResponse{}, errIncompleteRequest
+	}
+
+	session := models.Session{
+		Addr:               payload.Addr,
+		Nonce:              payload.Nonce,
+		Refresh_token_hash: shared.HashRefreshToken(refreshToken),
+		Expires_at:         time.Now().UTC().Add(h.A.Sessions.RefreshTTL),
+	}
+	if err := session.CreateSession(h.A.DB); err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			log.Error().Msgf("Sign in nonce for %s was already used.", payload.Addr)
+			return models.SessionResponse{}, errForbidden
+		}
+		log.Error().Err(err).Msg("Error creating session.")
+		return models.SessionResponse{}, errIncompleteRequest
+	}
+
+	return h.sessionResponse(session, refreshToken)
+}
+
+// Trades a refresh token for a new session token and refresh token.
+func (h *Helpers) refreshSession(payload models.RefreshSessionPayload) (models.SessionResponse, errorResponse) {
+	session := models.Session{Refresh_token_hash: shared.HashRefreshToken(payload.Refresh_token)}
+	if err := session.GetSessionByRefreshTokenHash(h.A.DB); err != nil {
+		return models.SessionResponse{}, errInvalidSession
+	}
+	if !session.IsActive() {
+		return models.SessionResponse{}, errInvalidSession
+	}
+
+	refreshToken, err := shared.NewRefreshToken()
+	if err != nil {
+		return models.SessionResponse{}, errIncompleteRequest
+	}
+	expiresAt := time.Now().UTC().Add(h.A.Sessions.RefreshTTL)
+	if err := session.Refresh(h.A.DB, shared.HashRefreshToken(refreshToken), expiresAt); err != nil {
+		log.Error().Err(err).Msgf("Error refreshing session %s.", session.ID)
+		return models.SessionResponse{}, errInvalidSession
+	}
+
+	return h.sessionResponse(session, refreshToken)
+}
+
+// Ends the session of the request's session token, or of the refresh token
+// when the session token has already expired.
+func (h *Helpers) revokeSession(r *http.Request, payload models.RefreshSessionPayload) errorResponse {
+	var session models.Session
+	if payload.Refresh_token != "" {
+		session.Refresh_token_hash = shared.HashRefreshToken(payload.Refresh_token)
+		if err := session.GetSessionByRefreshTokenHash(h.A.DB); err != nil {
+			return errInvalidSession
+		}
 	} else {
-		scriptPath = "./main/cadence/scripts/get_balance.cdc"
+		var err error
+		session, err = h.activeSession(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			return errInvalidSession
+		}
 	}
 
-	hasBalance, err := h.A.FlowAdapter.EnforceTokenThreshold(scriptPath, address, &c)
+	if session.Revoked_at != nil {
+		return nilErr
+	}
+	if err := session.Revoke(h.A.DB); err != nil {
+		log.Error().Err(err).Msgf("Error revoking session %s.", session.ID)
+		return errIncompleteRequest
+	}
+	return nilErr
+}
+
+func (h *Helpers) sessionResponse(session models.Session, refreshToken string) (models.SessionResponse, errorResponse) {
+	token, expiresAt, err := h.A.Sessions.Issue(session.Addr, session.ID)
 	if err != nil {
-		return false, err
+		log.Error().Err(err).Msg("Error issuing session token.")
+		return models.SessionResponse{}, errIncompleteRequest
 	}
 
-	return hasBalance, nil
+	return models.SessionResponse{
+		Addr:               session.Addr,
+		Token:              token,
+		Expires_at:         expiresAt,
+		Refresh_token:      refreshToken,
+		Refresh_expires_at: session.Expires_at,
+	}, nilErr
+}
+
+// Issues a nonce for one signed request doing the action on the resource.
+func (h *Helpers) createNonce(payload models.Nonce) (models.Nonce, errorResponse) {
+	if !funk.Contains(models.NonceActions, payload.Action) {
+		errResponse := errIncompleteRequest
+		errResponse.Details = fmt.Sprintf("Unknown action: %s.", payload.Action)
+		return models.Nonce{}, errResponse
+	}
+
+	if err := models.DeleteExpiredNonces(h.A.DB); err != nil {
+		log.Error().Err(err).Msg("Error deleting expired nonces.")
+	}
+
+	nonce, err := shared.NewNonce()
+	if err != nil {
+		return models.Nonce{}, errIncompleteRequest
+	}
+	n := models.Nonce{
+		Nonce:      nonce,
+		Action:     payload.Action,
+		Resource:   payload.Resource,
+		Expires_at: time.Now().UTC().Add(h.A.NonceTTL),
+	}
+	if err := n.CreateNonce(h.A.DB); err != nil {
+		log.Error().Err(err).Msg("Error creating nonce.")
+		return models.Nonce{}, errIncompleteRequest
+	}
+
+	return n, nilErr
 }
 
 func (h *Helpers) initStrategy(name string) Strategy {
@@ -1223,6 +2138,76 @@ func validateContractThreshold(s []models.Strategy) error {
 				return errors.New("Contract Threshold cannot be less than 1.")
 			}
 		}
+		// balances are read as UFix64, which has no more precision than the default
+		if s.Decimals != nil && (*s.Decimals < 0 || *s.Decimals > shared.DefaultDecimals) {
+			return fmt.Errorf("Contract Decimals must be between 0 and %d.", shared.DefaultDecimals)
+		}
+		if s.Buckets != nil {
+			if err := validateContractBuckets(s.Contract); err != nil {
+				return err
+			}
+		}
+		if s.Prefetch_balances != nil && *s.Prefetch_balances && !canPrefetchBalances(s.Name) {
+			return errors.New("Balances can only be prefetched for token weighted strategies.")
+		}
+		if s.Name != nil && *s.Name == "composite-weighted" {
+			if err := validateContractComponents(s.Contract); err != nil {
+				return err
+			}
+		}
+		if s.Name != nil && *s.Name == "trait-weighted-nfts" {
+			if err := validateContractTraits(s.Contract); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateContractComponents(c shared.Contract) error {
+	if c.Components == nil || len(*c.Components) == 0 {
+		return errors.New("Composite strategy requires at least one component.")
+	}
+	for _, component := range *c.Components {
+		if component.Name == nil || component.Addr == nil || component.Public_path == nil {
+			return errors.New("Component name, addr and publicPath are required.")
+		}
+		if component.Type != shared.ComponentFT && component.Type != shared.ComponentNFT {
+			return fmt.Errorf("Component type must be %s or %s.", shared.ComponentFT, shared.ComponentNFT)
+		}
+		if component.Multiplier <= 0 {
+			return errors.New("Component multiplier must be greater than 0.")
+		}
+	}
+	return nil
+}
+
+func validateContractBuckets(c shared.Contract) error {
+	if len(*c.Buckets) == 0 {
+		return errors.New("Contract buckets cannot be empty.")
+	}
+	if c.Name == nil || *c.Name != "FlowToken" {
+		return errors.New("Contract buckets are only supported for FlowToken.")
+	}
+	for _, bucket := range *c.Buckets {
+		if !shared.IsFlowBucket(bucket) {
+			return fmt.Errorf("Unknown FLOW balance bucket: %s.", bucket)
+		}
+	}
+	return nil
+}
+
+func validateContractTraits(c shared.Contract) error {
+	if c.Traits == nil || len(*c.Traits) == 0 {
+		return errors.New("Trait weighted strategy requires at least one trait filter.")
+	}
+	for _, trait := range *c.Traits {
+		if trait.Name == "" {
+			return errors.New("Trait name is required.")
+		}
+		if trait.Multiplier != nil && *trait.Multiplier <= 0 {
+			return errors.New("Trait multiplier must be greater than 0.")
+		}
 	}
 	return nil
 }
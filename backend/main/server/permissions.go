@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// Finds the community a request acts on from its route variables.
+type communityResolver func(vars map[string]string) (int, error)
+
+func communityFromVar(name string) communityResolver {
+	return func(vars map[string]string) (int, error) {
+		return strconv.Atoi(vars[name])
+	}
+}
+
+func communityOfProposal(vars map[string]string) (int, error) {
+	p, err := helpers.fetchProposal(vars, "id")
+	if err != nil {
+		return 0, err
+	}
+	return p.Community_id, nil
+}
+
+func communityOfList(vars map[string]string) (int, error) {
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, err
+	}
+	l := models.List{ID: id}
+	if err := l.GetListById(helpers.A.DB); err != nil {
+		return 0, err
+	}
+	return l.Community_id, nil
+}
+
+// Lets a request through only if the address of its session, or else the
+// signer named in its payload, has a role allowing the action in the
+// community it acts on. Handlers still verify the signature.
+func (a *App) requirePermission(action string, community communityResolver) mux.MiddlewareFunc {
+	return a.requirePermissionUnlessSelf(action, community, nil)
+}
+
+// Like requirePermission, but also lets an address act on itself, for the
+// routes members use to join a community and give up their roles. Handlers
+// still decide what an address may do to itself, like granting itself a
+// privileged role.
+func (a *App) requirePermissionUnlessSelf(
+	action string,
+	community communityResolver,
+	subject subjectResolver,
+) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			communityId, err := community(mux.Vars(r))
+			if err != nil {
+				log.Error().Err(err).Msg("Error finding community for request")
+				respondWithError(w, errIncompleteRequest)
+				return
+			}
+
+			addr, err := helpers.sessionAddr(r)
+			if err != nil {
+				log.Error().Err(err).Msg("Invalid session token")
+				respondWithError(w, errInvalidSession)
+				return
+			}
+			if addr == "" {
+				if addr, err = payloadSigner(r); err != nil {
+					log.Error().Err(err).Msg("Error reading payload")
+					respondWithError(w, errIncompleteRequest)
+					return
+				}
+			}
+
+			if subject != nil {
+				target, err := subject(r)
+				if err != nil {
+					log.Error().Err(err).Msg("Error reading payload")
+					respondWithError(w, errIncompleteRequest)
+					return
+				}
+				if addr != "" && addr == target {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if err := models.EnsurePermissionForCommunity(a.DB, addr, communityId, action); err != nil {
+				log.Error().Err(err).Msg("Permission denied")
+				respondWithError(w, errForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Shorthand for a route handled by h behind requirePermission.
+func (a *App) withPermission(action string, community communityResolver, h http.HandlerFunc) http.Handler {
+	return a.requirePermission(action, community)(h)
+}
+
+// Shorthand for a route handled by h behind requirePermissionUnlessSelf.
+func (a *App) withPermissionUnlessSelf(
+	action string,
+	community communityResolver,
+	subject subjectResolver,
+	h http.HandlerFunc,
+) http.Handler {
+	return a.requirePermissionUnlessSelf(action, community, subject)(h)
+}
+
+// Finds the address a request acts on.
+type subjectResolver func(r *http.Request) (string, error)
+
+func subjectFromVar(name string) subjectResolver {
+	return func(r *http.Request) (string, error) {
+		return mux.Vars(r)[name], nil
+	}
+}
+
+// Returns the addr of the request's payload.
+func payloadSubject(r *http.Request) (string, error) {
+	var payload struct {
+		Addr string `json:"addr"`
+	}
+	err := readPayload(r, &payload)
+	return payload.Addr, err
+}
+
+// Returns the signingAddr of the request's payload.
+func payloadSigner(r *http.Request) (string, error) {
+	var payload struct {
+		Signing_addr string `json:"signingAddr"`
+	}
+	err := readPayload(r, &payload)
+	return payload.Signing_addr, err
+}
+
+// Decodes the request's payload into v and puts the body back for the
+// handler. Malformed payloads are left for the handler to reject.
+func readPayload(r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	json.Unmarshal(body, v)
+	return nil
+}
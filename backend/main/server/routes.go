@@ -1,57 +1,120 @@
 package server
 
+import (
+	"net/http"
+
+	"github.com/DapperCollectives/CAST/backend/main/middleware"
+	"github.com/DapperCollectives/CAST/backend/main/models"
+)
+
 func (a *App) initializeRoutes() {
 	// Health
 	a.Router.HandleFunc("/", a.health).Methods("GET")
 	a.Router.HandleFunc("/api", a.health).Methods("GET")
 	// File upload
-	a.Router.HandleFunc("/upload", a.upload).Methods("POST", "OPTIONS")
+	a.Router.Handle("/upload", a.withRateLimit("upload", a.upload)).Methods("POST", "OPTIONS")
 	// Communities
 	a.Router.HandleFunc("/communities", a.getCommunities).Methods("GET")
 	a.Router.HandleFunc("/communities-for-homepage", a.getCommunitiesForHomePage).Methods("GET")
 	a.Router.HandleFunc("/communities/{id:[0-9]+}", a.getCommunity).Methods("GET")
-	a.Router.HandleFunc("/communities/{id:[0-9]+}", a.updateCommunity).Methods("PATCH", "OPTIONS")
+	a.Router.Handle("/communities/{id:[0-9]+}",
+		a.withPermission(models.ActionCommunityUpdate, communityFromVar("id"), a.updateCommunity)).
+		Methods("PATCH", "OPTIONS")
 	a.Router.HandleFunc("/communities", a.createCommunity).Methods("POST", "OPTIONS")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/strategies", a.getActiveStrategiesForCommunity).Methods("GET")
 	//Community Search
-	a.Router.HandleFunc("/communities/search", a.searchCommunities).Methods("GET")
+	a.Router.Handle("/communities/search", a.withRateLimit("search", a.searchCommunities)).Methods("GET")
 	// Proposals
 	a.Router.HandleFunc("/proposals/{id:[0-9]+}", a.getProposal).Methods("GET")
-	a.Router.HandleFunc("/proposals/{id:[0-9]+}", a.updateProposal).Methods("PUT", "OPTIONS")
+	a.Router.Handle("/proposals/{id:[0-9]+}",
+		a.withPermission(models.ActionProposalCancel, communityOfProposal, a.updateProposal)).
+		Methods("PUT", "OPTIONS")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/proposals", a.getProposalsForCommunity).Methods("GET")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/proposals/{id:[0-9]+}", a.getProposal).Methods("GET")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/proposals", a.createProposal).Methods("POST", "OPTIONS")
-	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/proposals/{id:[0-9]+}", a.updateProposal).
+	a.Router.Handle("/communities/{communityId:[0-9]+}/proposals/{id:[0-9]+}",
+		a.withPermission(models.ActionProposalCancel, communityOfProposal, a.updateProposal)).
 		Methods("PUT", "OPTIONS")
 	// Lists
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/lists", a.getListsForCommunity).Methods("GET")
-	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/lists", a.createListForCommunity).Methods("POST", "OPTIONS")
+	a.Router.Handle("/communities/{communityId:[0-9]+}/lists",
+		a.withPermission(models.ActionListCreate, communityFromVar("communityId"), a.createListForCommunity)).
+		Methods("POST", "OPTIONS")
 	a.Router.HandleFunc("/lists/{id:[0-9]+}", a.getList).Methods("GET")
-	a.Router.HandleFunc("/lists/{id:[0-9]+}/add", a.addAddressesToList).Methods("POST", "OPTIONS")
-	a.Router.HandleFunc("/lists/{id:[0-9]+}/remove", a.removeAddressesFromList).Methods("POST", "OPTIONS")
+	a.Router.Handle("/lists/{id:[0-9]+}/add",
+		a.withPermission(models.ActionListEdit, communityOfList, a.addAddressesToList)).
+		Methods("POST", "OPTIONS")
+	a.Router.Handle("/lists/{id:[0-9]+}/remove",
+		a.withPermission(models.ActionListEdit, communityOfList, a.removeAddressesFromList)).
+		Methods("POST", "OPTIONS")
 	// Votes
 	a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/votes", a.getVotesForProposal).Methods("GET")
 	a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/votes/{addr:0x[a-zA-Z0-9]+}", a.getVoteForAddress).Methods("GET")
-	a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/votes", a.createVoteForProposal).Methods("POST", "OPTIONS")
+	a.Router.Handle("/proposals/{proposalId:[0-9]+}/votes", a.withRateLimit("vote", a.createVoteForProposal)).
+		Methods("POST", "OPTIONS")
+	a.Router.Handle("/proposals/{proposalId:[0-9]+}/votes/{addr:0x[a-zA-Z0-9]{16}}", a.withRateLimit("vote", a.updateVoteForProposal)).
+		Methods("PUT", "OPTIONS")
+	a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/votes/{addr:0x[a-zA-Z0-9]{16}}/history", a.getVoteHistoryForAddress).
+		Methods("GET")
 	a.Router.HandleFunc("/votes/{addr:0x[a-zA-Z0-9]+}", a.getVotesForAddress).Methods("GET")
 	//Strategies
-	// a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/votes/{addr:0x[a-zA-Z0-9]{16}}", a.updateVoteForProposal).Methods("PUT", "OPTIONS")
 	a.Router.HandleFunc("/proposals/{proposalId:[0-9]+}/results", a.getResultsForProposal)
 	// Types
 	a.Router.HandleFunc("/voting-strategies", a.getVotingStrategies).Methods("GET")
 	a.Router.HandleFunc("/community-categories", a.getCommunityCategories).Methods("GET")
 	// Users
 	a.Router.HandleFunc("/users/{addr:0x[a-zA-Z0-9]{16}}/communities", a.getUserCommunities).Methods("GET")
-	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/users", a.createCommunityUser).Methods("POST", "OPTIONS")
+	// members add themselves and give up their own roles
+	a.Router.Handle("/communities/{communityId:[0-9]+}/users",
+		a.withPermissionUnlessSelf(models.ActionRoleGrant, communityFromVar("communityId"), payloadSubject, a.createCommunityUser)).
+		Methods("POST", "OPTIONS")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/users", a.getCommunityUsers).Methods("GET")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/users/type/{userType:[a-zA-Z]+}", a.getCommunityUsersByType).
 		Methods("GET")
-	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/users/{addr:0x[a-zA-Z0-9]{16}}/{userType:[a-zA-Z]+}", a.removeUserRole).
+	a.Router.Handle("/communities/{communityId:[0-9]+}/users/{addr:0x[a-zA-Z0-9]{16}}/{userType:[a-zA-Z]+}",
+		a.withPermissionUnlessSelf(models.ActionRoleRemove, communityFromVar("communityId"), subjectFromVar("addr"), a.removeUserRole)).
 		Methods("DELETE", "OPTIONS")
 	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/leaderboard", a.getCommunityLeaderboard).Methods("GET")
+	// Roles
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/roles", a.getCommunityRoles).Methods("GET")
+	a.Router.Handle("/communities/{communityId:[0-9]+}/roles",
+		a.withPermission(models.ActionRoleManage, communityFromVar("communityId"), a.createCommunityRole)).
+		Methods("POST", "OPTIONS")
+	a.Router.Handle("/communities/{communityId:[0-9]+}/roles/{name:[a-zA-Z]+}",
+		a.withPermission(models.ActionRoleManage, communityFromVar("communityId"), a.updateCommunityRole)).
+		Methods("PUT", "OPTIONS")
+	a.Router.Handle("/communities/{communityId:[0-9]+}/roles/{name:[a-zA-Z]+}",
+		a.withPermission(models.ActionRoleManage, communityFromVar("communityId"), a.removeCommunityRole)).
+		Methods("DELETE", "OPTIONS")
+	// Delegations
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/delegations", a.getDelegationsForCommunity).Methods("GET")
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/delegations", a.createDelegation).Methods("POST", "OPTIONS")
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/delegations/{addr:0x[a-zA-Z0-9]{16}}", a.getDelegationForAddress).
+		Methods("GET")
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/delegations/{addr:0x[a-zA-Z0-9]{16}}", a.revokeDelegation).
+		Methods("DELETE", "OPTIONS")
+	// Custom Scripts
+	a.Router.HandleFunc("/communities/{communityId:[0-9]+}/scripts", a.getCustomScriptsForCommunity).Methods("GET")
+	a.Router.Handle("/communities/{communityId:[0-9]+}/scripts",
+		a.withPermission(models.ActionScriptCreate, communityFromVar("communityId"), a.createCustomScript)).
+		Methods("POST", "OPTIONS")
+	// Nonces
+	a.Router.Handle("/nonces", a.withRateLimit("nonce", a.createNonce)).Methods("POST", "OPTIONS")
+	// Sessions
+	a.Router.HandleFunc("/auth/sign-in", a.signIn).Methods("POST", "OPTIONS")
+	a.Router.HandleFunc("/auth/refresh", a.refreshSession).Methods("POST", "OPTIONS")
+	a.Router.HandleFunc("/auth/revoke", a.revokeSession).Methods("POST", "OPTIONS")
 	// Utilities
 	a.Router.HandleFunc("/accounts/admin", a.getAdminList).Methods("GET")
 	a.Router.HandleFunc("/accounts/blocklist", a.getCommunityBlocklist).Methods("GET")
 	a.Router.HandleFunc("/accounts/{addr:0x[a-zA-Z0-9]{16}}/{blockHeight:[0-9]+}", a.getAccountAtBlockHeight).Methods("GET")
 
 }
+
+// Shorthand for a route handled by h within its budget in Config.RateLimits.
+func (a *App) withRateLimit(route string, h http.HandlerFunc) http.Handler {
+	reject := func(w http.ResponseWriter) {
+		respondWithError(w, errTooManyRequests)
+	}
+	return middleware.UseRateLimit(a.Config, a.RateLimiter, route, reject)(h)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Name of the lease held while the scheduler runs, so only one API
+// replica processes proposals at a time. The lease is claimed and released
+// with single queries, so hooks have the whole connection pool to work with.
+const schedulerLease = "proposal-scheduler"
+
+// A run that outlives its lease may overlap the next replica's run,
+// which hooks tolerate since they must be safe to run more than once.
+const schedulerLeaseTTL = 15 * time.Minute
+
+const defaultSchedulerInterval = time.Minute
+
+// Proposals transitioned per run, so a backlog, like every proposal that
+// ended before the scheduler was deployed, is worked through a batch a run.
+const schedulerBatchSize = 50
+
+type transitionHook func(p models.Proposal) error
+
+// Scheduler watches for proposals crossing their start and end times
+// and runs the hooks for each transition. A transition is persisted in the
+// proposal's lifecycle status only after its hooks succeed, failed hooks are
+// retried on the next run. Hooks must be safe to run more than once.
+type Scheduler struct {
+	A        *App
+	Interval time.Duration
+	hooks    map[string][]transitionHook
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewScheduler(a *App, interval time.Duration) *Scheduler {
+	s := &Scheduler{A: a, Interval: interval}
+	s.hooks = map[string][]transitionHook{
+		models.LifecycleActive: {s.fetchDelegatorBalances},
+		models.LifecycleClosed: {s.finalizeResults},
+	}
+	return s
+}
+
+// Start runs the scheduler in the background until Stop is called.
+func (s *Scheduler) Start() {
+	log.Info().Msgf("Starting proposal scheduler, running every %s", s.Interval)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunOnce(); err != nil {
+				log.Error().Err(err).Msg("Proposal scheduler run failed.")
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Proposal scheduler stopped.")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduler, waiting for a run in progress to finish.
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+}
+
+// RunOnce processes the proposals due for a transition. It returns
+// without doing anything if another replica holds the scheduler lease.
+func (s *Scheduler) RunOnce() error {
+	db := s.A.DB
+	holder := uuid.New()
+
+	var claimed bool
+	err := db.Conn.QueryRow(db.Context, `
+		INSERT INTO scheduler_leases(name, holder, expires_at)
+		VALUES($1, $2, (now() at time zone 'utc') + $3 * interval '1 second')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE scheduler_leases.expires_at < (now() at time zone 'utc')
+		RETURNING true
+	`, schedulerLease, holder, schedulerLeaseTTL.Seconds()).Scan(&claimed)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return err
+	}
+	if !claimed {
+		log.Debug().Msg("Proposal scheduler lease held by another replica, skipping run.")
+		return nil
+	}
+	defer func() {
+		if _, err := db.Conn.Exec(db.Context,
+			`DELETE FROM scheduler_leases WHERE name = $1 AND holder = $2`,
+			schedulerLease, holder); err != nil {
+			log.Error().Err(err).Msg("Error releasing proposal scheduler lease.")
+		}
+	}()
+
+	proposals, err := models.GetProposalsDueForTransition(db, schedulerBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range proposals {
+		if err := s.transition(p); err != nil {
+			log.Error().Err(err).Msgf("Error transitioning proposal %d.", p.ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) transition(p *models.Proposal) error {
+	if p.Computed_status == nil {
+		return nil
+	}
+
+	status := *p.Computed_status
+	if status != models.LifecycleActive && status != models.LifecycleClosed {
+		return nil
+	}
+
+	for _, hook := range s.hooks[status] {
+		if err := hook(*p); err != nil {
+			return err
+		}
+	}
+
+	updated, err := p.UpdateLifecycleStatus(s.A.DB, status)
+	if err != nil {
+		return err
+	}
+	if updated {
+		log.Info().Msgf("Proposal %d is now %s.", p.ID, status)
+	}
+
+	return nil
+}
+
+// Failed fetches are retried on the next run, until the proposal closes.
+func (s *Scheduler) fetchDelegatorBalances(p models.Proposal) error {
+	return helpers.fetchDelegatorBalances(p)
+}
+
+// Delegators whose balance still can't be fetched aren't counted.
+func (s *Scheduler) finalizeResults(p models.Proposal) error {
+	if err := helpers.fetchDelegatorBalances(p); err != nil {
+		log.Error().Err(err).Msgf("Finalizing proposal %d without every delegator balance.", p.ID)
+	}
+
+	_, err := helpers.finalizeProposalResults(p)
+	return err
+}
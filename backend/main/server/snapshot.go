@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultPrefetchWorkers = 8
+
+// Strategies whose vote weight comes from a stored balance alone, so a
+// balance fetched ahead of the vote can stand in for fetching it then.
+var prefetchStrategies = map[string]bool{
+	"token-weighted-default":        true,
+	"quadratic-token-weighted":      true,
+	"total-token-weighted-default":  true,
+	"staked-token-weighted-default": true,
+}
+
+func canPrefetchBalances(name *string) bool {
+	return name != nil && prefetchStrategies[*name]
+}
+
+// Progress is saved after this many addresses, and once all are done.
+const prefetchProgressInterval = 25
+
+// BalancePrefetcher snapshots the balances of a community's allowlist when a
+// proposal is created, so votes don't wait on the archive node. Addresses
+// that fail are left to be fetched when they vote.
+type BalancePrefetcher struct {
+	A       *App
+	Workers int
+}
+
+func NewBalancePrefetcher(a *App, workers int) *BalancePrefetcher {
+	if workers < 1 {
+		workers = defaultPrefetchWorkers
+	}
+	return &BalancePrefetcher{A: a, Workers: workers}
+}
+
+// Returns the addresses to prefetch for the proposal, or nil if its strategy
+// doesn't prefetch balances or the community has no allowlist.
+func (bp *BalancePrefetcher) Addresses(p models.Proposal, strategy models.Strategy) ([]string, error) {
+	if strategy.Prefetch_balances == nil || !*strategy.Prefetch_balances || p.Block_height == nil {
+		return nil, nil
+	}
+	if !canPrefetchBalances(p.Strategy) {
+		return nil, nil
+	}
+
+	list, err := models.GetListForCommunityByType(bp.A.DB, p.Community_id, "allow")
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	addresses := []string{}
+	for _, addr := range list.Addresses {
+		if !seen[addr] {
+			seen[addr] = true
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses, nil
+}
+
+// Fetches and stores the balance of every address at the proposal's block
+// height, reporting progress in the proposal's snapshot status.
+func (bp *BalancePrefetcher) Run(p models.Proposal, addresses []string) error {
+	s := helpers.initStrategy(*p.Strategy)
+	if s == nil {
+		return errors.New("Strategy not found.")
+	}
+
+	total := len(addresses)
+	if err := p.UpdateSnapshotStatus(bp.A.DB, models.SnapshotProcessing, 0, total); err != nil {
+		return err
+	}
+
+	jobs := make(chan string)
+	var (
+		mu      sync.Mutex
+		fetched int
+		failed  int
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < bp.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				err := bp.fetchBalance(s, p, addr)
+
+				mu.Lock()
+				if err != nil {
+					log.Error().Err(err).Msgf("Error prefetching balance of %s for proposal %d.", addr, p.ID)
+					failed++
+				} else {
+					fetched++
+				}
+				if fetched%prefetchProgressInterval == 0 && err == nil {
+					if err := p.UpdateSnapshotStatus(bp.A.DB, models.SnapshotProcessing, fetched, total); err != nil {
+						log.Error().Err(err).Msgf("Error saving snapshot progress of proposal %d.", p.ID)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, addr := range addresses {
+		jobs <- addr
+	}
+	close(jobs)
+	wg.Wait()
+
+	status := models.SnapshotComplete
+	if failed > 0 {
+		status = models.SnapshotFailed
+	}
+	log.Info().Msgf("Prefetched %d of %d balances for proposal %d.", fetched, total, p.ID)
+
+	return p.UpdateSnapshotStatus(bp.A.DB, status, fetched, total)
+}
+
+// Runs the prefetch in the background. Tests run it inline so they can check
+// the result.
+func (bp *BalancePrefetcher) Prefetch(p models.Proposal, addresses []string) {
+	run := func() {
+		if err := bp.Run(p, addresses); err != nil {
+			log.Error().Err(err).Msgf("Error prefetching balances for proposal %d.", p.ID)
+		}
+	}
+	if os.Getenv("APP_ENV") == "TEST" {
+		run()
+		return
+	}
+	go run()
+}
+
+func (bp *BalancePrefetcher) fetchBalance(s Strategy, p models.Proposal, addr string) error {
+	b := &models.Balance{
+		Addr:        addr,
+		Proposal_id: p.ID,
+		BlockHeight: *p.Block_height,
+		Source:      p.Balance_source,
+	}
+
+	// another proposal with the same source may have stored it already
+	err := b.GetBalanceByAddressAndBlockHeight(bp.A.DB)
+	if err == nil {
+		return nil
+	}
+	if err.Error() != pgx.ErrNoRows.Error() {
+		return err
+	}
+
+	_, err = s.FetchBalance(b, &p)
+	return err
+}
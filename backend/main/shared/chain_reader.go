@@ -0,0 +1,32 @@
+package shared
+
+import "github.com/onflow/flow-go-sdk"
+
+// ChainReader is everything the server and the strategies read from the
+// chain. FlowAdapter reads it from the access nodes, FakeChainReader from
+// in-memory fixtures.
+type ChainReader interface {
+	GetCurrentBlockHeight() (int, error)
+	GetAccountAtBlockHeight(addr string, blockHeight uint64) (*flow.Account, error)
+
+	// Balances
+	GetAddressBalanceAtBlockHeight(addr string, blockHeight uint64, balanceResponse *FTBalanceResponse, contract *Contract) error
+	GetFTBalance(address string, blockHeight uint64, contractName string, contractAddress string, publicPath string) (float64, error)
+	GetTotalSupplyAtBlockHeight(c *Contract, blockHeight uint64) (float64, error)
+	EnforceTokenThreshold(scriptPath, creatorAddr string, c *Contract) (bool, error)
+
+	// NFTs
+	GetNFTIds(voterAddr string, c *Contract, path string, blockHeight uint64) ([]interface{}, error)
+	GetNFTIdsFromScript(voterAddr string, c *Contract, script []byte, blockHeight uint64) ([]interface{}, error)
+	GetNFTTraits(voterAddr string, c *Contract, blockHeight uint64) (map[string]map[string]string, error)
+	GetCustomScript(key string) (CustomScript, bool)
+
+	// FLOATs
+	GetFloatNFTIds(voterAddr string, c *Contract, blockHeight uint64) ([]interface{}, error)
+	CheckIfUserHasEvent(voterAddr string, c *Contract, blockHeight uint64) (bool, error)
+
+	ValidateSignature(address, message string, sigs *[]CompositeSignature, messageType string) error
+}
+
+var _ ChainReader = (*FlowAdapter)(nil)
+var _ ChainReader = (*FakeChainReader)(nil)
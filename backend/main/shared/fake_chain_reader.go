@@ -0,0 +1,288 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// Chain state read by FakeChainReader. The state is the same at every block
+// height, snapshots read the current fixtures.
+type ChainFixtures struct {
+	BlockHeight uint64 `json:"blockHeight"`
+	// Accounts keyed by address
+	Accounts map[string]*AccountFixture `json:"accounts"`
+	// Total supply keyed by contract name
+	TotalSupply map[string]float64 `json:"totalSupply,omitempty"`
+}
+
+type AccountFixture struct {
+	FlowBalance *FlowBalanceFixture `json:"flowBalance,omitempty"`
+	// Fungible token balances keyed by contract name
+	Tokens map[string]float64 `json:"tokens,omitempty"`
+	// NFTs keyed by contract name
+	NFTs map[string][]NFTFixture `json:"nfts,omitempty"`
+	// FLOAT IDs keyed by event ID
+	Floats map[string][]uint64 `json:"floats,omitempty"`
+	Keys   []KeyFixture        `json:"keys,omitempty"`
+}
+
+// FLOW buckets in token amounts, see FlowBalance.
+type FlowBalanceFixture struct {
+	Unlocked   float64 `json:"unlocked"`
+	Locked     float64 `json:"locked"`
+	NodeStaked float64 `json:"nodeStaked"`
+	Delegated  float64 `json:"delegated"`
+	Rewards    float64 `json:"rewards"`
+	Unstaking  float64 `json:"unstaking"`
+}
+
+func (b *FlowBalanceFixture) fixedPoint(decimals int) *FlowBalance {
+	if b == nil {
+		return &FlowBalance{}
+	}
+	return &FlowBalance{
+		Unlocked:   ToFixedPoint(b.Unlocked, decimals),
+		Locked:     ToFixedPoint(b.Locked, decimals),
+		NodeStaked: ToFixedPoint(b.NodeStaked, decimals),
+		Delegated:  ToFixedPoint(b.Delegated, decimals),
+		Rewards:    ToFixedPoint(b.Rewards, decimals),
+		Unstaking:  ToFixedPoint(b.Unstaking, decimals),
+	}
+}
+
+type NFTFixture struct {
+	ID     uint64            `json:"id"`
+	Traits map[string]string `json:"traits,omitempty"`
+}
+
+type KeyFixture struct {
+	PublicKey string `json:"publicKey"`
+	SigAlgo   string `json:"sigAlgo"`
+	HashAlgo  string `json:"hashAlgo"`
+	Weight    int    `json:"weight"`
+	Revoked   bool   `json:"revoked,omitempty"`
+}
+
+// FakeChainReader serves chain reads from in-memory fixtures, so the server
+// and the tests can run without an emulator or access node.
+type FakeChainReader struct {
+	CustomScriptsMap map[string]CustomScript
+
+	mu       sync.RWMutex
+	fixtures ChainFixtures
+}
+
+func NewFakeChainReader(fixtures ChainFixtures, customScriptsMap map[string]CustomScript) *FakeChainReader {
+	if fixtures.Accounts == nil {
+		fixtures.Accounts = map[string]*AccountFixture{}
+	}
+	if fixtures.BlockHeight == 0 {
+		fixtures.BlockHeight = 1
+	}
+
+	accounts := make(map[string]*AccountFixture, len(fixtures.Accounts))
+	for addr, account := range fixtures.Accounts {
+		accounts[normalizeAddr(addr)] = account
+	}
+	fixtures.Accounts = accounts
+
+	return &FakeChainReader{CustomScriptsMap: customScriptsMap, fixtures: fixtures}
+}
+
+func LoadChainFixtures(path string) (ChainFixtures, error) {
+	var fixtures ChainFixtures
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixtures, err
+	}
+	err = json.Unmarshal(content, &fixtures)
+	return fixtures, err
+}
+
+// Replaces an account's fixture.
+func (f *FakeChainReader) SetAccount(addr string, account *AccountFixture) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fixtures.Accounts[normalizeAddr(addr)] = account
+}
+
+func (f *FakeChainReader) SetBlockHeight(blockHeight uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fixtures.BlockHeight = blockHeight
+}
+
+func (f *FakeChainReader) account(addr string) *AccountFixture {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if account, ok := f.fixtures.Accounts[normalizeAddr(addr)]; ok {
+		return account
+	}
+	return &AccountFixture{}
+}
+
+func normalizeAddr(addr string) string {
+	return flow.HexToAddress(addr).Hex()
+}
+
+func (f *FakeChainReader) GetCurrentBlockHeight() (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return int(f.fixtures.BlockHeight), nil
+}
+
+func (f *FakeChainReader) GetAccountAtBlockHeight(addr string, blockHeight uint64) (*flow.Account, error) {
+	account := f.account(addr)
+	keys, err := account.accountKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var balance uint64
+	if account.FlowBalance != nil {
+		balance = ToFixedPoint(account.FlowBalance.Unlocked, DefaultDecimals)
+	}
+
+	return &flow.Account{
+		Address: flow.HexToAddress(addr),
+		Balance: balance,
+		Keys:    keys,
+	}, nil
+}
+
+func (a *AccountFixture) accountKeys() ([]*flow.AccountKey, error) {
+	keys := make([]*flow.AccountKey, len(a.Keys))
+	for i, k := range a.Keys {
+		sigAlgo := crypto.StringToSignatureAlgorithm(k.SigAlgo)
+		publicKey, err := crypto.DecodePublicKeyHex(sigAlgo, k.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture key %d: %w", i, err)
+		}
+		keys[i] = &flow.AccountKey{
+			Index:     i,
+			PublicKey: publicKey,
+			SigAlgo:   sigAlgo,
+			HashAlgo:  crypto.StringToHashAlgorithm(k.HashAlgo),
+			Weight:    k.Weight,
+			Revoked:   k.Revoked,
+		}
+	}
+	return keys, nil
+}
+
+func (f *FakeChainReader) GetAddressBalanceAtBlockHeight(addr string, blockHeight uint64, balanceResponse *FTBalanceResponse, contract *Contract) error {
+	if *contract.Name == "FlowToken" {
+		balances := f.account(addr).FlowBalance.fixedPoint(contract.TokenDecimals())
+		balanceResponse.PrimaryAccountBalance = balances.Unlocked
+		balanceResponse.SecondaryAccountBalance = balances.Locked
+		balanceResponse.StakingBalance = balances.Sum(StakedBuckets)
+		balanceResponse.FlowBalance = balances
+		return nil
+	}
+
+	balance, err := f.GetFTBalance(addr, blockHeight, *contract.Name, *contract.Addr, *contract.Public_path)
+	if err != nil {
+		return err
+	}
+	balanceResponse.PrimaryAccountBalance = ToFixedPoint(balance, contract.TokenDecimals())
+	balanceResponse.SecondaryAccountBalance = 0
+	balanceResponse.StakingBalance = 0
+	return nil
+}
+
+func (f *FakeChainReader) GetFTBalance(address string, blockHeight uint64, contractName string, contractAddress string, publicPath string) (float64, error) {
+	return f.account(address).Tokens[contractName], nil
+}
+
+func (f *FakeChainReader) GetTotalSupplyAtBlockHeight(c *Contract, blockHeight uint64) (float64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	totalSupply, ok := f.fixtures.TotalSupply[*c.Name]
+	if !ok {
+		return 0, fmt.Errorf("no total supply fixture for %s", *c.Name)
+	}
+	return totalSupply, nil
+}
+
+func (f *FakeChainReader) EnforceTokenThreshold(scriptPath, creatorAddr string, c *Contract) (bool, error) {
+	var balance float64
+	if scriptPath == ScriptGetNFTIds {
+		balance = float64(len(f.account(creatorAddr).NFTs[*c.Name]))
+	} else {
+		balance = f.account(creatorAddr).Tokens[*c.Name]
+	}
+	return balance >= *c.Threshold, nil
+}
+
+// Scripts aren't run, the IDs of the contract's NFT fixtures are returned.
+func (f *FakeChainReader) GetNFTIds(voterAddr string, c *Contract, path string, blockHeight uint64) ([]interface{}, error) {
+	nftIds := []interface{}{}
+	for _, nft := range f.account(voterAddr).NFTs[*c.Name] {
+		nftIds = append(nftIds, strconv.FormatUint(nft.ID, 10))
+	}
+	return nftIds, nil
+}
+
+func (f *FakeChainReader) GetNFTIdsFromScript(voterAddr string, c *Contract, script []byte, blockHeight uint64) ([]interface{}, error) {
+	return f.GetNFTIds(voterAddr, c, "", blockHeight)
+}
+
+func (f *FakeChainReader) GetNFTTraits(voterAddr string, c *Contract, blockHeight uint64) (map[string]map[string]string, error) {
+	nfts := map[string]map[string]string{}
+	for _, nft := range f.account(voterAddr).NFTs[*c.Name] {
+		traits := map[string]string{}
+		for name, value := range nft.Traits {
+			traits[name] = value
+		}
+		nfts[strconv.FormatUint(nft.ID, 10)] = traits
+	}
+	return nfts, nil
+}
+
+func (f *FakeChainReader) GetCustomScript(key string) (CustomScript, bool) {
+	script, ok := f.CustomScriptsMap[key]
+	return script, ok
+}
+
+func (f *FakeChainReader) GetFloatNFTIds(voterAddr string, c *Contract, blockHeight uint64) ([]interface{}, error) {
+	nftIds := []interface{}{}
+	for _, id := range f.floats(voterAddr, c) {
+		nftIds = append(nftIds, strconv.FormatUint(id, 10))
+	}
+	return nftIds, nil
+}
+
+func (f *FakeChainReader) CheckIfUserHasEvent(voterAddr string, c *Contract, blockHeight uint64) (bool, error) {
+	return len(f.floats(voterAddr, c)) > 0, nil
+}
+
+func (f *FakeChainReader) floats(voterAddr string, c *Contract) []uint64 {
+	if c.Float_event_id == nil {
+		return nil
+	}
+	return f.account(voterAddr).Floats[strconv.FormatUint(*c.Float_event_id, 10)]
+}
+
+// Verifies the signatures against the account's fixture keys.
+func (f *FakeChainReader) ValidateSignature(address, message string, sigs *[]CompositeSignature, messageType string) error {
+	account, err := f.GetAccountAtBlockHeight(address, 0)
+	if err != nil {
+		return err
+	}
+
+	valid, err := VerifyAccountSignatures(account.Keys, message, *sigs, messageType)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
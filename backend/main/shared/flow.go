@@ -7,6 +7,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
@@ -16,20 +17,26 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/parser"
 	"github.com/onflow/flow-go-sdk"
-	"github.com/onflow/flow-go-sdk/client"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type FlowAdapter struct {
 	Config           FlowConfig
-	ArchiveClient    *client.Client
-	LiveClient       *client.Client
+	ArchiveClient    *AccessNodeClient
+	LiveClient       *AccessNodeClient
 	Context          context.Context
 	CustomScriptsMap map[string]CustomScript
-	URL              string
-	ArchiveURL       string
-	Env              string
+	Scripts          *ScriptCache
+	AccountKeys      *AccountKeyCache
+	// "local" verifies signatures in Go, falling back to the script when the
+	// keys can't be checked, "script" always runs validate_signature.cdc
+	SignatureVerification string
+	URL                   string
+	ArchiveURL            string
+	Env                   string
 }
 
 type FlowContract struct {
@@ -40,6 +47,23 @@ type FlowContract struct {
 type FlowConfig struct {
 	Contracts map[string]FlowContract `json:"contracts"`
 	Networks  map[string]string       `json:"networks"`
+	// Fallback access nodes per network, tried in order after the network's
+	// own URL. Kept apart from networks so the flow CLI can still read it.
+	AccessNodes map[string][]string `json:"accessNodes,omitempty"`
+}
+
+// Returns the network's URL followed by its fallback access nodes.
+func (c FlowConfig) NetworkURLs(network string) []string {
+	var urls []string
+	if url := c.Networks[network]; url != "" {
+		urls = append(urls, url)
+	}
+	for _, url := range c.AccessNodes[network] {
+		if !contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
 }
 
 type Contract struct {
@@ -50,6 +74,87 @@ type Contract struct {
 	MaxWeight      *float64 `json:"maxWeight,omitempty,string"`
 	Float_event_id *uint64  `json:"floatEventId,omitempty,string"`
 	Script         *string  `json:"script,omitempty"`
+	Decimals       *int     `json:"decimals,omitempty,string"`
+	// Contracts summed into the vote weight of a composite strategy
+	Components *[]ContractComponent `json:"components,omitempty"`
+	// MetadataViews trait filters for trait weighted NFT strategies
+	Traits *[]TraitFilter `json:"traits,omitempty"`
+	// FLOW balance buckets counted toward vote weight
+	Buckets *[]string `json:"buckets,omitempty"`
+	// Snapshot the allowlist's balances when a proposal is created
+	Prefetch_balances *bool `json:"prefetchBalances,omitempty"`
+	// Read NFTs at the latest block once the archive node no longer serves
+	// the proposal's snapshot, letting NFTs moved since the snapshot count
+	Latest_block_fallback *bool `json:"latestBlockFallback,omitempty"`
+}
+
+// Whether the strategy opted in to reading the latest block when its
+// snapshot is unavailable.
+func (c *Contract) AllowsLatestBlockFallback() bool {
+	return c.Latest_block_fallback != nil && *c.Latest_block_fallback
+}
+
+const (
+	ComponentFT  = "ft"
+	ComponentNFT = "nft"
+)
+
+// A contract counted toward a composite strategy. Fungible tokens count
+// their balance and NFTs count one per token held, times the multiplier.
+type ContractComponent struct {
+	Contract
+	Type       string  `json:"type"`
+	Multiplier float64 `json:"multiplier,string"`
+}
+
+// Filters NFTs on one of their MetadataViews traits. An NFT matches when its
+// trait value is one of Values, or when it has the trait if Values is empty.
+type TraitFilter struct {
+	Name       string   `json:"name"`
+	Values     []string `json:"values,omitempty"`
+	Multiplier *float64 `json:"multiplier,omitempty,string"`
+}
+
+// Returns the weight of an NFT with the given traits, or false if a filter
+// with allowed values excludes it. The weight starts at 1 and is multiplied
+// by the multiplier of every filter the NFT matches.
+func NFTTraitWeight(traits map[string]string, filters []TraitFilter) (float64, bool) {
+	weight := 1.0
+	for _, filter := range filters {
+		value, ok := traits[filter.Name]
+		matches := ok && (len(filter.Values) == 0 || contains(filter.Values, value))
+
+		if !matches {
+			if len(filter.Values) > 0 {
+				return 0, false
+			}
+			continue
+		}
+		if filter.Multiplier != nil {
+			weight *= *filter.Multiplier
+		}
+	}
+	return weight, true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Token balances are stored as fixed-point integers counting the token's
+// smallest unit. Flow's UFix64 has 8 decimal places, used by default.
+const DefaultDecimals = 8
+
+func (c *Contract) TokenDecimals() int {
+	if c.Decimals == nil {
+		return DefaultDecimals
+	}
+	return *c.Decimals
 }
 
 var (
@@ -67,6 +172,25 @@ func NewFlowClient(flowEnv string, customScriptsMap map[string]CustomScript) *Fl
 	adapter.Context = context.Background()
 	adapter.Env = flowEnv
 	adapter.CustomScriptsMap = customScriptsMap
+
+	scripts, err := LoadScripts(customScriptsMap)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading cadence scripts.")
+	}
+	adapter.Scripts = scripts
+
+	adapter.SignatureVerification = os.Getenv("FLOW_SIGNATURE_VERIFICATION")
+	if adapter.SignatureVerification == "" {
+		adapter.SignatureVerification = SignatureVerificationLocal
+	}
+	keyTTL := DefaultAccountKeyTTL
+	if v := os.Getenv("FLOW_ACCOUNT_KEY_TTL"); v != "" {
+		if keyTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatal().Err(err).Msg("Invalid FLOW_ACCOUNT_KEY_TTL.")
+		}
+	}
+	adapter.AccountKeys = NewAccountKeyCache(keyTTL)
+
 	path := "./flow.json"
 
 	content, err := ioutil.ReadFile(path)
@@ -82,36 +206,46 @@ func NewFlowClient(flowEnv string, customScriptsMap map[string]CustomScript) *Fl
 	}
 
 	adapter.Config = config
-	adapter.URL = config.Networks[adapter.Env]
-	adapter.ArchiveURL = config.Networks[fmt.Sprintf("%s_archive", adapter.Env)]
+	urls := config.NetworkURLs(adapter.Env)
+	archiveURLs := config.NetworkURLs(fmt.Sprintf("%s_archive", adapter.Env))
 
 	// Explicitly set when running test suite
 	if flag.Lookup("test.v") != nil {
-		adapter.URL = "127.0.0.1:3569"
-		adapter.ArchiveURL = "127.0.0.1:3569"
+		urls = []string{"127.0.0.1:3569"}
+		archiveURLs = []string{"127.0.0.1:3569"}
 	}
 
-	log.Info().Msgf("FLOW URL: %s", adapter.URL)
+	policy := FlowRetryPolicyFromEnv()
+
+	log.Info().Msgf("FLOW URLs: %v", urls)
 	// create flow client
-	FlowClient, err := client.New(adapter.URL, grpc.WithInsecure())
+	FlowClient, err := NewAccessNodeClient(urls, policy)
 	if err != nil {
-		log.Panic().Msgf("Failed to connect to %s.", adapter.URL)
+		log.Panic().Err(err).Msgf("Failed to connect to %v.", urls)
 	}
 
-	log.Info().Msgf("FLOW Archive URL: %s", adapter.ArchiveURL)
+	log.Info().Msgf("FLOW Archive URLs: %v", archiveURLs)
 
 	//create archive client
-	FlowClientArchive, err := client.New(adapter.ArchiveURL, grpc.WithInsecure())
+	FlowClientArchive, err := NewAccessNodeClient(archiveURLs, policy)
 	if err != nil {
-		log.Panic().Msgf("Failed to connect to %s.", adapter.ArchiveURL)
+		log.Panic().Err(err).Msgf("Failed to connect to %v.", archiveURLs)
 	}
 
+	adapter.URL = urls[0]
+	adapter.ArchiveURL = archiveURLs[0]
+
 	adapter.LiveClient = FlowClient
 	adapter.ArchiveClient = FlowClientArchive
 
 	return &adapter
 }
 
+func (fa *FlowAdapter) GetCustomScript(key string) (CustomScript, bool) {
+	script, ok := fa.CustomScriptsMap[key]
+	return script, ok
+}
+
 func (fa *FlowAdapter) GetAccountAtBlockHeight(addr string, blockheight uint64) (*flow.Account, error) {
 	hexAddr := flow.HexToAddress(addr)
 	return fa.ArchiveClient.GetAccountAtBlockHeight(fa.Context, hexAddr, blockheight)
@@ -128,33 +262,86 @@ func (fa *FlowAdapter) GetCurrentBlockHeight() (int, error) {
 func (fa *FlowAdapter) GetAddressBalanceAtBlockHeight(addr string, blockHeight uint64, balanceResponse *FTBalanceResponse, contract *Contract) error {
 
 	if *contract.Name == "FlowToken" {
-		balances, err := fa.GetFlowBalance(addr, blockHeight)
-		fmt.Println(balances)
+		balances, err := fa.GetFlowBalance(addr, blockHeight, contract.TokenDecimals())
 		if err != nil {
 			return err
 		}
-		balanceResponse.PrimaryAccountBalance = uint64(balances[0] * 100000000.0)
-		balanceResponse.SecondaryAccountBalance = uint64(balances[1] * 10000000.0)
-		balanceResponse.StakingBalance = uint64(balances[2] * 10000000.0)
+		balanceResponse.PrimaryAccountBalance = balances.Unlocked
+		balanceResponse.SecondaryAccountBalance = balances.Locked
+		balanceResponse.StakingBalance = balances.Sum(StakedBuckets)
+		balanceResponse.FlowBalance = balances
 
 		return nil
 
 	} else {
 		balance, err := fa.GetFTBalance(addr, blockHeight, *contract.Name, *contract.Addr, *contract.Public_path)
-		fmt.Println(balance)
 		if err != nil {
 			return err
 		}
-		balanceResponse.PrimaryAccountBalance = uint64(balance * 100000000.0)
+		balanceResponse.PrimaryAccountBalance = ToFixedPoint(balance, contract.TokenDecimals())
 		balanceResponse.SecondaryAccountBalance = 0
 		balanceResponse.StakingBalance = 0
 		return nil
 	}
 }
 
+const (
+	SignatureVerificationLocal  = "local"
+	SignatureVerificationScript = "script"
+)
+
+const DefaultAccountKeyTTL = time.Minute
+
 func (fa *FlowAdapter) ValidateSignature(address, message string, sigs *[]CompositeSignature, messageType string) error {
 	log.Debug().Msgf("ValidateSignature()\nAddress: %s\nMessage: %s\nSigs: %v.", address, message, *sigs)
 
+	if fa.SignatureVerification == SignatureVerificationScript {
+		return fa.validateSignatureWithScript(address, message, sigs, messageType)
+	}
+
+	valid, err := fa.validateSignatureLocally(address, message, *sigs, messageType)
+	if err != nil {
+		log.Warn().Err(err).Msg("Couldn't verify signature locally, running the validate signature script.")
+		return fa.validateSignatureWithScript(address, message, sigs, messageType)
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Verifies the signatures against the account's keys. Keys from the cache
+// may be stale, so when they don't verify the keys are fetched again.
+func (fa *FlowAdapter) validateSignatureLocally(address, message string, sigs []CompositeSignature, messageType string) (bool, error) {
+	flowAddress := flow.HexToAddress(address)
+	fetch := func() ([]*flow.AccountKey, error) {
+		account, err := fa.LiveClient.GetAccountAtLatestBlock(fa.Context, flowAddress)
+		if err != nil {
+			return nil, err
+		}
+		return account.Keys, nil
+	}
+
+	keys, cached, err := fa.AccountKeys.Get(flowAddress.Hex(), fetch)
+	if err != nil {
+		return false, err
+	}
+
+	valid, err := VerifyAccountSignatures(keys, message, sigs, messageType)
+	if err != nil || valid || !cached {
+		return valid, err
+	}
+
+	fa.AccountKeys.Invalidate(flowAddress.Hex())
+	keys, _, err = fa.AccountKeys.Get(flowAddress.Hex(), fetch)
+	if err != nil {
+		return false, err
+	}
+	return VerifyAccountSignatures(keys, message, sigs, messageType)
+}
+
+func (fa *FlowAdapter) validateSignatureWithScript(address, message string, sigs *[]CompositeSignature, messageType string) error {
+
 	// Prepare Script Args
 	flowAddress := flow.HexToAddress(address)
 	cadenceAddress := cadence.NewAddress(flowAddress)
@@ -175,11 +362,8 @@ func (fa *FlowAdapter) ValidateSignature(address, message string, sigs *[]Compos
 		domainSeparationTag = "FLOW-V0.0-user"
 	}
 
-	// Load script
-	script, err := ioutil.ReadFile("./main/cadence/scripts/validate_signature.cdc")
-
+	script, err := fa.Scripts.Source(ScriptValidateSignature)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
 		return err
 	}
 
@@ -198,6 +382,7 @@ func (fa *FlowAdapter) ValidateSignature(address, message string, sigs *[]Compos
 
 	log.Info().Msgf("Validate signature script returned: %v", value)
 
+	// ledger errors were already retried across the access nodes
 	if err != nil && strings.Contains(err.Error(), "ledger returns unsuccessful") {
 		log.Error().Err(err).Msg("signature validation error")
 		return errors.New("flow access node error, please cast your vote again")
@@ -225,17 +410,14 @@ func (fa *FlowAdapter) EnforceTokenThreshold(scriptPath, creatorAddr string, c *
 	cadenceAddress := cadence.NewAddress(flowAddress)
 	cadencePath := cadence.Path{Domain: "public", Identifier: *c.Public_path}
 	fmt.Println(cadencePath)
-	script, err := ioutil.ReadFile(scriptPath)
-	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
-		return false, err
-	}
-
 	var cadenceValue cadence.Value
 
-	if scriptPath == "./main/cadence/scripts/get_nfts_ids.cdc" {
+	if scriptPath == ScriptGetNFTIds {
 		isFungible := false
-		script = fa.ReplaceContractPlaceholders(string(script[:]), c, isFungible)
+		script, err := fa.script(scriptPath, c, isFungible)
+		if err != nil {
+			return false, err
+		}
 
 		//call the non-fungible token script to verify balance
 		cadenceValue, err = fa.LiveClient.ExecuteScriptAtLatestBlock(
@@ -255,7 +437,10 @@ func (fa *FlowAdapter) EnforceTokenThreshold(scriptPath, creatorAddr string, c *
 
 	} else {
 		isFungible := true
-		script = fa.ReplaceContractPlaceholders(string(script[:]), c, isFungible)
+		script, err := fa.script(scriptPath, c, isFungible)
+		if err != nil {
+			return false, err
+		}
 
 		//call the fungible-token script to verify balance
 		cadenceValue, err = fa.LiveClient.ExecuteScriptAtLatestBlock(
@@ -286,13 +471,14 @@ func (fa *FlowAdapter) EnforceTokenThreshold(scriptPath, creatorAddr string, c *
 	return true, nil
 }
 
-func (fa *FlowAdapter) GetFlowBalance(address string, blockHeight uint64) ([]float64, error) {
+// Reads an account's FLOW buckets as fixed-point balances with the given
+// decimals.
+func (fa *FlowAdapter) GetFlowBalance(address string, blockHeight uint64, decimals int) (*FlowBalance, error) {
 	flowAddress := flow.HexToAddress(address)
 	cadenceAddress := cadence.NewAddress(flowAddress)
-	script, err := ioutil.ReadFile("./main/cadence/scripts/get_total_balance.cdc")
+	script, err := fa.Scripts.Source(ScriptGetTotalBalance)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
-		return []float64{0, 0, 0}, err
+		return nil, err
 	}
 	cadenceValue, err := fa.ArchiveClient.ExecuteScriptAtBlockHeight(
 		fa.Context,
@@ -305,29 +491,33 @@ func (fa *FlowAdapter) GetFlowBalance(address string, blockHeight uint64) ([]flo
 
 	if err != nil {
 		log.Error().Err(err).Msg("Error executing Total balance Script.")
-		return []float64{0, 0, 0}, err
+		return nil, err
 	}
 
-	var values []interface{} = CadenceValueToInterface(cadenceValue).([]interface{})
-
-	balancePrimary, err := strconv.ParseFloat(values[0].(string), 64)
-	if err != nil {
-		log.Error().Err(err).Msg("Error converting cadence value to float. (balancePrimary)")
-		return []float64{0, 0, 0}, err
+	values, ok := CadenceValueToInterface(cadenceValue).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unexpected result from Total balance Script")
 	}
 
-	balanceSecondary, err := strconv.ParseFloat(values[1].(string), 64)
-	if err != nil {
-		log.Error().Err(err).Msg("Error converting cadence value to float. (balanceSecondary)")
-		return []float64{0, 0, 0}, err
+	balance := &FlowBalance{}
+	buckets := map[string]*uint64{
+		BucketUnlocked:   &balance.Unlocked,
+		BucketLocked:     &balance.Locked,
+		BucketNodeStaked: &balance.NodeStaked,
+		BucketDelegated:  &balance.Delegated,
+		BucketRewards:    &balance.Rewards,
+		BucketUnstaking:  &balance.Unstaking,
 	}
-
-	balanceStaked, err := strconv.ParseFloat(values[2].(string), 64)
-	if err != nil {
-		log.Error().Err(err).Msg("Error converting cadence value to float. (balanceStaked)")
-		return []float64{0, 0, 0}, err
+	for bucket, amount := range buckets {
+		value, _ := values[bucket].(string)
+		*amount, err = ParseFixedPoint(value, decimals)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error converting cadence value to fixed point. (%s)", bucket)
+			return nil, err
+		}
 	}
-	return []float64{balancePrimary, balanceSecondary, balanceStaked}, nil
+
+	return balance, nil
 }
 
 // @bluesign: this is called via archival node now
@@ -335,19 +525,16 @@ func (fa *FlowAdapter) GetFTBalance(address string, blockHeight uint64, contract
 	flowAddress := flow.HexToAddress(address)
 	cadenceAddress := cadence.NewAddress(flowAddress)
 
-	script, err := ioutil.ReadFile("./main/cadence/scripts/get_balance.cdc")
-	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
-		return 0, err
-	}
-
 	dummyContract := Contract{
 		Name:        &contractName,
 		Public_path: &publicPath,
 		Addr:        &contractAddress,
 	}
 
-	script = fa.ReplaceContractPlaceholders(string(script[:]), &dummyContract, true)
+	script, err := fa.script(ScriptGetBalance, &dummyContract, true)
+	if err != nil {
+		return 0, err
+	}
 	cadencePath := cadence.Path{Domain: "public", Identifier: *dummyContract.Public_path}
 	cadenceValue, err := fa.ArchiveClient.ExecuteScriptAtBlockHeight(
 		fa.Context,
@@ -372,20 +559,56 @@ func (fa *FlowAdapter) GetFTBalance(address string, blockHeight uint64, contract
 	return balance, nil
 }
 
-func (fa *FlowAdapter) GetNFTIds(voterAddr string, c *Contract, path string) ([]interface{}, error) {
-	flowAddress := flow.HexToAddress(voterAddr)
-	cadenceAddress := cadence.NewAddress(flowAddress)
+func (fa *FlowAdapter) GetTotalSupplyAtBlockHeight(c *Contract, blockHeight uint64) (float64, error) {
+	script, err := fa.script(ScriptGetTotalSupply, c, true)
+	if err != nil {
+		return 0, err
+	}
+	cadenceValue, err := fa.ArchiveClient.ExecuteScriptAtBlockHeight(
+		fa.Context,
+		blockHeight,
+		script,
+		[]cadence.Value{},
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Error executing total supply script.")
+		return 0, err
+	}
+
+	value := CadenceValueToInterface(cadenceValue)
+	totalSupply, err := strconv.ParseFloat(value.(string), 64)
+	if err != nil {
+		log.Error().Err(err).Msg("Error converting cadence value to float.")
+		return 0, err
+	}
+
+	return totalSupply, nil
+}
 
-	script, err := ioutil.ReadFile(path)
+// Gets the IDs of the NFTs the voter held at blockHeight, or at the latest
+// block if blockHeight is 0, by running the named script.
+func (fa *FlowAdapter) GetNFTIds(voterAddr string, c *Contract, name string, blockHeight uint64) ([]interface{}, error) {
+	script, err := fa.script(name, c, false)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
 		return nil, err
 	}
 
-	script = fa.ReplaceContractPlaceholders(string(script[:]), c, false)
+	return fa.getNFTIds(voterAddr, c, script, blockHeight)
+}
 
-	cadenceValue, err := fa.LiveClient.ExecuteScriptAtLatestBlock(
-		fa.Context,
+// Runs a script with the NFTIdsScriptSignature against the voter's address.
+func (fa *FlowAdapter) GetNFTIdsFromScript(voterAddr string, c *Contract, script []byte, blockHeight uint64) ([]interface{}, error) {
+	script = fa.substitute(sourceKey(script), script, c, false)
+	return fa.getNFTIds(voterAddr, c, script, blockHeight)
+}
+
+func (fa *FlowAdapter) getNFTIds(voterAddr string, c *Contract, script []byte, blockHeight uint64) ([]interface{}, error) {
+	flowAddress := flow.HexToAddress(voterAddr)
+	cadenceAddress := cadence.NewAddress(flowAddress)
+
+	cadenceValue, err := fa.executeScriptAtBlockHeight(
+		c,
+		blockHeight,
 		script,
 		[]cadence.Value{
 			cadenceAddress,
@@ -398,25 +621,93 @@ func (fa *FlowAdapter) GetNFTIds(voterAddr string, c *Contract, path string) ([]
 
 	value := CadenceValueToInterface(cadenceValue)
 
-	nftIds := value.([]interface{})
+	nftIds, ok := value.([]interface{})
+	if !ok && value != nil {
+		return nil, fmt.Errorf("script returned %T, expected an array of NFT IDs", value)
+	}
 	return nftIds, nil
 }
 
-func (fa *FlowAdapter) GetFloatNFTIds(voterAddr string, c *Contract) ([]interface{}, error) {
+const NFTIdsScriptSignature = "main(address: Address): [UInt64]"
+
+// Statically checks that a script parses and declares the main function
+// the NFT strategies call, without running it.
+func ValidateNFTIdsScript(code string) error {
+	program, err := parser.ParseProgram(code, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range program.FunctionDeclarations() {
+		if f.Identifier.Identifier != "main" {
+			continue
+		}
+
+		params := f.ParameterList.Parameters
+		if len(params) != 1 ||
+			params[0].TypeAnnotation.Type.String() != "Address" ||
+			f.ReturnTypeAnnotation == nil ||
+			f.ReturnTypeAnnotation.Type.String() != "[UInt64]" {
+			return fmt.Errorf("script must declare %s", NFTIdsScriptSignature)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("script must declare %s", NFTIdsScriptSignature)
+}
+
+// Gets the MetadataViews traits of each NFT the voter held at blockHeight,
+// keyed by NFT ID. Trait values are returned as strings.
+func (fa *FlowAdapter) GetNFTTraits(voterAddr string, c *Contract, blockHeight uint64) (map[string]map[string]string, error) {
 	flowAddress := flow.HexToAddress(voterAddr)
 	cadenceAddress := cadence.NewAddress(flowAddress)
-	cadenceUInt64 := cadence.NewUInt64(*c.Float_event_id)
 
-	script, err := ioutil.ReadFile("./main/cadence/float/scripts/get_float_ids.cdc")
+	script, err := fa.script(ScriptGetNFTTraits, c, false)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
 		return nil, err
 	}
 
-	script = fa.ReplaceContractPlaceholders(string(script[:]), c, false)
+	cadenceValue, err := fa.executeScriptAtBlockHeight(
+		c,
+		blockHeight,
+		script,
+		[]cadence.Value{
+			cadenceAddress,
+		},
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Error executing script.")
+		return nil, err
+	}
 
-	cadenceValue, err := fa.LiveClient.ExecuteScriptAtLatestBlock(
-		fa.Context,
+	nfts := map[string]map[string]string{}
+	value, _ := CadenceValueToInterface(cadenceValue).(map[string]interface{})
+	for id, nftTraits := range value {
+		traits := map[string]string{}
+		if t, ok := nftTraits.(map[string]interface{}); ok {
+			for name, traitValue := range t {
+				traits[name] = fmt.Sprintf("%v", traitValue)
+			}
+		}
+		nfts[id] = traits
+	}
+
+	return nfts, nil
+}
+
+func (fa *FlowAdapter) GetFloatNFTIds(voterAddr string, c *Contract, blockHeight uint64) ([]interface{}, error) {
+	flowAddress := flow.HexToAddress(voterAddr)
+	cadenceAddress := cadence.NewAddress(flowAddress)
+	cadenceUInt64 := cadence.NewUInt64(*c.Float_event_id)
+
+	script, err := fa.script(ScriptGetFloatIds, c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cadenceValue, err := fa.executeScriptAtBlockHeight(
+		c,
+		blockHeight,
 		script,
 		[]cadence.Value{
 			cadenceAddress,
@@ -433,21 +724,19 @@ func (fa *FlowAdapter) GetFloatNFTIds(voterAddr string, c *Contract) ([]interfac
 	return nftIds, nil
 }
 
-func (fa *FlowAdapter) CheckIfUserHasEvent(voterAddr string, c *Contract) (bool, error) {
+func (fa *FlowAdapter) CheckIfUserHasEvent(voterAddr string, c *Contract, blockHeight uint64) (bool, error) {
 	flowAddress := flow.HexToAddress(voterAddr)
 	cadenceAddress := cadence.NewAddress(flowAddress)
 	cadenceUInt64 := cadence.NewUInt64(*c.Float_event_id)
 
-	script, err := ioutil.ReadFile("./main/cadence/float/scripts/owns_specific_float.cdc")
+	script, err := fa.script(ScriptOwnsSpecificFloat, c, false)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
 		return false, err
 	}
 
-	script = fa.ReplaceContractPlaceholders(string(script[:]), c, false)
-
-	cadenceValue, err := fa.LiveClient.ExecuteScriptAtLatestBlock(
-		fa.Context,
+	cadenceValue, err := fa.executeScriptAtBlockHeight(
+		c,
+		blockHeight,
 		script,
 		[]cadence.Value{
 			cadenceAddress,
@@ -467,37 +756,76 @@ func (fa *FlowAdapter) CheckIfUserHasEvent(voterAddr string, c *Contract) (bool,
 	return hasEventNFT, nil
 }
 
-func (fa *FlowAdapter) GetEventNFT(voterAddr string, c *Contract) (interface{}, error) {
+// Returned when the archive node no longer serves a proposal's snapshot.
+var ErrSnapshotUnavailable = errors.New("snapshot block height is no longer available")
 
-	//first we get all the floats a user owns
+// Runs a script against the state at blockHeight on the archive node, or at
+// the latest block if blockHeight is 0. Archive nodes only serve a window of
+// recent heights. When the height is no longer available the script fails
+// with ErrSnapshotUnavailable, unless the contract opted in to the latest
+// block, which reads current state instead of the snapshot.
+func (fa *FlowAdapter) executeScriptAtBlockHeight(
+	c *Contract,
+	blockHeight uint64,
+	script []byte,
+	args []cadence.Value,
+) (cadence.Value, error) {
+	if blockHeight == 0 {
+		return fa.LiveClient.ExecuteScriptAtLatestBlock(fa.Context, script, args)
+	}
 
-	flowAddress := flow.HexToAddress(voterAddr)
-	cadenceAddress := cadence.NewAddress(flowAddress)
-	cadenceUInt64 := cadence.NewUInt64(*c.Float_event_id)
+	value, err := fa.ArchiveClient.ExecuteScriptAtBlockHeight(fa.Context, blockHeight, script, args)
+	if err == nil || !isBlockHeightUnavailable(err) {
+		return value, err
+	}
 
-	script, err := ioutil.ReadFile("./main/cadence/float/scripts/get_specific_float.cdc")
-	if err != nil {
-		log.Error().Err(err).Msgf("Error reading cadence script file.")
-		return nil, err
+	if !c.AllowsLatestBlockFallback() {
+		return nil, fmt.Errorf("%w: block height %d: %v", ErrSnapshotUnavailable, blockHeight, err)
 	}
 
-	script = fa.ReplaceContractPlaceholders(string(script[:]), c, false)
+	log.Warn().Err(err).Msgf("Block height %d is not available, running script at the latest block.", blockHeight)
+	return fa.LiveClient.ExecuteScriptAtLatestBlock(fa.Context, script, args)
+}
 
-	cadenceValue, err := fa.LiveClient.ExecuteScriptAtLatestBlock(
-		fa.Context,
-		script,
-		[]cadence.Value{
-			cadenceAddress,
-			cadenceUInt64,
-		})
+func isBlockHeightUnavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.NotFound, codes.OutOfRange:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the named script with its placeholders substituted for the
+// contract, cached per network and contract.
+func (fa *FlowAdapter) script(name string, c *Contract, isFungible bool) ([]byte, error) {
+	code, err := fa.Scripts.Source(name)
 	if err != nil {
-		log.Error().Err(err).Msg("Error executing script.")
 		return nil, err
 	}
+	return fa.substitute(name, code, c, isFungible), nil
+}
 
-	value := CadenceValueToInterface(cadenceValue)
+func (fa *FlowAdapter) substitute(source string, code []byte, c *Contract, isFungible bool) []byte {
+	key := strings.Join([]string{
+		fa.Env,
+		source,
+		strconv.FormatBool(isFungible),
+		stringValue(c.Name),
+		stringValue(c.Addr),
+		stringValue(c.Public_path),
+	}, "|")
+
+	return fa.Scripts.Substituted(key, func() []byte {
+		return fa.ReplaceContractPlaceholders(string(code), c, isFungible)
+	})
+}
 
-	return value, nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 func (fa *FlowAdapter) ReplaceContractPlaceholders(code string, c *Contract, isFungible bool) []byte {
@@ -508,10 +836,10 @@ func (fa *FlowAdapter) ReplaceContractPlaceholders(code string, c *Contract, isF
 		topshotAddr          string
 	)
 
-	nonFungibleTokenAddr = fa.Config.Contracts["NonFungibleToken"].Aliases[os.Getenv("FLOW_ENV")]
-	fungibleTokenAddr = fa.Config.Contracts["FungibleToken"].Aliases[os.Getenv("FLOW_ENV")]
-	metadataViewsAddr = fa.Config.Contracts["MetadataViews"].Aliases[os.Getenv("FLOW_ENV")]
-	topshotAddr = fa.Config.Contracts["TopShot"].Aliases[os.Getenv("FLOW_ENV")]
+	nonFungibleTokenAddr = fa.Config.Contracts["NonFungibleToken"].Aliases[fa.Env]
+	fungibleTokenAddr = fa.Config.Contracts["FungibleToken"].Aliases[fa.Env]
+	metadataViewsAddr = fa.Config.Contracts["MetadataViews"].Aliases[fa.Env]
+	topshotAddr = fa.Config.Contracts["TopShot"].Aliases[fa.Env]
 
 	if isFungible {
 		code = placeholderFungibleTokenAddr.ReplaceAllString(code, fungibleTokenAddr)
@@ -530,7 +858,7 @@ func (fa *FlowAdapter) ReplaceContractPlaceholders(code string, c *Contract, isF
 
 func WaitForSeal(
 	ctx context.Context,
-	c *client.Client,
+	c *AccessNodeClient,
 	id flow.Identifier,
 ) (*flow.TransactionResult, *flow.Transaction, error) {
 	result, err := c.GetTransactionResult(ctx, id)
@@ -593,6 +921,28 @@ func CadenceValueToInterface(field cadence.Value) interface{} {
 	}
 }
 
-func FloatBalanceToUint(balance float64) uint64 {
-	return uint64(balance * 1000000)
+// Converts a token amount to its fixed-point balance.
+func ToFixedPoint(amount float64, decimals int) uint64 {
+	return uint64(math.Round(amount * math.Pow10(decimals)))
+}
+
+// Parses a decimal token amount, such as a UFix64 returned by a script,
+// straight to its fixed-point balance. Digits past decimals are dropped.
+func ParseFixedPoint(amount string, decimals int) (uint64, error) {
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if len(fraction) > decimals {
+		fraction = fraction[:decimals]
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	balance, err := strconv.ParseUint(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid token amount %q: %w", amount, err)
+	}
+	return balance, nil
+}
+
+// Converts a fixed-point balance back to a token amount.
+func FromFixedPoint(balance uint64, decimals int) float64 {
+	return float64(balance) / math.Pow10(decimals)
 }
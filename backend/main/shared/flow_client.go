@@ -0,0 +1,291 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/client"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Timeout and retry settings for calls to Flow access nodes.
+type FlowRetryPolicy struct {
+	// Deadline for each attempt
+	Timeout time.Duration
+	// Attempts made after the first one fails with a transient error
+	Retries int
+	// Wait before the first retry, doubled for every retry after it
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// How long a node that failed is skipped before it's tried again
+	Cooldown time.Duration
+}
+
+var DefaultFlowRetryPolicy = FlowRetryPolicy{
+	Timeout:    10 * time.Second,
+	Retries:    3,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 2 * time.Second,
+	Cooldown:   30 * time.Second,
+}
+
+// Reads the retry policy from FLOW_TIMEOUT, FLOW_RETRIES, FLOW_RETRY_BACKOFF
+// and FLOW_NODE_COOLDOWN, using the defaults for anything unset or invalid.
+func FlowRetryPolicyFromEnv() FlowRetryPolicy {
+	policy := DefaultFlowRetryPolicy
+	durations := map[string]*time.Duration{
+		"FLOW_TIMEOUT":       &policy.Timeout,
+		"FLOW_RETRY_BACKOFF": &policy.Backoff,
+		"FLOW_NODE_COOLDOWN": &policy.Cooldown,
+	}
+	for env, d := range durations {
+		if v := os.Getenv(env); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				log.Error().Err(err).Msgf("Invalid %s, using the default of %s.", env, *d)
+				continue
+			}
+			*d = parsed
+		}
+	}
+	if v := os.Getenv("FLOW_RETRIES"); v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil || retries < 0 {
+			log.Error().Msgf("Invalid FLOW_RETRIES, using the default of %d.", policy.Retries)
+		} else {
+			policy.Retries = retries
+		}
+	}
+	return policy
+}
+
+type accessNode struct {
+	url       string
+	client    *client.Client
+	downUntil time.Time
+}
+
+// AccessNodeClient calls one of several access nodes for the same network.
+// Calls go to the current node until it fails with a transient error, then
+// the node is skipped for the cooldown and the call is retried on the next.
+type AccessNodeClient struct {
+	Policy FlowRetryPolicy
+
+	mu      sync.Mutex
+	nodes   []*accessNode
+	current int
+}
+
+func NewAccessNodeClient(urls []string, policy FlowRetryPolicy) (*AccessNodeClient, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no access node urls")
+	}
+
+	c := &AccessNodeClient{Policy: policy}
+	for _, url := range urls {
+		fc, err := client.New(url, grpc.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		c.nodes = append(c.nodes, &accessNode{url: url, client: fc})
+	}
+	return c, nil
+}
+
+// Returns the URLs of the nodes currently in use, the node calls go to first.
+func (c *AccessNodeClient) URLs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	urls := make([]string, len(c.nodes))
+	for i := range c.nodes {
+		urls[i] = c.nodes[(c.current+i)%len(c.nodes)].url
+	}
+	return urls
+}
+
+func (c *AccessNodeClient) ExecuteScriptAtLatestBlock(
+	ctx context.Context,
+	script []byte,
+	args []cadence.Value,
+) (cadence.Value, error) {
+	var value cadence.Value
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		value, err = fc.ExecuteScriptAtLatestBlock(ctx, script, args)
+		return err
+	})
+	return value, err
+}
+
+func (c *AccessNodeClient) ExecuteScriptAtBlockHeight(
+	ctx context.Context,
+	height uint64,
+	script []byte,
+	args []cadence.Value,
+) (cadence.Value, error) {
+	var value cadence.Value
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		value, err = fc.ExecuteScriptAtBlockHeight(ctx, height, script, args)
+		return err
+	})
+	return value, err
+}
+
+func (c *AccessNodeClient) GetLatestBlock(ctx context.Context, isSealed bool) (*flow.Block, error) {
+	var block *flow.Block
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		block, err = fc.GetLatestBlock(ctx, isSealed)
+		return err
+	})
+	return block, err
+}
+
+func (c *AccessNodeClient) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
+	var header *flow.BlockHeader
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		header, err = fc.GetLatestBlockHeader(ctx, isSealed)
+		return err
+	})
+	return header, err
+}
+
+func (c *AccessNodeClient) GetAccountAtLatestBlock(ctx context.Context, address flow.Address) (*flow.Account, error) {
+	var account *flow.Account
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		account, err = fc.GetAccountAtLatestBlock(ctx, address)
+		return err
+	})
+	return account, err
+}
+
+func (c *AccessNodeClient) GetAccountAtBlockHeight(
+	ctx context.Context,
+	address flow.Address,
+	height uint64,
+) (*flow.Account, error) {
+	var account *flow.Account
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		account, err = fc.GetAccountAtBlockHeight(ctx, address, height)
+		return err
+	})
+	return account, err
+}
+
+func (c *AccessNodeClient) GetTransactionResult(ctx context.Context, id flow.Identifier) (*flow.TransactionResult, error) {
+	var result *flow.TransactionResult
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		result, err = fc.GetTransactionResult(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (c *AccessNodeClient) GetTransaction(ctx context.Context, id flow.Identifier) (*flow.Transaction, error) {
+	var tx *flow.Transaction
+	err := c.do(ctx, func(ctx context.Context, fc *client.Client) (err error) {
+		tx, err = fc.GetTransaction(ctx, id)
+		return err
+	})
+	return tx, err
+}
+
+// Runs call against a healthy node with the policy's timeout, retrying
+// transient errors on the next node with exponential backoff.
+func (c *AccessNodeClient) do(ctx context.Context, call func(context.Context, *client.Client) error) error {
+	backoff := c.Policy.Backoff
+	var err error
+
+	for attempt := 0; attempt <= c.Policy.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if c.Policy.MaxBackoff > 0 && backoff > c.Policy.MaxBackoff {
+				backoff = c.Policy.MaxBackoff
+			}
+		}
+
+		node := c.pick()
+		callCtx, cancel := c.withTimeout(ctx)
+		err = call(callCtx, node.client)
+		cancel()
+
+		if err == nil || !IsTransientFlowError(err) {
+			return err
+		}
+
+		log.Warn().Err(err).Msgf("Access node %s failed, attempt %d of %d.", node.url, attempt+1, c.Policy.Retries+1)
+		c.markDown(node)
+	}
+
+	return err
+}
+
+func (c *AccessNodeClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Policy.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Policy.Timeout)
+}
+
+// Returns the current node if it's healthy, otherwise fails over to the next
+// healthy node. When every node is down the one that recovers first is used.
+func (c *AccessNodeClient) pick() *accessNode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	next := c.current
+	for i := 0; i < len(c.nodes); i++ {
+		idx := (c.current + i) % len(c.nodes)
+		if !c.nodes[idx].downUntil.After(now) {
+			next = idx
+			break
+		}
+		if c.nodes[idx].downUntil.Before(c.nodes[next].downUntil) {
+			next = idx
+		}
+	}
+
+	if next != c.current {
+		log.Info().Msgf("Failing over to access node %s.", c.nodes[next].url)
+		c.current = next
+	}
+	return c.nodes[next]
+}
+
+func (c *AccessNodeClient) markDown(node *accessNode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node.downUntil = time.Now().Add(c.Policy.Cooldown)
+}
+
+// Transient errors are the ones worth retrying on another node: the node is
+// unavailable, overloaded or timed out, or its execution node couldn't read
+// the ledger.
+func IsTransientFlowError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	case codes.Internal, codes.Unknown:
+		return strings.Contains(err.Error(), "ledger returns unsuccessful")
+	default:
+		return false
+	}
+}
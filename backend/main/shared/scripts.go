@@ -0,0 +1,134 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+
+	"github.com/DapperCollectives/CAST/backend/main/cadence"
+	"github.com/onflow/cadence/runtime/parser"
+)
+
+// Scripts embedded in the cadence package, by path.
+const (
+	ScriptGetBalance        = "scripts/get_balance.cdc"
+	ScriptGetTotalBalance   = "scripts/get_total_balance.cdc"
+	ScriptGetTotalSupply    = "scripts/get_total_supply.cdc"
+	ScriptGetNFTIds         = "scripts/get_nfts_ids.cdc"
+	ScriptGetNFTTraits      = "scripts/get_nft_traits.cdc"
+	ScriptValidateSignature = "scripts/validate_signature.cdc"
+	ScriptGetFloatIds       = "float/scripts/get_float_ids.cdc"
+	ScriptOwnsSpecificFloat = "float/scripts/owns_specific_float.cdc"
+
+	CustomScriptsDir  = "scripts/custom"
+	CustomScriptsJSON = "scripts/custom/scripts.json"
+)
+
+var adapterScripts = []string{
+	ScriptGetBalance,
+	ScriptGetTotalBalance,
+	ScriptGetTotalSupply,
+	ScriptGetNFTIds,
+	ScriptGetNFTTraits,
+	ScriptValidateSignature,
+	ScriptGetFloatIds,
+	ScriptOwnsSpecificFloat,
+}
+
+// Reads an embedded script.
+func ReadScript(name string) ([]byte, error) {
+	return cadence.Scripts.ReadFile(name)
+}
+
+// The path of a scripts.json custom script.
+func CustomScriptPath(script CustomScript) string {
+	return path.Join(CustomScriptsDir, script.Src)
+}
+
+// ScriptCache holds the adapter's scripts, and the code substituted for
+// each contract and network they've been run against.
+type ScriptCache struct {
+	mu          sync.RWMutex
+	sources     map[string][]byte
+	substituted map[string][]byte
+}
+
+// Loads the adapter's scripts and the scripts.json custom scripts, checking
+// that each one exists and parses once its placeholders are filled in.
+func LoadScripts(customScriptsMap map[string]CustomScript) (*ScriptCache, error) {
+	sc := &ScriptCache{
+		sources:     map[string][]byte{},
+		substituted: map[string][]byte{},
+	}
+
+	names := append([]string{}, adapterScripts...)
+	for _, script := range customScriptsMap {
+		names = append(names, CustomScriptPath(script))
+	}
+
+	for _, name := range names {
+		code, err := ReadScript(name)
+		if err != nil {
+			return nil, fmt.Errorf("missing script %s: %w", name, err)
+		}
+		if _, err := parser.ParseProgram(fillPlaceholders(string(code)), nil); err != nil {
+			return nil, fmt.Errorf("invalid script %s: %w", name, err)
+		}
+		sc.sources[name] = code
+	}
+
+	return sc, nil
+}
+
+func (sc *ScriptCache) Source(name string) ([]byte, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	code, ok := sc.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("script %s was not loaded", name)
+	}
+	return code, nil
+}
+
+// Returns the code cached under key, substituting and caching it first if
+// it isn't cached yet.
+func (sc *ScriptCache) Substituted(key string, substitute func() []byte) []byte {
+	sc.mu.RLock()
+	code, ok := sc.substituted[key]
+	sc.mu.RUnlock()
+	if ok {
+		return code
+	}
+
+	code = substitute()
+	sc.mu.Lock()
+	sc.substituted[key] = code
+	sc.mu.Unlock()
+	return code
+}
+
+func sourceKey(code []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(code))
+}
+
+// Fills every placeholder with a dummy value, so a script can be parsed
+// without a contract.
+func fillPlaceholders(code string) string {
+	for _, placeholder := range []struct {
+		pattern *regexp.Regexp
+		value   string
+	}{
+		{placeholderFungibleTokenAddr, "0x01"},
+		{placeholderNonFungibleTokenAddr, "0x01"},
+		{placeholderMetadataViewsAddr, "0x01"},
+		{placeholderTopshotAddr, "0x01"},
+		{placeholderTokenAddr, "0x01"},
+		{placeholderTokenName, "Token"},
+		{placeholderCollectionPublicPath, "collection"},
+	} {
+		code = placeholder.pattern.ReplaceAllString(code, placeholder.value)
+	}
+	return code
+}
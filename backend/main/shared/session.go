@@ -0,0 +1,166 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultSessionTTL        = 15 * time.Minute
+	DefaultSessionRefreshTTL = 24 * time.Hour
+)
+
+var ErrInvalidSessionToken = errors.New("invalid session token")
+
+// Claims of a session token. The token is a JWT signed with HS256.
+type SessionClaims struct {
+	Addr       string `json:"sub"`
+	Session_id string `json:"sid"`
+	Issued_at  int64  `json:"iat"`
+	Expires_at int64  `json:"exp"`
+}
+
+// SessionTokens issues and verifies the short lived tokens handed out at
+// sign in. Tokens last TTL, the session they belong to lasts RefreshTTL and
+// is extended every time it's refreshed.
+type SessionTokens struct {
+	TTL        time.Duration
+	RefreshTTL time.Duration
+
+	secret []byte
+}
+
+func NewSessionTokens(secret []byte, ttl, refreshTTL time.Duration) *SessionTokens {
+	return &SessionTokens{TTL: ttl, RefreshTTL: refreshTTL, secret: secret}
+}
+
+// Reads SESSION_SECRET, SESSION_TTL and SESSION_REFRESH_TTL. The secret is
+// required outside DEV and TEST, where a random one is used without it, so
+// sessions don't outlive the process.
+func SessionTokensFromEnv() (*SessionTokens, error) {
+	ttl := DefaultSessionTTL
+	refreshTTL := DefaultSessionRefreshTTL
+	durations := map[string]*time.Duration{
+		"SESSION_TTL":         &ttl,
+		"SESSION_REFRESH_TTL": &refreshTTL,
+	}
+	for env, d := range durations {
+		if v := os.Getenv(env); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*d = parsed
+		}
+	}
+
+	secret := []byte(os.Getenv("SESSION_SECRET"))
+	if len(secret) == 0 {
+		if env := os.Getenv("APP_ENV"); env != "TEST" && env != "DEV" {
+			return nil, errors.New("SESSION_SECRET must be set outside DEV and TEST")
+		}
+		log.Warn().Msg("SESSION_SECRET is not set, sessions will end when the server restarts.")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSessionTokens(secret, ttl, refreshTTL), nil
+}
+
+var sessionTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Returns a token for the session and when it expires.
+func (st *SessionTokens) Issue(addr, sessionId string) (string, time.Time, error) {
+	now := time.Now().UTC()
+	expires := now.Add(st.TTL)
+	claims, err := json.Marshal(SessionClaims{
+		Addr:       addr,
+		Session_id: sessionId,
+		Issued_at:  now.Unix(),
+		Expires_at: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := sessionTokenHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + st.sign(unsigned), expires, nil
+}
+
+// Verifies the token's signature and expiry and returns its claims.
+func (st *SessionTokens) Parse(token string) (SessionClaims, error) {
+	var claims SessionClaims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != sessionTokenHeader {
+		return claims, ErrInvalidSessionToken
+	}
+	if !hmac.Equal([]byte(st.sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return claims, ErrInvalidSessionToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrInvalidSessionToken
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, ErrInvalidSessionToken
+	}
+	if time.Now().UTC().Unix() >= claims.Expires_at {
+		return claims, errors.New("session token has expired")
+	}
+
+	return claims, nil
+}
+
+func (st *SessionTokens) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, st.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Returns an opaque token used to refresh a session. Only its hash is stored.
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns a random nonce for a signed request.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// The message a wallet signs to authorize an action on a resource.
+func ActionMessage(action, resource, nonce, timestamp string) string {
+	return fmt.Sprintf("CAST %s\nResource: %s\nNonce: %s\nTimestamp: %s", action, resource, nonce, timestamp)
+}
+
+// The message a wallet signs to start a session.
+func SignInMessage(addr, nonce, timestamp string) string {
+	return fmt.Sprintf("Sign in to CAST\nAddress: %s\nNonce: %s\nTimestamp: %s", addr, nonce, timestamp)
+}
@@ -0,0 +1,146 @@
+package shared
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// Signatures are valid once the keys that signed carry full account weight.
+const SignatureWeightThreshold = 1000
+
+func domainTag(messageType string) []byte {
+	if messageType == "TRANSACTION" {
+		return flow.TransactionDomainTag[:]
+	}
+	return flow.UserDomainTag[:]
+}
+
+// Verifies hex encoded signatures of a hex encoded message against the
+// account's keys, the same way validate_signature.cdc does on chain.
+func VerifyAccountSignatures(
+	keys []*flow.AccountKey,
+	message string,
+	sigs []CompositeSignature,
+	messageType string,
+) (bool, error) {
+	messageBytes, err := hex.DecodeString(message)
+	if err != nil {
+		return false, err
+	}
+	signedData := append(domainTag(messageType), messageBytes...)
+
+	// a key counts once however many times it signed
+	signed := map[int]bool{}
+	totalWeight := 0
+	for _, sig := range sigs {
+		key := accountKey(keys, int(sig.Key_id))
+		if key == nil {
+			return false, nil
+		}
+		if key.Revoked || signed[key.Index] {
+			continue
+		}
+
+		signature, err := hex.DecodeString(sig.Signature)
+		if err != nil {
+			return false, err
+		}
+		hasher, err := crypto.NewHasher(key.HashAlgo)
+		if err != nil {
+			return false, err
+		}
+
+		valid, err := key.PublicKey.Verify(signature, signedData, hasher)
+		if err != nil {
+			return false, err
+		}
+		if valid {
+			signed[key.Index] = true
+			totalWeight += key.Weight
+			if totalWeight >= SignatureWeightThreshold {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func accountKey(keys []*flow.AccountKey, index int) *flow.AccountKey {
+	for _, key := range keys {
+		if key.Index == index {
+			return key
+		}
+	}
+	return nil
+}
+
+// AccountKeyCache caches account keys by address for TTL, so verifying a
+// signature doesn't fetch the account on every request. Expired entries are
+// swept at most once per TTL, when keys are added.
+type AccountKeyCache struct {
+	TTL time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cachedAccountKeys
+	lastSweep time.Time
+}
+
+type cachedAccountKeys struct {
+	keys    []*flow.AccountKey
+	expires time.Time
+}
+
+func NewAccountKeyCache(ttl time.Duration) *AccountKeyCache {
+	return &AccountKeyCache{TTL: ttl, entries: map[string]cachedAccountKeys{}}
+}
+
+// Returns the account's cached keys, or fetches and caches them. Reports
+// whether the keys came from the cache.
+func (kc *AccountKeyCache) Get(
+	address string,
+	fetch func() ([]*flow.AccountKey, error),
+) ([]*flow.AccountKey, bool, error) {
+	kc.mu.Lock()
+	entry, ok := kc.entries[address]
+	kc.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.keys, true, nil
+	}
+
+	keys, err := fetch()
+	if err != nil {
+		return nil, false, err
+	}
+
+	kc.mu.Lock()
+	now := time.Now()
+	if now.Sub(kc.lastSweep) >= kc.TTL {
+		for addr, entry := range kc.entries {
+			if !now.Before(entry.expires) {
+				delete(kc.entries, addr)
+			}
+		}
+		kc.lastSweep = now
+	}
+	kc.entries[address] = cachedAccountKeys{keys: keys, expires: now.Add(kc.TTL)}
+	kc.mu.Unlock()
+	return keys, false, nil
+}
+
+// Returns the number of cached accounts, expired or not.
+func (kc *AccountKeyCache) Len() int {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	return len(kc.entries)
+}
+
+func (kc *AccountKeyCache) Invalidate(address string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	delete(kc.entries, address)
+}
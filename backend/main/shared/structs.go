@@ -10,7 +10,12 @@ import (
 )
 
 type Config struct {
-	Features map[string]bool `default:"useCorsMiddleware:false,validateTimestamps:true,validateAllowlist:true,validateBlocklist:true,validateSigs:true"`
+	Features map[string]bool `default:"useCorsMiddleware:false,validateTimestamps:true,validateAllowlist:true,validateBlocklist:true,validateSigs:true,validateNonces:true,useRateLimits:true,trustForwardedFor:false"`
+	// Requests allowed per window on rate limited routes, e.g. upload:10/1m
+	RateLimits map[string]string `envconfig:"RATE_LIMITS" default:"upload:10/1m,search:60/1m,vote:30/1m,nonce:30/1m"`
+	// Proxies in front of the server that append to X-Forwarded-For, used
+	// with the trustForwardedFor feature
+	Forwarded_hops int `envconfig:"FORWARDED_HOPS" default:"1"`
 }
 
 type Database struct {
@@ -21,7 +26,7 @@ type Database struct {
 }
 
 type StrategyStruct struct {
-	FlowAdapter *FlowAdapter
+	FlowAdapter ChainReader
 	DB          *Database
 }
 
@@ -61,6 +66,20 @@ type TimestampSignaturePayload struct {
 	Composite_signatures *[]CompositeSignature `json:"compositeSignatures"`
 	Signing_addr         string                `json:"signingAddr"`
 	Timestamp            string                `json:"timestamp"`
+	// Server issued nonce the signature covers
+	Nonce string `json:"nonce,omitempty"`
+	// Set when the request carries a session token, which stands in for
+	// the signed timestamp
+	Session_addr string `json:"-"`
+}
+
+// Makes the session's address the signer, if there is a session.
+func (p *TimestampSignaturePayload) UseSession(addr string) {
+	if addr == "" {
+		return
+	}
+	p.Signing_addr = addr
+	p.Session_addr = addr
 }
 
 // used in models/proposal.go
@@ -80,20 +99,80 @@ type MintParams struct {
 }
 
 type FTBalanceResponse struct {
-	ID                      string    `json:"id,omitempty"`
-	FungibleTokenID         string    `json:"fungibleTokenId"`
-	Addr                    string    `json:"addr"`
-	PrimaryAccountBalance   uint64    `json:"primaryAccountBalance"`
-	SecondaryAddress        string    `json:"secondaryAddress"`
-	SecondaryAccountBalance uint64    `json:"secondaryAccountBalance"`
-	Balance                 uint64    `json:"balance"`
-	StakingBalance          uint64    `json:"stakingBalance"`
-	ScriptResult            string    `json:"scriptResult"`
-	Stakes                  []string  `json:"stakes"`
-	BlockHeight             uint64    `json:"blockHeight"`
-	Proposal_id             int       `json:"proposal_id"`
-	NFTCount                int       `json:"nftCount"`
-	CreatedAt               time.Time `json:"createdAt"`
+	ID                      string       `json:"id,omitempty"`
+	FungibleTokenID         string       `json:"fungibleTokenId"`
+	Addr                    string       `json:"addr"`
+	PrimaryAccountBalance   uint64       `json:"primaryAccountBalance"`
+	SecondaryAddress        string       `json:"secondaryAddress"`
+	SecondaryAccountBalance uint64       `json:"secondaryAccountBalance"`
+	Balance                 uint64       `json:"balance"`
+	StakingBalance          uint64       `json:"stakingBalance"`
+	ScriptResult            string       `json:"scriptResult"`
+	Stakes                  []string     `json:"stakes"`
+	BlockHeight             uint64       `json:"blockHeight"`
+	Proposal_id             int          `json:"proposal_id"`
+	NFTCount                int          `json:"nftCount"`
+	CreatedAt               time.Time    `json:"createdAt"`
+	FlowBalance             *FlowBalance `json:"flowBalance,omitempty"`
+}
+
+const (
+	BucketUnlocked   = "unlocked"
+	BucketLocked     = "locked"
+	BucketNodeStaked = "nodeStaked"
+	BucketDelegated  = "delegated"
+	BucketRewards    = "rewards"
+	BucketUnstaking  = "unstaking"
+)
+
+var FlowBuckets = []string{
+	BucketUnlocked, BucketLocked, BucketNodeStaked, BucketDelegated, BucketRewards, BucketUnstaking,
+}
+
+// The buckets counted as the staking balance.
+var StakedBuckets = []string{BucketNodeStaked, BucketDelegated, BucketRewards, BucketUnstaking}
+
+// FLOW held by an account, split by where the tokens are. Locked tokens are
+// the balance of the locked account, tokens staked or delegated from it
+// count in the staking buckets. Amounts are fixed point, scaled by the
+// FlowToken contract's decimals like the other balances.
+type FlowBalance struct {
+	Unlocked   uint64 `json:"unlocked"`
+	Locked     uint64 `json:"locked"`
+	NodeStaked uint64 `json:"nodeStaked"`
+	Delegated  uint64 `json:"delegated"`
+	Rewards    uint64 `json:"rewards"`
+	Unstaking  uint64 `json:"unstaking"`
+}
+
+func (b *FlowBalance) Sum(buckets []string) uint64 {
+	var sum uint64
+	for _, bucket := range buckets {
+		switch bucket {
+		case BucketUnlocked:
+			sum += b.Unlocked
+		case BucketLocked:
+			sum += b.Locked
+		case BucketNodeStaked:
+			sum += b.NodeStaked
+		case BucketDelegated:
+			sum += b.Delegated
+		case BucketRewards:
+			sum += b.Rewards
+		case BucketUnstaking:
+			sum += b.Unstaking
+		}
+	}
+	return sum
+}
+
+func IsFlowBucket(bucket string) bool {
+	for _, b := range FlowBuckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
 }
 
 type CustomScript struct {
@@ -45,11 +45,12 @@ func (b *BalanceOfNfts) queryNFTs(
 	strategy models.Strategy,
 	balance *models.Balance,
 ) error {
-	scriptPath := "./main/cadence/scripts/get_nfts_ids.cdc"
+	scriptPath := shared.ScriptGetNFTIds
 	nftIds, err := b.FlowAdapter.GetNFTIds(
 		balance.Addr,
 		&strategy.Contract,
 		scriptPath,
+		balance.BlockHeight,
 	)
 	if err != nil {
 		return err
@@ -92,8 +93,10 @@ func (b *BalanceOfNfts) TallyVotes(
 				return models.ProposalResults{}, err
 			}
 
-			r.Results[vote.Choice] += int(voteWeight)
-			r.Results_float[vote.Choice] += voteWeight
+			for choice, weight := range vote.Allocate(voteWeight) {
+				r.Results[choice] += int(weight)
+				r.Results_float[choice] += weight
+			}
 		}
 	}
 
@@ -130,11 +133,11 @@ func (b *BalanceOfNfts) GetVotes(
 }
 
 func (b *BalanceOfNfts) RequiresSnapshot() bool {
-	return false
+	return true
 }
 
 func (b *BalanceOfNfts) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	b.FlowAdapter = f
@@ -0,0 +1,173 @@
+package strategies
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// CompositeWeighted sums the balances held in several contracts, each
+// multiplied by its own weight. The weighted total is stored as the primary
+// account balance, and the per-contract breakdown in the balance components.
+type CompositeWeighted struct {
+	shared.StrategyStruct
+	DB *shared.Database
+}
+
+func (cw *CompositeWeighted) FetchBalance(
+	b *models.Balance,
+	p *models.Proposal,
+) (*models.Balance, error) {
+
+	var c models.Community
+	if err := c.GetCommunityByProposalId(cw.DB, b.Proposal_id); err != nil {
+		return nil, err
+	}
+
+	strategy, err := models.MatchStrategyByProposal(*c.Strategies, *p.Strategy)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to find strategy for contract")
+		return nil, err
+	}
+
+	if err := cw.FetchBalanceFromSnapshot(&strategy, b, p.TokenDecimals()); err != nil {
+		log.Error().Err(err).Msg("Error calling snapshot client")
+		return nil, err
+	}
+	if err := b.CreateBalance(cw.DB); err != nil {
+		log.Error().Err(err).Msg("Error creating balance in the database.")
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (cw *CompositeWeighted) FetchBalanceFromSnapshot(
+	strategy *models.Strategy,
+	b *models.Balance,
+	decimals int,
+) error {
+	if strategy.Contract.Components == nil || len(*strategy.Contract.Components) == 0 {
+		return errors.New("composite strategy has no components")
+	}
+
+	var total float64
+	components := []models.BalanceComponent{}
+
+	for _, component := range *strategy.Contract.Components {
+		balance, err := cw.fetchComponentBalance(b, component)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error fetching %s balance.", *component.Name)
+			return err
+		}
+
+		weight := balance * component.Multiplier
+		components = append(components, models.BalanceComponent{
+			Name:       *component.Name,
+			Type:       component.Type,
+			Balance:    balance,
+			Multiplier: component.Multiplier,
+			Weight:     weight,
+		})
+		total += weight
+	}
+
+	b.PrimaryAccountBalance = shared.ToFixedPoint(total, decimals)
+	b.SecondaryAccountBalance = 0
+	b.StakingBalance = 0
+	b.Components = &components
+
+	return nil
+}
+
+func (cw *CompositeWeighted) fetchComponentBalance(
+	b *models.Balance,
+	component shared.ContractComponent,
+) (float64, error) {
+	switch component.Type {
+	case shared.ComponentFT:
+		return cw.FlowAdapter.GetFTBalance(
+			b.Addr,
+			b.BlockHeight,
+			*component.Name,
+			*component.Addr,
+			*component.Public_path,
+		)
+	case shared.ComponentNFT:
+		scriptPath := shared.ScriptGetNFTIds
+		nftIds, err := cw.FlowAdapter.GetNFTIds(b.Addr, &component.Contract, scriptPath, b.BlockHeight)
+		if err != nil {
+			return 0, err
+		}
+		return float64(len(nftIds)), nil
+	default:
+		return 0, fmt.Errorf("unknown component type: %s", component.Type)
+	}
+}
+
+func (cw *CompositeWeighted) TallyVotes(
+	votes []*models.VoteWithBalance,
+	r *models.ProposalResults,
+	p *models.Proposal,
+) (models.ProposalResults, error) {
+
+	for _, vote := range votes {
+		if vote.PrimaryAccountBalance != nil {
+			allowedBalance := p.EnforceMaxWeight(*vote.PrimaryAccountBalance)
+
+			for choice, balance := range vote.Allocate(float64(allowedBalance)) {
+				r.Results[choice] += int(balance)
+				r.Results_float[choice] += shared.FromFixedPoint(uint64(balance), p.TokenDecimals())
+			}
+		}
+	}
+
+	return *r, nil
+}
+
+func (cw *CompositeWeighted) GetVoteWeightForBalance(
+	vote *models.VoteWithBalance,
+	proposal *models.Proposal,
+) (float64, error) {
+	if vote.PrimaryAccountBalance == nil {
+		return 0.00, nil
+	}
+
+	weight := shared.FromFixedPoint(*vote.PrimaryAccountBalance, proposal.TokenDecimals())
+
+	if proposal.Max_weight != nil && weight > *proposal.Max_weight {
+		return *proposal.Max_weight, nil
+	}
+
+	return weight, nil
+}
+
+func (cw *CompositeWeighted) GetVotes(
+	votes []*models.VoteWithBalance,
+	proposal *models.Proposal,
+) ([]*models.VoteWithBalance, error) {
+
+	for _, vote := range votes {
+		weight, err := cw.GetVoteWeightForBalance(vote, proposal)
+		if err != nil {
+			return nil, err
+		}
+		vote.Weight = &weight
+	}
+	return votes, nil
+}
+
+func (cw *CompositeWeighted) RequiresSnapshot() bool {
+	return true
+}
+
+func (cw *CompositeWeighted) InitStrategy(
+	f shared.ChainReader,
+	db *shared.Database,
+) {
+	cw.FlowAdapter = f
+	cw.DB = db
+}
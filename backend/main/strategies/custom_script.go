@@ -1,7 +1,7 @@
 package strategies
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
@@ -37,9 +37,10 @@ func (cs *CustomScript) FetchBalance(
 
 	if strategy.Contract.Script == nil {
 		log.Error().Msg("No custom script name field was found for contract.")
+		return nil, errors.New("no custom script was found for contract")
 	}
 
-	if err := cs.queryNFTs(*vb, strategy, balance); err != nil {
+	if err := cs.queryNFTs(*vb, c.ID, strategy, balance); err != nil {
 		return nil, err
 	}
 
@@ -48,16 +49,20 @@ func (cs *CustomScript) FetchBalance(
 
 func (cs *CustomScript) queryNFTs(
 	vb models.VoteWithBalance,
+	communityId int,
 	strategy models.Strategy,
 	balance *models.Balance,
 ) error {
-	scriptName := cs.FlowAdapter.CustomScriptsMap[*strategy.Contract.Script].Src
-	scriptPath := fmt.Sprintf("./main/cadence/scripts/custom/%s", scriptName)
+	script, err := cs.loadScript(communityId, *strategy.Contract.Script)
+	if err != nil {
+		return err
+	}
 
-	nftIds, err := cs.FlowAdapter.GetNFTIds(
+	nftIds, err := cs.FlowAdapter.GetNFTIdsFromScript(
 		balance.Addr,
 		&strategy.Contract,
-		scriptPath,
+		script,
+		balance.BlockHeight,
 	)
 	if err != nil {
 		return err
@@ -85,6 +90,21 @@ func (cs *CustomScript) queryNFTs(
 	return err
 }
 
+// Scripts in scripts.json take precedence over the ones uploaded by the community.
+func (cs *CustomScript) loadScript(communityId int, key string) ([]byte, error) {
+	if script, ok := cs.FlowAdapter.GetCustomScript(key); ok {
+		return shared.ReadScript(shared.CustomScriptPath(script))
+	}
+
+	script := models.CustomScript{Community_id: communityId, Key: key}
+	if err := script.GetCustomScriptByKey(cs.DB); err != nil {
+		log.Error().Err(err).Msgf("Custom script %s not found.", key)
+		return nil, err
+	}
+
+	return []byte(script.Src), nil
+}
+
 func (cs *CustomScript) TallyVotes(
 	votes []*models.VoteWithBalance,
 	r *models.ProposalResults,
@@ -100,8 +120,10 @@ func (cs *CustomScript) TallyVotes(
 				return models.ProposalResults{}, err
 			}
 
-			r.Results[vote.Choice] += int(voteWeight)
-			r.Results_float[vote.Choice] += voteWeight
+			for choice, weight := range vote.Allocate(voteWeight) {
+				r.Results[choice] += int(weight)
+				r.Results_float[choice] += weight
+			}
 		}
 	}
 
@@ -141,11 +163,11 @@ func (cs *CustomScript) GetVotes(
 }
 
 func (cs *CustomScript) RequiresSnapshot() bool {
-	return false
+	return true
 }
 
 func (cs *CustomScript) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	cs.FlowAdapter = f
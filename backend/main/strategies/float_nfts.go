@@ -51,7 +51,7 @@ func (f *FloatNFTs) queryNFTs(
 	strategy models.Strategy,
 	balance *models.Balance,
 ) error {
-	hasEventNFT, err := f.FlowAdapter.CheckIfUserHasEvent(vb.Vote.Addr, &strategy.Contract)
+	hasEventNFT, err := f.FlowAdapter.CheckIfUserHasEvent(vb.Vote.Addr, &strategy.Contract, balance.BlockHeight)
 	if err != nil {
 		return err
 	}
@@ -62,7 +62,7 @@ func (f *FloatNFTs) queryNFTs(
 		return errors.New(errMsg)
 	}
 
-	nftIds, err := f.FlowAdapter.GetFloatNFTIds(vb.Vote.Addr, &strategy.Contract)
+	nftIds, err := f.FlowAdapter.GetFloatNFTIds(vb.Vote.Addr, &strategy.Contract, balance.BlockHeight)
 	for _, nftId := range nftIds {
 		nft := &models.NFT{
 			ID: nftId,
@@ -100,8 +100,10 @@ func (f *FloatNFTs) TallyVotes(
 				return models.ProposalResults{}, err
 			}
 
-			r.Results[vote.Choice] += int(voteWeight)
-			r.Results_float[vote.Choice] += voteWeight
+			for choice, weight := range vote.Allocate(voteWeight) {
+				r.Results[choice] += int(weight)
+				r.Results_float[choice] += weight
+			}
 		}
 	}
 
@@ -139,11 +141,11 @@ func (f *FloatNFTs) GetVotes(
 }
 
 func (f *FloatNFTs) RequiresSnapshot() bool {
-	return false
+	return true
 }
 
 func (f *FloatNFTs) InitStrategy(
-	fa *shared.FlowAdapter,
+	fa shared.ChainReader,
 	db *shared.Database,
 ) {
 	f.FlowAdapter = fa
@@ -42,7 +42,15 @@ func (s *OneAddressOneVote) TallyVotes(
 ) (models.ProposalResults, error) {
 
 	for _, vote := range votes {
-		r.Results[vote.Choice]++
+		if len(vote.Allocations) == 0 {
+			r.Results[vote.Choice]++
+			continue
+		}
+
+		// split ballots divide the single vote between choices
+		for choice, weight := range vote.Allocate(1) {
+			r.Results_float[choice] += weight
+		}
 	}
 
 	return *r, nil
@@ -84,7 +92,7 @@ func (s *OneAddressOneVote) RequiresSnapshot() bool {
 }
 
 func (s *OneAddressOneVote) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	s.FlowAdapter = f
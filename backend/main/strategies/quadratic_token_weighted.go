@@ -0,0 +1,86 @@
+package strategies
+
+import (
+	"math"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+)
+
+// QuadraticTokenWeighted fetches balances the same way as
+// TokenWeightedDefault, but a vote's weight is the square root
+// of the voter's balance.
+type QuadraticTokenWeighted struct {
+	TokenWeightedDefault
+}
+
+func (s *QuadraticTokenWeighted) TallyVotes(
+	votes []*models.VoteWithBalance,
+	r *models.ProposalResults,
+	p *models.Proposal,
+) (models.ProposalResults, error) {
+
+	for _, vote := range votes {
+		if vote.PrimaryAccountBalance != nil {
+			voteWeight, err := s.GetVoteWeightForBalance(vote, p)
+			if err != nil {
+				return models.ProposalResults{}, err
+			}
+
+			for choice, weight := range vote.Allocate(voteWeight) {
+				r.Results[choice] += int(weight)
+				r.Results_float[choice] += weight
+			}
+		}
+	}
+
+	return *r, nil
+}
+
+func (s *QuadraticTokenWeighted) GetVoteWeightForBalance(
+	vote *models.VoteWithBalance,
+	proposal *models.Proposal,
+) (float64, error) {
+
+	if vote.PrimaryAccountBalance == nil {
+		return 0.00, nil
+	}
+
+	balance := shared.FromFixedPoint(*vote.PrimaryAccountBalance, proposal.TokenDecimals())
+	weight := math.Sqrt(balance)
+
+	if proposal.Max_weight != nil && weight > *proposal.Max_weight {
+		return *proposal.Max_weight, nil
+	}
+
+	return weight, nil
+}
+
+func (s *QuadraticTokenWeighted) GetVotes(
+	votes []*models.VoteWithBalance,
+	proposal *models.Proposal,
+) ([]*models.VoteWithBalance, error) {
+
+	for _, vote := range votes {
+		weight, err := s.GetVoteWeightForBalance(vote, proposal)
+		if err != nil {
+			return nil, err
+		}
+		vote.Weight = &weight
+	}
+	return votes, nil
+}
+
+// Min_balance is a token balance, so it is checked against
+// the balance rather than its square root.
+func (s *QuadraticTokenWeighted) ValidateBalance(
+	vote *models.VoteWithBalance,
+	proposal *models.Proposal,
+) error {
+	var balance float64
+	if vote.PrimaryAccountBalance != nil {
+		balance = shared.FromFixedPoint(*vote.PrimaryAccountBalance, proposal.TokenDecimals())
+	}
+
+	return proposal.ValidateBalance(balance)
+}
@@ -2,7 +2,6 @@ package strategies
 
 import (
 	"fmt"
-	"math"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
@@ -64,6 +63,7 @@ func (s *StakedTokenWeightedDefault) FetchBalanceFromSnapshot(
 		b.PrimaryAccountBalance = ftBalance.PrimaryAccountBalance
 		b.SecondaryAccountBalance = ftBalance.SecondaryAccountBalance
 		b.StakingBalance = ftBalance.StakingBalance
+		b.FlowBalance = ftBalance.FlowBalance
 
 	} else {
 		if err := s.FlowAdapter.GetAddressBalanceAtBlockHeight(
@@ -90,17 +90,15 @@ func (s *StakedTokenWeightedDefault) TallyVotes(
 	var zero uint64 = 0
 
 	for _, vote := range votes {
-		if *vote.StakingBalance != zero {
-			var allowedBalance float64
-
-			if p.Max_weight != nil {
-				allowedBalance = p.EnforceMaxWeight(float64(*vote.StakingBalance))
-			} else {
-				allowedBalance = float64(*vote.StakingBalance)
+		// gate on the buckets the proposal counts, which may not be staked
+		stakedBalance := p.BucketBalance(vote.FlowBalance, *vote.StakingBalance)
+		if stakedBalance != zero {
+			allowedBalance := p.EnforceMaxWeight(stakedBalance)
+
+			for choice, balance := range vote.Allocate(float64(allowedBalance)) {
+				r.Results[choice] += int(balance)
+				r.Results_float[choice] += shared.FromFixedPoint(uint64(balance), p.TokenDecimals())
 			}
-
-			r.Results[vote.Choice] += int(allowedBalance)
-			r.Results_float[vote.Choice] += allowedBalance * math.Pow(10, -8)
 		}
 	}
 
@@ -118,7 +116,8 @@ func (s *StakedTokenWeightedDefault) GetVoteWeightForBalance(
 		return 0.00, nil
 	}
 
-	weight = float64(*vote.StakingBalance) * math.Pow(10, -8)
+	stakedBalance := proposal.BucketBalance(vote.FlowBalance, *vote.StakingBalance)
+	weight = shared.FromFixedPoint(stakedBalance, proposal.TokenDecimals())
 
 	switch {
 	case proposal.Max_weight != nil && weight > *proposal.Max_weight:
@@ -156,7 +155,7 @@ func (s *StakedTokenWeightedDefault) RequiresSnapshot() bool {
 }
 
 func (s *StakedTokenWeightedDefault) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	s.FlowAdapter = f
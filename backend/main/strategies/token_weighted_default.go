@@ -2,7 +2,6 @@ package strategies
 
 import (
 	"fmt"
-	"math"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	s "github.com/DapperCollectives/CAST/backend/main/shared"
@@ -92,16 +91,12 @@ func (s *TokenWeightedDefault) TallyVotes(
 
 	for _, vote := range votes {
 		if vote.PrimaryAccountBalance != nil {
-			var allowedBalance float64
+			allowedBalance := p.EnforceMaxWeight(*vote.PrimaryAccountBalance)
 
-			if p.Max_weight != nil {
-				allowedBalance = p.EnforceMaxWeight(float64(*vote.PrimaryAccountBalance))
-			} else {
-				allowedBalance = float64(*vote.PrimaryAccountBalance)
+			for choice, balance := range vote.Allocate(float64(allowedBalance)) {
+				r.Results[choice] += int(balance)
+				r.Results_float[choice] += shared.FromFixedPoint(uint64(balance), p.TokenDecimals())
 			}
-
-			r.Results[vote.Choice] += int(allowedBalance)
-			r.Results_float[vote.Choice] += allowedBalance * math.Pow(10, -8)
 		}
 	}
 
@@ -119,7 +114,7 @@ func (s *TokenWeightedDefault) GetVoteWeightForBalance(
 		return 0.00, nil
 	}
 
-	weight = float64(*vote.PrimaryAccountBalance) * math.Pow(10, -8)
+	weight = shared.FromFixedPoint(*vote.PrimaryAccountBalance, proposal.TokenDecimals())
 
 	switch {
 	case proposal.Max_weight != nil && weight > *proposal.Max_weight:
@@ -156,7 +151,7 @@ func (s *TokenWeightedDefault) RequiresSnapshot() bool {
 }
 
 func (s *TokenWeightedDefault) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	s.FlowAdapter = f
@@ -2,7 +2,6 @@ package strategies
 
 import (
 	"fmt"
-	"math"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
@@ -64,6 +63,7 @@ func (s *TotalTokenWeightedDefault) FetchBalanceFromSnapshot(
 		b.PrimaryAccountBalance = ftBalance.PrimaryAccountBalance
 		b.SecondaryAccountBalance = ftBalance.SecondaryAccountBalance
 		b.StakingBalance = ftBalance.StakingBalance
+		b.FlowBalance = ftBalance.FlowBalance
 
 	} else {
 		if err := s.FlowAdapter.GetAddressBalanceAtBlockHeight(
@@ -90,19 +90,16 @@ func (s *TotalTokenWeightedDefault) TallyVotes(
 	var zero uint64 = 0
 
 	for _, vote := range votes {
-		if *vote.StakingBalance != zero {
-			var allowedBalance float64
-
-			totalBalance := float64(*vote.StakingBalance) + float64(*vote.PrimaryAccountBalance) + float64(*vote.SecondaryAccountBalance)
-
-			if p.Max_weight != nil {
-				allowedBalance = p.EnforceMaxWeight(totalBalance)
-			} else {
-				allowedBalance = totalBalance
+		// gate on the buckets the proposal counts, which may not be staked
+		totalBalance := *vote.StakingBalance + *vote.PrimaryAccountBalance + *vote.SecondaryAccountBalance
+		totalBalance = p.BucketBalance(vote.FlowBalance, totalBalance)
+		if totalBalance != zero {
+			allowedBalance := p.EnforceMaxWeight(totalBalance)
+
+			for choice, balance := range vote.Allocate(float64(allowedBalance)) {
+				r.Results[choice] += int(balance)
+				r.Results_float[choice] += shared.FromFixedPoint(uint64(balance), p.TokenDecimals())
 			}
-
-			r.Results[vote.Choice] += int(allowedBalance)
-			r.Results_float[vote.Choice] += allowedBalance * math.Pow(10, -8)
 		}
 	}
 
@@ -116,9 +113,10 @@ func (s *TotalTokenWeightedDefault) GetVoteWeightForBalance(
 	var weight float64
 	var ERROR error = fmt.Errorf("no weight found, address: %s, strategy: %s", vote.Addr, *proposal.Strategy)
 
-	totalBalance := float64(*vote.StakingBalance) + float64(*vote.PrimaryAccountBalance) + float64(*vote.SecondaryAccountBalance)
+	totalBalance := *vote.StakingBalance + *vote.PrimaryAccountBalance + *vote.SecondaryAccountBalance
+	totalBalance = proposal.BucketBalance(vote.FlowBalance, totalBalance)
 
-	weight = totalBalance * math.Pow(10, -8)
+	weight = shared.FromFixedPoint(totalBalance, proposal.TokenDecimals())
 
 	switch {
 	case proposal.Max_weight != nil && weight > *proposal.Max_weight:
@@ -156,7 +154,7 @@ func (s *TotalTokenWeightedDefault) RequiresSnapshot() bool {
 }
 
 func (s *TotalTokenWeightedDefault) InitStrategy(
-	f *shared.FlowAdapter,
+	f shared.ChainReader,
 	db *shared.Database,
 ) {
 	s.FlowAdapter = f
@@ -0,0 +1,170 @@
+package strategies
+
+import (
+	"errors"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// TraitWeightedNfts counts the NFTs in a MetadataViews collection whose
+// traits match the contract's trait filters, weighting each NFT by the
+// multipliers of the traits it has.
+type TraitWeightedNfts struct {
+	shared.StrategyStruct
+	DB *shared.Database
+}
+
+func (t *TraitWeightedNfts) FetchBalance(
+	balance *models.Balance,
+	p *models.Proposal,
+) (*models.Balance, error) {
+
+	v := models.Vote{Proposal_id: balance.Proposal_id, Addr: balance.Addr}
+	vb := &models.VoteWithBalance{
+		NFTs: []*models.NFT{},
+		Vote: v,
+	}
+
+	var c models.Community
+	if err := c.GetCommunityByProposalId(t.DB, balance.Proposal_id); err != nil {
+		return nil, err
+	}
+
+	strategy, err := models.MatchStrategyByProposal(*c.Strategies, *p.Strategy)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to find strategy for contract.")
+		return nil, err
+	}
+
+	if strategy.Contract.Traits == nil {
+		return nil, errors.New("no trait filters were found for contract")
+	}
+
+	if err := t.queryNFTs(*vb, strategy, balance); err != nil {
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+func (t *TraitWeightedNfts) queryNFTs(
+	vb models.VoteWithBalance,
+	strategy models.Strategy,
+	balance *models.Balance,
+) error {
+	nftTraits, err := t.FlowAdapter.GetNFTTraits(
+		balance.Addr,
+		&strategy.Contract,
+		balance.BlockHeight,
+	)
+	if err != nil {
+		return err
+	}
+
+	for nftId, traits := range nftTraits {
+		weight, ok := shared.NFTTraitWeight(traits, *strategy.Contract.Traits)
+		if !ok {
+			continue
+		}
+
+		nft := &models.NFT{
+			ID:     nftId,
+			Weight: &weight,
+		}
+		vb.NFTs = append(vb.NFTs, nft)
+	}
+
+	doesExist, err := models.DoesNFTExist(t.DB, &vb)
+	if err != nil {
+		return err
+	}
+
+	//only if the NFT ID is not already in the DB,
+	//do we add the balance
+	if !doesExist && err == nil {
+		err = models.CreateUserNFTRecord(t.DB, &vb)
+		balance.NFTCount = len(vb.NFTs)
+	}
+
+	return err
+}
+
+func (t *TraitWeightedNfts) TallyVotes(
+	votes []*models.VoteWithBalance,
+	r *models.ProposalResults,
+	proposal *models.Proposal,
+) (models.ProposalResults, error) {
+
+	for _, vote := range votes {
+		if len(vote.NFTs) != 0 {
+			var voteWeight float64
+
+			voteWeight, err := t.GetVoteWeightForBalance(vote, proposal)
+			if err != nil {
+				return models.ProposalResults{}, err
+			}
+
+			for choice, weight := range vote.Allocate(voteWeight) {
+				r.Results[choice] += int(weight)
+				r.Results_float[choice] += weight
+			}
+		}
+	}
+
+	return *r, nil
+}
+
+func (t *TraitWeightedNfts) GetVoteWeightForBalance(
+	vote *models.VoteWithBalance,
+	proposal *models.Proposal,
+) (float64, error) {
+	nfts, err := models.GetUserNFTs(t.DB, vote)
+	if err != nil {
+		log.Error().Err(err).Msg("error in GetVoteWeightForBalance for TraitWeightedNfts strategy")
+		return 0.00, err
+	}
+
+	var weight float64
+	for _, nft := range nfts {
+		if nft.Weight != nil {
+			weight += *nft.Weight
+		} else {
+			weight += 1
+		}
+	}
+
+	if proposal.Max_weight != nil && weight > *proposal.Max_weight {
+		return *proposal.Max_weight, nil
+	}
+
+	return weight, nil
+}
+
+func (t *TraitWeightedNfts) GetVotes(
+	votes []*models.VoteWithBalance,
+	proposal *models.Proposal,
+) ([]*models.VoteWithBalance, error) {
+	for _, vote := range votes {
+		weight, err := t.GetVoteWeightForBalance(vote, proposal)
+		if err != nil {
+			return nil, err
+		}
+		vote.Weight = &weight
+	}
+
+	return votes, nil
+}
+
+func (t *TraitWeightedNfts) RequiresSnapshot() bool {
+	return true
+}
+
+func (t *TraitWeightedNfts) InitStrategy(
+	f shared.ChainReader,
+	db *shared.Database,
+) {
+	t.FlowAdapter = f
+	t.DB = db
+}
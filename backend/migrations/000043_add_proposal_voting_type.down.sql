@@ -0,0 +1,2 @@
+ALTER TABLE votes DROP COLUMN IF EXISTS choices;
+ALTER TABLE proposals DROP COLUMN IF EXISTS voting_type;
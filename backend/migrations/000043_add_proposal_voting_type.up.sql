@@ -0,0 +1,2 @@
+ALTER TABLE proposals ADD COLUMN voting_type VARCHAR(255) NOT NULL DEFAULT 'single-choice';
+ALTER TABLE votes ADD COLUMN choices JSONB;
@@ -0,0 +1 @@
+ALTER TABLE votes DROP COLUMN IF EXISTS allocations;
@@ -0,0 +1,8 @@
+ALTER TABLE proposals ADD COLUMN quorum FLOAT;
+ALTER TABLE proposals ADD COLUMN quorum_type VARCHAR(255);
+ALTER TABLE proposals ADD COLUMN pass_threshold FLOAT;
+ALTER TABLE proposals ADD COLUMN total_supply FLOAT;
+
+ALTER TABLE communities ADD COLUMN quorum FLOAT;
+ALTER TABLE communities ADD COLUMN quorum_type VARCHAR(255);
+ALTER TABLE communities ADD COLUMN pass_threshold FLOAT;
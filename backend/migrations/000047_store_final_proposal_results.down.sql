@@ -0,0 +1,9 @@
+ALTER TABLE proposal_results DROP CONSTRAINT IF EXISTS proposal_results_proposal_id_key;
+
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS winning_choice;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS passed;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS quorum_met;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS total_weight;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS approvals;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS rounds;
+ALTER TABLE proposal_results DROP COLUMN IF EXISTS results_float;
@@ -0,0 +1,9 @@
+ALTER TABLE proposal_results ADD COLUMN results_float JSONB;
+ALTER TABLE proposal_results ADD COLUMN rounds JSONB;
+ALTER TABLE proposal_results ADD COLUMN approvals JSONB;
+ALTER TABLE proposal_results ADD COLUMN total_weight FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE proposal_results ADD COLUMN quorum_met BOOLEAN NOT NULL DEFAULT 'false';
+ALTER TABLE proposal_results ADD COLUMN passed BOOLEAN NOT NULL DEFAULT 'false';
+ALTER TABLE proposal_results ADD COLUMN winning_choice VARCHAR;
+
+ALTER TABLE proposal_results ADD UNIQUE (proposal_id);
@@ -0,0 +1 @@
+ALTER TABLE proposals DROP COLUMN IF EXISTS lifecycle_status;
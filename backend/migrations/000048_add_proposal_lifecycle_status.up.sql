@@ -0,0 +1,5 @@
+ALTER TABLE proposals ADD COLUMN lifecycle_status VARCHAR(255) NOT NULL DEFAULT 'pending';
+
+-- proposals whose results are already stored need no further transitions
+UPDATE proposals SET lifecycle_status = 'closed'
+WHERE achievements_done = 'true' AND id IN (SELECT proposal_id FROM proposal_results);
@@ -0,0 +1,14 @@
+CREATE TABLE delegations (
+    id BIGSERIAL primary key,
+    community_id INT not null references communities(id),
+    delegator_addr VARCHAR(18) not null,
+    delegate_addr VARCHAR(18) not null,
+    composite_signatures JSONB,
+    voucher JSONB,
+    created_at TIMESTAMP without time zone not null default (now() at time zone 'utc'),
+    revoked_at TIMESTAMP without time zone
+);
+
+/* an address can only delegate to one other address per community at a time */
+CREATE UNIQUE INDEX delegations_active_delegator_idx ON delegations (community_id, delegator_addr) WHERE revoked_at IS NULL;
+CREATE INDEX delegations_delegate_idx ON delegations (community_id, delegate_addr);
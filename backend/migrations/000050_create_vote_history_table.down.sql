@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS votes_proposal_id_addr_idx;
+ALTER TABLE votes DROP COLUMN IF EXISTS updated_at;
+DROP TABLE IF EXISTS vote_history;
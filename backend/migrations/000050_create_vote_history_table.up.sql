@@ -0,0 +1,38 @@
+CREATE TABLE vote_history (
+    id BIGSERIAL primary key,
+    vote_id BIGINT not null references votes(id) ON DELETE CASCADE,
+    proposal_id INT not null references proposals(id),
+    addr VARCHAR(18) not null,
+    choice VARCHAR(256) not null,
+    choices JSONB,
+    allocations JSONB,
+    composite_signatures JSONB,
+    message TEXT not null,
+    cid VARCHAR(64),
+    cast_at TIMESTAMP without time zone not null,
+    replaced_at TIMESTAMP without time zone not null default (now() at time zone 'utc')
+);
+
+CREATE INDEX vote_history_vote_id_idx ON vote_history (vote_id);
+
+ALTER TABLE votes ADD COLUMN updated_at TIMESTAMP without time zone;
+
+/* keep only the latest vote per address, older duplicates move to the history */
+INSERT INTO vote_history (vote_id, proposal_id, addr, choice, choices, allocations, composite_signatures, message, cid, cast_at)
+SELECT latest.id, v.proposal_id, v.addr, v.choice, v.choices, v.allocations, v.composite_signatures, v.message, v.cid, v.created_at
+FROM votes v
+JOIN LATERAL (
+    SELECT id FROM votes
+    WHERE proposal_id = v.proposal_id AND addr = v.addr
+    ORDER BY created_at DESC, id DESC
+    LIMIT 1
+) latest ON latest.id != v.id;
+
+DELETE FROM votes v
+WHERE EXISTS (
+    SELECT 1 FROM votes newer
+    WHERE newer.proposal_id = v.proposal_id AND newer.addr = v.addr
+    AND (newer.created_at, newer.id) > (v.created_at, v.id)
+);
+
+CREATE UNIQUE INDEX votes_proposal_id_addr_idx ON votes (proposal_id, addr);
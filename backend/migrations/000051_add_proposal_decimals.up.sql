@@ -0,0 +1 @@
+ALTER TABLE proposals ADD COLUMN decimals INT;
@@ -0,0 +1,2 @@
+ALTER TABLE balances DROP COLUMN IF EXISTS components;
+DELETE FROM voting_strategies WHERE key = 'composite-weighted';
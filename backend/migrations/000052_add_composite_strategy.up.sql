@@ -0,0 +1,9 @@
+BEGIN;
+ALTER TYPE strategies ADD VALUE IF NOT EXISTS 'composite-weighted';
+END TRANSACTION;
+COMMIT;
+
+INSERT INTO voting_strategies (key, name, description)
+VALUES ('composite-weighted', 'Composite Weighted', 'Vote weight is the sum of several token and NFT balances, each multiplied by its own weight.');
+
+ALTER TABLE balances ADD COLUMN components JSONB;
@@ -0,0 +1,2 @@
+ALTER TABLE nfts DROP COLUMN IF EXISTS weight;
+DELETE FROM voting_strategies WHERE key = 'trait-weighted-nfts';
@@ -0,0 +1,9 @@
+BEGIN;
+ALTER TYPE strategies ADD VALUE IF NOT EXISTS 'trait-weighted-nfts';
+END TRANSACTION;
+COMMIT;
+
+INSERT INTO voting_strategies (key, name, description)
+VALUES ('trait-weighted-nfts', 'Trait Weighted NFTs', 'Vote weight is the number of NFTs held whose traits match the filters, each multiplied by the weight of the traits it has.');
+
+ALTER TABLE nfts ADD COLUMN weight DOUBLE PRECISION NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+CREATE TABLE custom_scripts (
+    id BIGSERIAL primary key,
+    community_id INT not null references communities(id),
+    key VARCHAR(255) not null,
+    name VARCHAR(255) not null,
+    description TEXT,
+    src TEXT not null,
+    cid VARCHAR(255),
+    creator_addr VARCHAR(18) not null,
+    created_at TIMESTAMP without time zone not null default (now() at time zone 'utc')
+);
+
+/* strategies refer to scripts by key, keys are unique within a community */
+CREATE UNIQUE INDEX custom_scripts_community_key_idx ON custom_scripts (community_id, key);
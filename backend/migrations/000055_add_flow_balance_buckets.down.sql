@@ -0,0 +1,2 @@
+ALTER TABLE proposals DROP COLUMN IF EXISTS balance_buckets;
+ALTER TABLE balances DROP COLUMN IF EXISTS flow_balance;
@@ -0,0 +1,2 @@
+ALTER TABLE balances ADD COLUMN flow_balance JSONB;
+ALTER TABLE proposals ADD COLUMN balance_buckets TEXT[];
@@ -0,0 +1,2 @@
+ALTER TABLE proposals DROP COLUMN IF EXISTS snapshot_fetched;
+ALTER TABLE proposals DROP COLUMN IF EXISTS snapshot_total;
@@ -0,0 +1,2 @@
+ALTER TABLE proposals ADD COLUMN snapshot_total INT;
+ALTER TABLE proposals ADD COLUMN snapshot_fetched INT;
@@ -0,0 +1,13 @@
+CREATE TABLE sessions (
+    id UUID primary key,
+    addr VARCHAR(18) not null,
+    nonce VARCHAR(255) not null,
+    refresh_token_hash VARCHAR(64) not null,
+    expires_at TIMESTAMP without time zone not null,
+    revoked_at TIMESTAMP without time zone,
+    created_at TIMESTAMP without time zone not null default (now() at time zone 'utc')
+);
+
+/* a signed sign in message starts one session only */
+CREATE UNIQUE INDEX sessions_nonce_idx ON sessions (addr, nonce);
+CREATE UNIQUE INDEX sessions_refresh_token_hash_idx ON sessions (refresh_token_hash);
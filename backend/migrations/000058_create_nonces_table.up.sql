@@ -0,0 +1,11 @@
+CREATE TABLE nonces (
+    nonce VARCHAR(64) primary key,
+    action VARCHAR(255) not null,
+    resource VARCHAR(255) not null,
+    expires_at TIMESTAMP without time zone not null,
+    used_at TIMESTAMP without time zone,
+    created_at TIMESTAMP without time zone not null default (now() at time zone 'utc')
+);
+
+/* expired nonces are deleted as new ones are issued */
+CREATE INDEX nonces_expires_at_idx ON nonces (expires_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS community_roles;
+
+DELETE FROM community_users WHERE user_type NOT IN ('admin', 'author', 'member');
+CREATE TYPE user_types AS enum ('admin', 'author', 'member');
+ALTER TABLE community_users ALTER COLUMN user_type TYPE user_types USING user_type::user_types;
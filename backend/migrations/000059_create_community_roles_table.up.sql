@@ -0,0 +1,11 @@
+/* custom roles are stored by name, so user types can't be an enum */
+ALTER TABLE community_users ALTER COLUMN user_type TYPE VARCHAR(32) USING user_type::text;
+DROP TYPE IF EXISTS user_types;
+
+CREATE TABLE community_roles (
+    community_id INT not null references communities(id),
+    name VARCHAR(32) not null,
+    permissions TEXT[] not null default '{}',
+    created_at TIMESTAMP without time zone not null default (now() at time zone 'utc'),
+    PRIMARY KEY (community_id, name)
+);
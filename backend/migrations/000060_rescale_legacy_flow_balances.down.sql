@@ -0,0 +1,4 @@
+UPDATE balances
+SET secondary_account_balance = secondary_account_balance / 10,
+    staking_balance = staking_balance / 10
+WHERE flow_balance IS NULL;
@@ -0,0 +1,7 @@
+-- Balances fetched before per-contract decimals stored FLOW secondary and
+-- staking balances with 7 decimals instead of 8. Balances fetched since
+-- carry a flow_balance breakdown, and other tokens leave both columns at 0.
+UPDATE balances
+SET secondary_account_balance = secondary_account_balance * 10,
+    staking_balance = staking_balance * 10
+WHERE flow_balance IS NULL;
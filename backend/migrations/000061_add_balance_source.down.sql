@@ -0,0 +1,2 @@
+ALTER TABLE proposals DROP COLUMN balance_source;
+ALTER TABLE balances DROP COLUMN source;
@@ -0,0 +1,6 @@
+-- Balances were keyed by address and block height alone, so strategies
+-- reading different contracts at the same height shared a row. New balances
+-- record what they measure, and proposals what their votes should read.
+-- Existing rows and proposals keep a null source and still match each other.
+ALTER TABLE balances ADD COLUMN source TEXT;
+ALTER TABLE proposals ADD COLUMN balance_source TEXT;
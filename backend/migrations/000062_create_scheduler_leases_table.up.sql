@@ -0,0 +1,8 @@
+-- Held by the API replica running the proposal scheduler, so only one
+-- replica processes proposals at a time. A lease left by a replica that
+-- stopped mid run expires on its own.
+CREATE TABLE scheduler_leases (
+    name VARCHAR(64) primary key,
+    holder uuid not null,
+    expires_at TIMESTAMP without time zone not null
+);
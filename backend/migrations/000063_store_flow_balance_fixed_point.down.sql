@@ -0,0 +1,10 @@
+UPDATE balances
+SET flow_balance = jsonb_build_object(
+    'unlocked', (flow_balance->>'unlocked')::numeric / 100000000,
+    'locked', (flow_balance->>'locked')::numeric / 100000000,
+    'nodeStaked', (flow_balance->>'nodeStaked')::numeric / 100000000,
+    'delegated', (flow_balance->>'delegated')::numeric / 100000000,
+    'rewards', (flow_balance->>'rewards')::numeric / 100000000,
+    'unstaking', (flow_balance->>'unstaking')::numeric / 100000000
+)
+WHERE flow_balance IS NOT NULL;
@@ -0,0 +1,12 @@
+-- FLOW balance breakdowns were stored in token amounts, store them fixed
+-- point with FLOW's 8 decimals like the other balance columns.
+UPDATE balances
+SET flow_balance = jsonb_build_object(
+    'unlocked', round((flow_balance->>'unlocked')::numeric * 100000000)::bigint,
+    'locked', round((flow_balance->>'locked')::numeric * 100000000)::bigint,
+    'nodeStaked', round((flow_balance->>'nodeStaked')::numeric * 100000000)::bigint,
+    'delegated', round((flow_balance->>'delegated')::numeric * 100000000)::bigint,
+    'rewards', round((flow_balance->>'rewards')::numeric * 100000000)::bigint,
+    'unstaking', round((flow_balance->>'unstaking')::numeric * 100000000)::bigint
+)
+WHERE flow_balance IS NOT NULL;
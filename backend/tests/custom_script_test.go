@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	utils "github.com/DapperCollectives/CAST/backend/tests/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+/******************/
+/* Custom Scripts */
+/******************/
+
+func TestCreateCustomScript(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("custom_scripts")
+
+	communityId := otu.AddCommunitiesWithUsers(1, "user1")[0]
+
+	t.Run("Dry run should return the sample address NFT IDs without storing the script", func(t *testing.T) {
+		payload := otu.GenerateCustomScriptPayload("user1", communityId, utils.DefaultCustomScriptStruct)
+		response := otu.CreateCustomScriptAPI(payload, true)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		response = otu.GetCustomScriptsForCommunityAPI(communityId)
+		var scripts []models.CustomScript
+		json.Unmarshal(response.Body.Bytes(), &scripts)
+		assert.Equal(t, 0, len(scripts))
+	})
+
+	t.Run("Community admin should be able to register a script", func(t *testing.T) {
+		payload := otu.GenerateCustomScriptPayload("user1", communityId, utils.DefaultCustomScriptStruct)
+		response := otu.CreateCustomScriptAPI(payload, false)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var script models.CustomScript
+		json.Unmarshal(response.Body.Bytes(), &script)
+		assert.Equal(t, utils.DefaultCustomScriptStruct.Key, script.Key)
+		assert.Equal(t, communityId, script.Community_id)
+		assert.NotNil(t, script.Cid)
+
+		response = otu.GetCustomScriptsForCommunityAPI(communityId)
+		var scripts []models.CustomScript
+		json.Unmarshal(response.Body.Bytes(), &scripts)
+		assert.Equal(t, 1, len(scripts))
+	})
+
+	t.Run("Should not register the same key twice", func(t *testing.T) {
+		payload := otu.GenerateCustomScriptPayload("user1", communityId, utils.DefaultCustomScriptStruct)
+		response := otu.CreateCustomScriptAPI(payload, false)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should reject a script without the expected main signature", func(t *testing.T) {
+		script := utils.DefaultCustomScriptStruct
+		script.Key = "wrong-signature"
+		script.Src = `pub fun main(address: Address): UInt64 { return 0 }`
+
+		payload := otu.GenerateCustomScriptPayload("user1", communityId, script)
+		response := otu.CreateCustomScriptAPI(payload, false)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should reject a script that fails to parse", func(t *testing.T) {
+		script := utils.DefaultCustomScriptStruct
+		script.Key = "invalid"
+		script.Src = `pub fun main(address: Address): [UInt64] {`
+
+		payload := otu.GenerateCustomScriptPayload("user1", communityId, script)
+		response := otu.CreateCustomScriptAPI(payload, false)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Non-admins should not be able to register a script", func(t *testing.T) {
+		script := utils.DefaultCustomScriptStruct
+		script.Key = "not-an-admin"
+
+		payload := otu.GenerateCustomScriptPayload("user2", communityId, script)
+		response := otu.CreateCustomScriptAPI(payload, false)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+/*******************/
+/*   Delegations   */
+/*******************/
+
+func TestCreateDelegation(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("delegations")
+	communityId := otu.AddCommunities(1, "dao")[0]
+
+	var delegation models.Delegation
+
+	t.Run("Should create a signed delegation", func(t *testing.T) {
+		payload := otu.GenerateDelegationPayload("user1", "user2")
+		response := otu.CreateDelegationAPI(communityId, payload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &delegation)
+		assert.Equal(t, communityId, delegation.Community_id)
+		assert.Equal(t, payload.Delegator_addr, delegation.Delegator_addr)
+		assert.Equal(t, payload.Delegate_addr, delegation.Delegate_addr)
+		assert.Nil(t, delegation.Revoked_at)
+	})
+
+	t.Run("Should not delegate twice in the same community", func(t *testing.T) {
+		payload := otu.GenerateDelegationPayload("user1", "user3")
+		response := otu.CreateDelegationAPI(communityId, payload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should not delegate to itself", func(t *testing.T) {
+		payload := otu.GenerateDelegationPayload("user2", "user2")
+		response := otu.CreateDelegationAPI(communityId, payload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should list active delegations for the community", func(t *testing.T) {
+		response := otu.GetDelegationsForCommunityAPI(communityId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body shared.PaginatedResponse
+		json.Unmarshal(response.Body.Bytes(), &body)
+		assert.Equal(t, 1, body.TotalRecords)
+	})
+
+	t.Run("Should revoke a delegation", func(t *testing.T) {
+		payload := otu.GenerateDelegationPayload("user1", "user2")
+		response := otu.RevokeDelegationAPI(communityId, payload)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var revoked models.Delegation
+		json.Unmarshal(response.Body.Bytes(), &revoked)
+		assert.Equal(t, delegation.ID, revoked.ID)
+		assert.NotNil(t, revoked.Revoked_at)
+
+		response = otu.GetDelegationForAddressAPI(communityId, payload.Delegator_addr)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should delegate again once revoked", func(t *testing.T) {
+		payload := otu.GenerateDelegationPayload("user1", "user3")
+		response := otu.CreateDelegationAPI(communityId, payload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+	})
+}
+
+func TestDelegatedVotes(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("delegations")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+	delegation := otu.GenerateDelegationPayload("user1", "user2")
+	otu.CreateDelegationAPI(communityId, delegation)
+
+	t.Run("Should not count a delegation until the delegate votes", func(t *testing.T) {
+		votes, err := models.GetDelegatedVotesForProposal(otu.A.DB, proposalId)
+		assert.Nil(t, err)
+		assert.Empty(t, votes)
+	})
+
+	t.Run("Should count the delegator towards the delegate's choice", func(t *testing.T) {
+		response := otu.CreateVoteAPI(proposalId, otu.GenerateValidVotePayload("user2", proposalId, "a"))
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		votes, err := models.GetDelegatedVotesForProposal(otu.A.DB, proposalId)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(votes))
+		assert.Equal(t, delegation.Delegator_addr, *votes[0].Delegator_addr)
+		assert.Equal(t, delegation.Delegate_addr, votes[0].Addr)
+		assert.Equal(t, "a", votes[0].Choice)
+	})
+
+	t.Run("Should show delegated weight separately in the votes list", func(t *testing.T) {
+		response := otu.GetVotesForProposalAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body struct {
+			Data []models.VoteWithBalance `json:"data"`
+		}
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		assert.Equal(t, 1, len(body.Data))
+		assert.NotNil(t, body.Data[0].Delegated_weight)
+	})
+
+	t.Run("Should not count the delegation once the delegator votes", func(t *testing.T) {
+		response := otu.CreateVoteAPI(proposalId, otu.GenerateValidVotePayload("user1", proposalId, "b"))
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		votes, err := models.GetDelegatedVotesForProposal(otu.A.DB, proposalId)
+		assert.Nil(t, err)
+		assert.Empty(t, votes)
+	})
+}
+
+func TestDelegatedBalances(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("delegations")
+	clearTable("balances")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+	response := otu.CreateVoteAPI(proposalId, otu.GenerateValidVotePayload("user2", proposalId, "a"))
+	CheckResponseCode(t, http.StatusCreated, response.Code)
+
+	// stored directly, so the delegator's balance isn't fetched on creation
+	delegation := otu.GenerateDelegationPayload("user1", "user2").Delegation
+	delegation.Community_id = communityId
+	assert.Nil(t, delegation.CreateDelegation(otu.A.DB))
+
+	getDelegatedWeight := func() *float64 {
+		response := otu.GetVotesForProposalAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body struct {
+			Data []models.VoteWithBalance `json:"data"`
+		}
+		json.Unmarshal(response.Body.Bytes(), &body)
+		assert.Equal(t, 1, len(body.Data))
+		return body.Data[0].Delegated_weight
+	}
+
+	t.Run("Should not fetch delegator balances when reading votes", func(t *testing.T) {
+		assert.Nil(t, getDelegatedWeight())
+
+		votes, err := models.GetDelegatedVotesForProposal(otu.A.DB, proposalId)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(votes))
+		assert.Nil(t, votes[0].PrimaryAccountBalance)
+	})
+
+	t.Run("Should fetch delegator balances when the proposal opens", func(t *testing.T) {
+		err := otu.A.Scheduler.RunOnce()
+		assert.Nil(t, err)
+
+		votes, err := models.GetDelegatedVotesForProposal(otu.A.DB, proposalId)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(votes))
+		assert.NotNil(t, votes[0].PrimaryAccountBalance)
+		assert.NotNil(t, getDelegatedWeight())
+	})
+
+	t.Run("Should not count delegators below the minimum balance", func(t *testing.T) {
+		_, err := otu.A.DB.Conn.Exec(otu.A.DB.Context,
+			`UPDATE proposals SET min_balance = $1 WHERE id = $2`, 1e12, proposalId)
+		assert.Nil(t, err)
+
+		// minimum balances aren't checked in TEST
+		os.Setenv("APP_ENV", "PRODUCTION")
+		defer os.Setenv("APP_ENV", "TEST")
+
+		assert.Nil(t, getDelegatedWeight())
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/DapperCollectives/CAST/backend/main/strategies"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+const fixtureAddr = "0x01cf0e2f2f715450"
+
+func newFakeChainReader(t *testing.T) *shared.FakeChainReader {
+	fixtures, err := shared.LoadChainFixtures("./tests/fixtures/chain.json")
+	assert.Nil(t, err)
+	return shared.NewFakeChainReader(fixtures, nil)
+}
+
+func TestFakeChainReader(t *testing.T) {
+	flowToken := "FlowToken"
+	exampleToken := "ExampleToken"
+	exampleNFT := "ExampleNFT"
+	addr := "0xf8d6e0586b0a20c7"
+	publicPath := "exampleTokenBalance"
+
+	t.Run("Should read balances from fixtures", func(t *testing.T) {
+		fake := newFakeChainReader(t)
+
+		blockHeight, err := fake.GetCurrentBlockHeight()
+		assert.Nil(t, err)
+		assert.Equal(t, 100, blockHeight)
+
+		b := &models.Balance{Addr: fixtureAddr, BlockHeight: uint64(blockHeight)}
+		s := &strategies.StakedTokenWeightedDefault{}
+		s.InitStrategy(fake, nil)
+
+		strategy := &models.Strategy{Contract: shared.Contract{Name: &flowToken}}
+		assert.Nil(t, s.FetchBalanceFromSnapshot(strategy, b))
+		assert.Equal(t, shared.ToFixedPoint(10, 8), b.PrimaryAccountBalance)
+		assert.Equal(t, shared.ToFixedPoint(20, 8), b.SecondaryAccountBalance)
+		assert.Equal(t, shared.ToFixedPoint(76, 8), b.StakingBalance)
+
+		contract := &shared.Contract{Name: &exampleToken}
+		totalSupply, err := fake.GetTotalSupplyAtBlockHeight(contract, 100)
+		assert.Nil(t, err)
+		assert.Equal(t, 1000.0, totalSupply)
+	})
+
+	t.Run("Should weigh composite strategies from fixtures", func(t *testing.T) {
+		fake := newFakeChainReader(t)
+		s := &strategies.CompositeWeighted{}
+		s.InitStrategy(fake, nil)
+
+		strategy := &models.Strategy{Contract: shared.Contract{Components: &[]shared.ContractComponent{
+			{Contract: shared.Contract{Name: &exampleToken, Addr: &addr, Public_path: &publicPath}, Type: shared.ComponentFT, Multiplier: 1},
+			{Contract: shared.Contract{Name: &exampleNFT, Addr: &addr, Public_path: &publicPath}, Type: shared.ComponentNFT, Multiplier: 10},
+		}}}
+
+		b := &models.Balance{Addr: fixtureAddr, BlockHeight: 100}
+		assert.Nil(t, s.FetchBalanceFromSnapshot(strategy, b, shared.DefaultDecimals))
+		assert.Equal(t, shared.ToFixedPoint(50+2*10, 8), b.PrimaryAccountBalance)
+	})
+
+	t.Run("Should read NFTs and FLOATs from fixtures", func(t *testing.T) {
+		fake := newFakeChainReader(t)
+		contract := &shared.Contract{Name: &exampleNFT}
+
+		nftIds, err := fake.GetNFTIds(fixtureAddr, contract, shared.ScriptGetNFTIds, 100)
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"1", "2"}, nftIds)
+
+		traits, err := fake.GetNFTTraits(fixtureAddr, contract, 100)
+		assert.Nil(t, err)
+		assert.Equal(t, "rare", traits["1"]["rarity"])
+
+		eventId := uint64(7)
+		contract.Float_event_id = &eventId
+		hasEvent, err := fake.CheckIfUserHasEvent(fixtureAddr, contract, 100)
+		assert.Nil(t, err)
+		assert.True(t, hasEvent)
+
+		hasEvent, err = fake.CheckIfUserHasEvent(addr, contract, 100)
+		assert.Nil(t, err)
+		assert.False(t, hasEvent)
+	})
+
+	t.Run("Should verify signatures against fixture keys", func(t *testing.T) {
+		fake := newFakeChainReader(t)
+
+		seed := make([]byte, crypto.MinSeedLength)
+		privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSA_P256, seed)
+		assert.Nil(t, err)
+
+		fake.SetAccount(fixtureAddr, &shared.AccountFixture{Keys: []shared.KeyFixture{{
+			PublicKey: hex.EncodeToString(privateKey.PublicKey().Encode()),
+			SigAlgo:   crypto.ECDSA_P256.String(),
+			HashAlgo:  crypto.SHA3_256.String(),
+			Weight:    1000,
+		}}})
+
+		message := hex.EncodeToString([]byte("cast a vote"))
+		signer, err := crypto.NewInMemorySigner(privateKey, crypto.SHA3_256)
+		assert.Nil(t, err)
+		messageBytes, _ := hex.DecodeString(message)
+		signature, err := signer.Sign(append(flow.UserDomainTag[:], messageBytes...))
+		assert.Nil(t, err)
+
+		sigs := []shared.CompositeSignature{{Addr: fixtureAddr, Key_id: 0, Signature: hex.EncodeToString(signature)}}
+		assert.Nil(t, fake.ValidateSignature(fixtureAddr, message, &sigs, "USER"))
+
+		otherMessage := hex.EncodeToString([]byte("cast another vote"))
+		assert.NotNil(t, fake.ValidateSignature(fixtureAddr, otherMessage, &sigs, "USER"))
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessNodeFailover(t *testing.T) {
+	policy := shared.FlowRetryPolicy{
+		Timeout:  2 * time.Second,
+		Retries:  2,
+		Backoff:  10 * time.Millisecond,
+		Cooldown: time.Minute,
+	}
+
+	t.Run("Should fail over to the next access node", func(t *testing.T) {
+		// nothing listens on the first node, the second is the emulator
+		c, err := shared.NewAccessNodeClient([]string{"127.0.0.1:1", "127.0.0.1:3569"}, policy)
+		assert.Nil(t, err)
+
+		block, err := c.GetLatestBlock(context.Background(), true)
+		assert.Nil(t, err)
+		assert.NotNil(t, block)
+
+		// the failed node is skipped until its cooldown ends
+		assert.Equal(t, []string{"127.0.0.1:3569", "127.0.0.1:1"}, c.URLs())
+	})
+
+	t.Run("Should return the error once retries are exhausted", func(t *testing.T) {
+		c, err := shared.NewAccessNodeClient([]string{"127.0.0.1:1"}, policy)
+		assert.Nil(t, err)
+
+		_, err = c.GetLatestBlock(context.Background(), true)
+		assert.NotNil(t, err)
+		assert.True(t, shared.IsTransientFlowError(err))
+	})
+
+	t.Run("Should only retry transient errors", func(t *testing.T) {
+		assert.True(t, shared.IsTransientFlowError(status.Error(codes.Unavailable, "")))
+		assert.True(t, shared.IsTransientFlowError(status.Error(codes.DeadlineExceeded, "")))
+		assert.True(t, shared.IsTransientFlowError(status.Error(codes.Internal, "ledger returns unsuccessful")))
+		assert.False(t, shared.IsTransientFlowError(status.Error(codes.InvalidArgument, "cadence runtime error")))
+		assert.False(t, shared.IsTransientFlowError(status.Error(codes.NotFound, "")))
+	})
+
+	t.Run("Should read the network's fallback access nodes", func(t *testing.T) {
+		config := shared.FlowConfig{
+			Networks:    map[string]string{"mainnet": "a:9000"},
+			AccessNodes: map[string][]string{"mainnet": {"b:9000", "a:9000"}},
+		}
+		assert.Equal(t, []string{"a:9000", "b:9000"}, config.NetworkURLs("mainnet"))
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/DapperCollectives/CAST/backend/tests/test_utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var injectionPayloads = []string{
+	"'",
+	"' OR '1'='1",
+	"dao' OR category IS NOT NULL OR '1'='1",
+	"'; DROP TABLE communities; --",
+	"asc; DROP TABLE proposals; --",
+	"created_at ASC, (SELECT pg_sleep(1))",
+	"1) OR (1=1",
+}
+
+func TestSqlInjection(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddProposals(communityId, 2)[0]
+	otu.AddVotes(proposalId, 2)
+
+	get := func(path string, params url.Values) *http.Request {
+		req, _ := http.NewRequest("GET", path+"?"+params.Encode(), nil)
+		return req
+	}
+
+	t.Run("Community search should bind filters and text", func(t *testing.T) {
+		for _, payload := range injectionPayloads {
+			for _, text := range []string{"", payload} {
+				params := url.Values{"filters": {payload}, "text": {text}}
+				response := executeRequest(get("/communities/search", params))
+				checkResponseCode(t, http.StatusOK, response.Code)
+
+				var p test_utils.PaginatedResponseSearch
+				json.Unmarshal(response.Body.Bytes(), &p)
+				assert.Empty(t, p.Results.Data, payload)
+				assert.Equal(t, 0, p.Results.TotalRecords, payload)
+			}
+		}
+	})
+
+	t.Run("Proposals should only be ordered and filtered by known values", func(t *testing.T) {
+		path := "/communities/" + strconv.Itoa(communityId) + "/proposals"
+		for _, payload := range injectionPayloads {
+			response := executeRequest(get(path, url.Values{"order": {payload}}))
+			checkResponseCode(t, http.StatusOK, response.Code)
+
+			var p shared.PaginatedResponse
+			json.Unmarshal(response.Body.Bytes(), &p)
+			assert.Equal(t, 2, p.TotalRecords, payload)
+
+			response = executeRequest(get(path, url.Values{"status": {payload}}))
+			checkResponseCode(t, http.StatusOK, response.Code)
+		}
+	})
+
+	t.Run("Votes should only be ordered by known values", func(t *testing.T) {
+		path := "/proposals/" + strconv.Itoa(proposalId) + "/votes"
+		for _, payload := range injectionPayloads {
+			response := executeRequest(get(path, url.Values{"order": {payload}}))
+			checkResponseCode(t, http.StatusOK, response.Code)
+		}
+	})
+
+	t.Run("Leaderboard should bind stored voter addresses", func(t *testing.T) {
+		_, err := otu.A.DB.Conn.Exec(otu.A.DB.Context, `
+			INSERT INTO votes(proposal_id, addr, choice, composite_signatures, message)
+			VALUES($1, $2, $3, $4, $5)
+		`, proposalId, "0x01' OR 'a'='a", "yes", "[]", "__msg__")
+		assert.Nil(t, err)
+		_, err = otu.A.DB.Conn.Exec(otu.A.DB.Context, `
+			INSERT INTO votes(proposal_id, addr, choice, composite_signatures, message)
+			VALUES($1, $2, $3, $4, $5)
+		`, proposalId, "0x02'", "yes", "[]", "__msg__")
+		assert.Nil(t, err)
+
+		response := otu.GetCommunityLeaderboardAPI(communityId)
+		checkResponseCode(t, http.StatusOK, response.Code)
+	})
+
+	t.Run("Tables should be intact", func(t *testing.T) {
+		var count int
+		err := otu.A.DB.Conn.QueryRow(otu.A.DB.Context, "SELECT COUNT(*) FROM communities").Scan(&count)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+
+		err = otu.A.DB.Conn.QueryRow(otu.A.DB.Context, "SELECT COUNT(*) FROM proposals").Scan(&count)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
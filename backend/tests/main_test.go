@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -116,6 +115,20 @@ var (
 		Details:    "There was an error creating the vote.",
 	}
 
+	errTooManyRequests = errorResponse{
+		StatusCode: http.StatusTooManyRequests,
+		ErrorCode:  "ERR_1016",
+		Message:    "Too Many Requests",
+		Details:    "Too many requests, please try again later.",
+	}
+
+	errSnapshotUnavailable = errorResponse{
+		StatusCode: http.StatusBadRequest,
+		ErrorCode:  "ERR_1017",
+		Message:    "Snapshot Unavailable",
+		Details:    "This proposal's snapshot is no longer available, so votes can't be counted.",
+	}
+
 	nilErr = errorResponse{}
 )
 
@@ -143,9 +156,13 @@ func TestMain(m *testing.M) {
 	os.Setenv("FLOW_ENV", "emulator")
 
 	A.Initialize()
+	// payload generators sign timestamps, nonce tests turn nonces on
+	A.Config.Features["validateNonces"] = false
+	// many requests share an address, rate limit tests turn limits on
+	A.Config.Features["useRateLimits"] = false
 
 	// Load custom scripts for strategies
-	scripts, err := ioutil.ReadFile("../main/cadence/scripts/custom/scripts.json")
+	scripts, err := shared.ReadScript(shared.CustomScriptsJSON)
 	if err != nil {
 		log.Error().Err(err).Msg("Error Reading Custom Strategy scripts.")
 	}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonces(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("lists")
+	clearTable("nonces")
+
+	otu.A.Config.Features["validateNonces"] = true
+	defer func() { otu.A.Config.Features["validateNonces"] = false }()
+
+	communityId := otu.AddCommunitiesWithUsers(1, "account")[0]
+	resource := fmt.Sprintf("communities/%d/lists", communityId)
+
+	t.Run("Should issue a nonce for a known action", func(t *testing.T) {
+		response := otu.CreateNonceAPI(models.ActionListCreate, resource)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var nonce models.Nonce
+		json.Unmarshal(response.Body.Bytes(), &nonce)
+		assert.Len(t, nonce.Nonce, 32)
+		assert.Equal(t, models.ActionListCreate, nonce.Action)
+		assert.Equal(t, resource, nonce.Resource)
+	})
+
+	t.Run("Should not issue a nonce for an unknown action", func(t *testing.T) {
+		response := otu.CreateNonceAPI("list.delete", resource)
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should accept a nonce once", func(t *testing.T) {
+		payload := otu.GenerateBlockListPayload("account", otu.GenerateBlockListStruct(communityId))
+		payload.TimestampSignaturePayload = otu.SignAction("account", models.ActionListCreate, resource)
+
+		response := otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		response = otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should reject a nonce issued for another action", func(t *testing.T) {
+		payload := otu.GenerateBlockListPayload("account", otu.GenerateBlockListStruct(communityId))
+		payload.TimestampSignaturePayload = otu.SignAction("account", models.ActionScriptCreate, resource)
+
+		response := otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should reject a nonce issued for another resource", func(t *testing.T) {
+		other := fmt.Sprintf("communities/%d/lists", communityId+1)
+		payload := otu.GenerateBlockListPayload("account", otu.GenerateBlockListStruct(communityId))
+		payload.TimestampSignaturePayload = otu.SignAction("account", models.ActionListCreate, other)
+
+		response := otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should reject a request without a nonce", func(t *testing.T) {
+		payload := otu.GenerateBlockListPayload("account", otu.GenerateBlockListStruct(communityId))
+
+		response := otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should reject a malformed timestamp", func(t *testing.T) {
+		payload := otu.GenerateBlockListPayload("account", otu.GenerateBlockListStruct(communityId))
+		payload.TimestampSignaturePayload = otu.SignAction("account", models.ActionListCreate, resource)
+		payload.Timestamp = "not-a-timestamp"
+
+		response := otu.CreateListAPI(payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should only accept a nonce signed into the voucher", func(t *testing.T) {
+		proposals := fmt.Sprintf("communities/%d/proposals", communityId)
+		timestamp := fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+
+		// a voucher signed without a nonce can't be replayed with a fresh one
+		proposal := otu.GenerateProposalStruct("account", communityId)
+		proposal.Timestamp = timestamp
+		proposal.Voucher = otu.GenerateVoucher("account", timestamp)
+		proposal.Nonce = otu.CreateNonce(models.ActionProposalCreate, proposals)
+
+		response := otu.CreateProposalAPI(proposal)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+
+		proposal = otu.GenerateProposalStruct("account", communityId)
+		proposal.Timestamp = timestamp
+		proposal.Voucher = otu.GenerateVoucher("account", timestamp, otu.CreateNonce(models.ActionProposalCreate, proposals))
+
+		response = otu.CreateProposalAPI(proposal)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("Should sign in with a server issued nonce", func(t *testing.T) {
+		response := otu.SignInAPI(otu.GenerateSignInPayload("account"))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	})
+}
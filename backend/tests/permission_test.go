@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("lists")
+
+	communityId := otu.AddCommunitiesWithUsers(1, "account")[0]
+	listId := otu.AddLists(communityId, 1)[0]
+
+	t.Run("Moderators should be able to edit lists", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, otu.GrantRole("account", "user2", communityId, models.ModeratorRole).Code)
+
+		payload := otu.GenerateUpdateListPayload(listId, communityId, "user2")
+		response := otu.AddAddressesToListAPI(listId, payload)
+		checkResponseCode(t, http.StatusOK, response.Code)
+	})
+
+	t.Run("Moderators should not be able to create lists", func(t *testing.T) {
+		allow := "allow"
+		list := otu.GenerateBlockListStruct(communityId)
+		list.List_type = &allow
+
+		response := otu.CreateListAPI(otu.GenerateBlockListPayload("user2", list))
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Members should not be able to edit lists", func(t *testing.T) {
+		payload := otu.GenerateUpdateListPayload(listId, communityId, "user3")
+		response := otu.AddAddressesToListAPI(listId, payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Only admins should manage roles", func(t *testing.T) {
+		role := models.CommunityRole{Name: "curator", Permissions: []string{models.ActionListCreate}}
+		response := otu.CreateCommunityRoleAPI(communityId, otu.GenerateCommunityRolePayload("user2", &role))
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Custom roles should not shadow built in roles or grant roles", func(t *testing.T) {
+		role := models.CommunityRole{Name: "admin", Permissions: []string{models.ActionListCreate}}
+		response := otu.CreateCommunityRoleAPI(communityId, otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+		role = models.CommunityRole{Name: "curator", Permissions: []string{models.ActionRoleGrant}}
+		response = otu.CreateCommunityRoleAPI(communityId, otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Admins should be able to define custom roles", func(t *testing.T) {
+		role := models.CommunityRole{Name: "curator", Permissions: []string{models.ActionListEdit}}
+		response := otu.CreateCommunityRoleAPI(communityId, otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		role.Permissions = []string{models.ActionListCreate}
+		response = otu.UpdateCommunityRoleAPI(communityId, "curator", otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		response = otu.GetCommunityRolesAPI(communityId)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var roles []models.CommunityRole
+		json.Unmarshal(response.Body.Bytes(), &roles)
+		custom := roles[len(roles)-1]
+		assert.Equal(t, "curator", custom.Name)
+		assert.Equal(t, []string{models.ActionListCreate}, custom.Permissions)
+		assert.False(t, custom.Built_in)
+	})
+
+	t.Run("Custom roles should grant their permissions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, otu.GrantRole("account", "user3", communityId, "curator").Code)
+
+		allow := "allow"
+		list := otu.GenerateBlockListStruct(communityId)
+		list.List_type = &allow
+
+		response := otu.CreateListAPI(otu.GenerateBlockListPayload("user3", list))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("Removing a custom role should take it away", func(t *testing.T) {
+		role := models.CommunityRole{}
+		response := otu.DeleteCommunityRoleAPI(communityId, "curator", otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		err := models.EnsurePermissionForCommunity(otu.A.DB, otu.ResolveUser(3), communityId, models.ActionListCreate)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Custom roles should allow submitting proposals", func(t *testing.T) {
+		response := otu.CreateProposalAPI(otu.GenerateProposalStruct("user3", communityId))
+		assert.NotEqual(t, http.StatusCreated, response.Code)
+
+		role := models.CommunityRole{Name: "proposer", Permissions: []string{models.ActionProposalCreate}}
+		response = otu.CreateCommunityRoleAPI(communityId, otu.GenerateCommunityRolePayload("account", &role))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+		checkResponseCode(t, http.StatusCreated, otu.GrantRole("account", "user3", communityId, "proposer").Code)
+
+		response = otu.CreateProposalAPI(otu.GenerateProposalStruct("user3", communityId))
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("Members should not remove another account's roles", func(t *testing.T) {
+		moderator := otu.GenerateCommunityUserStruct("user2", models.ModeratorRole)
+		moderator.Community_id = communityId
+
+		payload := otu.GenerateCommunityUserPayload("user3", moderator)
+		response := otu.DeleteUserFromCommunityAPI(communityId, moderator.Addr, moderator.User_type, payload)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Members should be able to give up their own roles", func(t *testing.T) {
+		moderator := otu.GenerateCommunityUserStruct("user2", models.ModeratorRole)
+		moderator.Community_id = communityId
+
+		payload := otu.GenerateCommunityUserPayload("user2", moderator)
+		response := otu.DeleteUserFromCommunityAPI(communityId, moderator.Addr, moderator.User_type, payload)
+		checkResponseCode(t, http.StatusOK, response.Code)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -121,6 +122,91 @@ func TestCreateProposal(t *testing.T) {
 	})
 }
 
+func TestProposalOutcome(t *testing.T) {
+	proposal := otu.GenerateProposalStruct("account", 1)
+
+	newResults := func(a, b float64) *models.ProposalResults {
+		results := models.NewProposalResults(1, proposal.Choices)
+		results.Results_float["a"] = a
+		results.Results_float["b"] = b
+		return results
+	}
+
+	t.Run("Should pass the choice with the most weight without quorum or threshold", func(t *testing.T) {
+		results := newResults(1, 3)
+		results.ComputeOutcome(proposal)
+
+		assert.True(t, results.Quorum_met)
+		assert.True(t, results.Passed)
+		assert.Equal(t, "b", *results.Winning_choice)
+	})
+
+	t.Run("Should not pass when an absolute quorum is not met", func(t *testing.T) {
+		p := *proposal
+		quorum := 10.0
+		p.Quorum = &quorum
+		results := newResults(1, 3)
+		results.ComputeOutcome(&p)
+
+		assert.False(t, results.Quorum_met)
+		assert.False(t, results.Passed)
+		assert.Equal(t, "b", *results.Winning_choice)
+	})
+
+	t.Run("Should measure a percentage quorum against the snapshotted supply", func(t *testing.T) {
+		p := *proposal
+		quorum := 10.0
+		quorumType := models.QuorumPercentage
+		totalSupply := 30.0
+		p.Quorum = &quorum
+		p.Quorum_type = &quorumType
+		p.Total_supply = &totalSupply
+		results := newResults(1, 3)
+		results.ComputeOutcome(&p)
+
+		assert.True(t, results.Quorum_met)
+		assert.True(t, results.Passed)
+	})
+
+	t.Run("Should only allow a percentage quorum for token weighted strategies", func(t *testing.T) {
+		quorum := 10.0
+		quorumType := models.QuorumPercentage
+
+		p := *proposal
+		p.Quorum = &quorum
+		p.Quorum_type = &quorumType
+		assert.Nil(t, p.ValidateOutcomeRules())
+
+		quadratic := "quadratic-token-weighted"
+		p.Strategy = &quadratic
+		assert.NotNil(t, p.ValidateOutcomeRules())
+
+		oneAddressOneVote := "one-address-one-vote"
+		p.Strategy = &oneAddressOneVote
+		assert.NotNil(t, p.ValidateOutcomeRules())
+	})
+
+	t.Run("Should not pass when the winner misses the pass threshold", func(t *testing.T) {
+		p := *proposal
+		threshold := 66.0
+		p.Pass_threshold = &threshold
+		results := newResults(4, 6)
+		results.ComputeOutcome(&p)
+
+		assert.True(t, results.Quorum_met)
+		assert.False(t, results.Passed)
+		assert.Equal(t, "b", *results.Winning_choice)
+	})
+
+	t.Run("Should have no winner on a tie", func(t *testing.T) {
+		results := newResults(2, 2)
+		results.ComputeOutcome(proposal)
+
+		assert.Nil(t, results.Winning_choice)
+		assert.False(t, results.Passed)
+	})
+}
+
 func TestUpdateProposal(t *testing.T) {
 	clearTable("communities")
 	clearTable("community_users")
@@ -255,3 +341,73 @@ func TestCreateManyProposals(t *testing.T) {
 	})
 
 }
+
+func TestProposalScheduler(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("proposal_results")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+	t.Run("Should persist the active status of a started proposal", func(t *testing.T) {
+		err := otu.A.Scheduler.RunOnce()
+		assert.Nil(t, err)
+
+		p := models.Proposal{ID: proposalId}
+		p.GetProposalById(otu.A.DB)
+		assert.Equal(t, models.LifecycleActive, p.Lifecycle_status)
+	})
+
+	t.Run("Should finalize results once a proposal ends", func(t *testing.T) {
+		otu.CreateVoteAPI(proposalId, otu.GenerateValidVotePayload("user1", proposalId, "a"))
+		otu.UpdateProposalEndTime(proposalId, time.Now().UTC())
+
+		err := otu.A.Scheduler.RunOnce()
+		assert.Nil(t, err)
+
+		p := models.Proposal{ID: proposalId}
+		p.GetProposalById(otu.A.DB)
+		assert.Equal(t, models.LifecycleClosed, p.Lifecycle_status)
+		assert.True(t, p.Achievements_done)
+
+		results := models.ProposalResults{Proposal_id: proposalId}
+		err = results.GetLatestProposalResultsById(otu.A.DB)
+		assert.Nil(t, err)
+		assert.NotNil(t, results.Cid)
+		assert.Equal(t, 1, results.Results["a"])
+	})
+
+	t.Run("Should not rerun hooks for proposals already transitioned", func(t *testing.T) {
+		stored := models.ProposalResults{Proposal_id: proposalId}
+		stored.GetLatestProposalResultsById(otu.A.DB)
+
+		err := otu.A.Scheduler.RunOnce()
+		assert.Nil(t, err)
+
+		due, err := models.GetProposalsDueForTransition(otu.A.DB, 50)
+		assert.Nil(t, err)
+		assert.Empty(t, due)
+
+		results := models.ProposalResults{Proposal_id: proposalId}
+		results.GetLatestProposalResultsById(otu.A.DB)
+		assert.Equal(t, stored.Updated_at, results.Updated_at)
+	})
+
+	t.Run("Should skip a run while another replica holds the lease", func(t *testing.T) {
+		_, err := otu.A.DB.Conn.Exec(otu.A.DB.Context, `
+			INSERT INTO scheduler_leases(name, holder, expires_at)
+			VALUES('proposal-scheduler', $1, (now() at time zone 'utc') + interval '1 minute')
+		`, uuid.New())
+		assert.Nil(t, err)
+		defer otu.A.DB.Conn.Exec(otu.A.DB.Context, `DELETE FROM scheduler_leases`)
+
+		pending := otu.AddActiveProposals(communityId, 1)[0]
+		assert.Nil(t, otu.A.Scheduler.RunOnce())
+
+		p := models.Proposal{ID: pending}
+		p.GetProposalById(otu.A.DB)
+		assert.Equal(t, models.LifecyclePending, p.Lifecycle_status)
+	})
+}
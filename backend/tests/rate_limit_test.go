@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/middleware"
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Should parse budgets", func(t *testing.T) {
+		limit, err := middleware.ParseRateLimit("10/1m")
+		assert.Nil(t, err)
+		assert.Equal(t, middleware.RateLimit{Requests: 10, Window: time.Minute}, limit)
+
+		for _, invalid := range []string{"10", "0/1m", "ten/1m", "10/soon"} {
+			_, err := middleware.ParseRateLimit(invalid)
+			assert.NotNil(t, err, invalid)
+		}
+
+		assert.Nil(t, middleware.ValidateRateLimits(otu.A.Config.RateLimits))
+		assert.NotNil(t, middleware.ValidateRateLimits(map[string]string{"vote": "30/1m", "search": "often"}))
+	})
+
+	t.Run("Should refill buckets over the window", func(t *testing.T) {
+		limiter := middleware.NewRateLimiter()
+		limit := middleware.RateLimit{Requests: 1, Window: 50 * time.Millisecond}
+
+		allowed, _ := limiter.Allow("key", limit)
+		assert.True(t, allowed)
+		allowed, retryAfter := limiter.Allow("key", limit)
+		assert.False(t, allowed)
+		assert.True(t, retryAfter > 0 && retryAfter <= limit.Window)
+
+		allowed, _ = limiter.Allow("other", limit)
+		assert.True(t, allowed)
+
+		time.Sleep(limit.Window)
+		allowed, _ = limiter.Allow("key", limit)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Should limit a route to its budget", func(t *testing.T) {
+		clearTable("communities")
+
+		budget := otu.A.Config.RateLimits["search"]
+		otu.A.Config.Features["useRateLimits"] = true
+		otu.A.Config.RateLimits["search"] = "2/1m"
+		defer func() {
+			otu.A.Config.Features["useRateLimits"] = false
+			otu.A.Config.RateLimits["search"] = budget
+		}()
+
+		for i := 0; i < 2; i++ {
+			response := otu.GetSearchCommunitiesAPI([]string{}, "dao", nil)
+			checkResponseCode(t, http.StatusOK, response.Code)
+		}
+
+		response := otu.GetSearchCommunitiesAPI([]string{}, "dao", nil)
+		checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+		assert.NotEmpty(t, response.Header().Get("Retry-After"))
+
+		var e errorResponse
+		json.Unmarshal(response.Body.Bytes(), &e)
+		assert.Equal(t, errTooManyRequests, e)
+	})
+	t.Run("Should key clients by the hop their proxy appended", func(t *testing.T) {
+		clearTable("communities")
+
+		budget := otu.A.Config.RateLimits["search"]
+		otu.A.Config.Features["useRateLimits"] = true
+		otu.A.Config.Features["trustForwardedFor"] = true
+		otu.A.Config.RateLimits["search"] = "2/1m"
+		defer func() {
+			otu.A.Config.Features["useRateLimits"] = false
+			otu.A.Config.Features["trustForwardedFor"] = false
+			otu.A.Config.RateLimits["search"] = budget
+		}()
+
+		// the client rotates the entries it controls, the proxy appends its address
+		for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+			req, _ := http.NewRequest("GET", "/communities/search?text=dao", nil)
+			req.Header.Set("X-Forwarded-For", "192.0.2."+strconv.Itoa(i)+", 10.0.0.9")
+			response := otu.ExecuteRequest(req)
+			checkResponseCode(t, code, response.Code)
+		}
+	})
+
+	t.Run("Should only charge a signer's budget once the signature is verified", func(t *testing.T) {
+		clearTable("communities")
+		clearTable("proposals")
+		clearTable("votes")
+		communityId := otu.AddCommunities(1, "dao")[0]
+		proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+		budget := otu.A.Config.RateLimits["vote"]
+		otu.A.Config.Features["useRateLimits"] = true
+		otu.A.Config.Features["trustForwardedFor"] = true
+		otu.A.Config.RateLimits["vote"] = "2/1m"
+		defer func() {
+			otu.A.Config.Features["useRateLimits"] = false
+			otu.A.Config.Features["trustForwardedFor"] = false
+			otu.A.Config.RateLimits["vote"] = budget
+		}()
+
+		victim := otu.GenerateValidVotePayload("user1", proposalId, "a")
+
+		// votes naming the victim but signed by someone else
+		for i := 0; i < 2; i++ {
+			forged := otu.GenerateValidVotePayload("user2", proposalId, "a")
+			forged.Addr = victim.Addr
+			response := voteFromIP("POST", proposalId, forged, "10.0.0.1")
+			checkResponseCode(t, http.StatusBadRequest, response.Code)
+		}
+
+		response := voteFromIP("POST", proposalId, victim, "10.0.0.2")
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		// the victim's own verified votes still count against their budget
+		response = voteFromIP("PUT", proposalId, otu.GenerateValidVotePayload("user1", proposalId, "b"), "10.0.0.3")
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		response = voteFromIP("PUT", proposalId, otu.GenerateValidVotePayload("user1", proposalId, "a"), "10.0.0.4")
+		checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+		assert.NotEmpty(t, response.Header().Get("Retry-After"))
+
+		// once the victim is over budget, votes naming them are turned away
+		// before the signature is checked
+		forged := otu.GenerateValidVotePayload("user2", proposalId, "b")
+		forged.Addr = victim.Addr
+		response = voteFromIP("PUT", proposalId, forged, "10.0.0.5")
+		checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+	})
+
+	t.Run("Should limit nonces", func(t *testing.T) {
+		clearTable("nonces")
+
+		budget := otu.A.Config.RateLimits["nonce"]
+		otu.A.Config.Features["useRateLimits"] = true
+		otu.A.Config.RateLimits["nonce"] = "1/1m"
+		defer func() {
+			otu.A.Config.Features["useRateLimits"] = false
+			otu.A.Config.RateLimits["nonce"] = budget
+		}()
+
+		for _, code := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+			response := otu.CreateNonceAPI(models.ActionListCreate, "1")
+			checkResponseCode(t, code, response.Code)
+		}
+	})
+}
+
+func voteFromIP(method string, proposalId int, vote *models.Vote, ip string) *httptest.ResponseRecorder {
+	url := "/proposals/" + strconv.Itoa(proposalId) + "/votes"
+	if method == "PUT" {
+		url = url + "/" + vote.Addr
+	}
+	body, _ := json.Marshal(vote)
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", ip)
+	return otu.ExecuteRequest(req)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadScripts(t *testing.T) {
+	raw, err := shared.ReadScript(shared.CustomScriptsJSON)
+	assert.Nil(t, err)
+
+	var customScripts []shared.CustomScript
+	assert.Nil(t, json.Unmarshal(raw, &customScripts))
+
+	customScriptsMap := map[string]shared.CustomScript{}
+	for _, script := range customScripts {
+		customScriptsMap[script.Key] = script
+	}
+
+	t.Run("Should load every embedded script", func(t *testing.T) {
+		scripts, err := shared.LoadScripts(customScriptsMap)
+		assert.Nil(t, err)
+
+		code, err := scripts.Source(shared.ScriptGetNFTIds)
+		assert.Nil(t, err)
+		assert.Contains(t, string(code), "NON_FUNGIBLE_TOKEN_ADDRESS")
+	})
+
+	t.Run("Should fail when a custom script is missing", func(t *testing.T) {
+		missing := map[string]shared.CustomScript{
+			"missing": {Key: "missing", Src: "missing.cdc"},
+		}
+		_, err := shared.LoadScripts(missing)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should cache substituted scripts by key", func(t *testing.T) {
+		scripts, err := shared.LoadScripts(nil)
+		assert.Nil(t, err)
+
+		calls := 0
+		substitute := func() []byte {
+			calls++
+			return []byte("code")
+		}
+		scripts.Substituted("key", substitute)
+		scripts.Substituted("key", substitute)
+		assert.Equal(t, 1, calls)
+	})
+}
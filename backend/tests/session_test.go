@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+/*****************/
+/*   Sessions    */
+/*****************/
+
+func TestSessionTokens(t *testing.T) {
+	tokens := shared.NewSessionTokens([]byte("secret"), time.Minute, time.Hour)
+
+	t.Run("Should parse the tokens it issues", func(t *testing.T) {
+		token, expires, err := tokens.Issue("0x01cf0e2f2f715450", "session-id")
+		assert.Nil(t, err)
+		assert.True(t, expires.After(time.Now()))
+
+		claims, err := tokens.Parse(token)
+		assert.Nil(t, err)
+		assert.Equal(t, "0x01cf0e2f2f715450", claims.Addr)
+		assert.Equal(t, "session-id", claims.Session_id)
+	})
+
+	t.Run("Should reject tokens signed with another secret", func(t *testing.T) {
+		other := shared.NewSessionTokens([]byte("other"), time.Minute, time.Hour)
+		token, _, _ := other.Issue("0x01cf0e2f2f715450", "session-id")
+
+		_, err := tokens.Parse(token)
+		assert.Equal(t, shared.ErrInvalidSessionToken, err)
+	})
+
+	t.Run("Should reject expired tokens", func(t *testing.T) {
+		expired := shared.NewSessionTokens([]byte("secret"), -time.Minute, time.Hour)
+		token, _, _ := expired.Issue("0x01cf0e2f2f715450", "session-id")
+
+		_, err := tokens.Parse(token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should require a secret outside DEV and TEST", func(t *testing.T) {
+		secret := os.Getenv("SESSION_SECRET")
+		os.Setenv("SESSION_SECRET", "")
+		defer os.Setenv("SESSION_SECRET", secret)
+
+		_, err := shared.SessionTokensFromEnv()
+		assert.Nil(t, err)
+
+		os.Setenv("APP_ENV", "PRODUCTION")
+		defer os.Setenv("APP_ENV", "TEST")
+
+		_, err = shared.SessionTokensFromEnv()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("lists")
+	clearTable("sessions")
+
+	t.Run("Should sign in with a signed message", func(t *testing.T) {
+		payload := otu.GenerateSignInPayload("user1")
+		response := otu.SignInAPI(payload)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var session models.SessionResponse
+		json.Unmarshal(response.Body.Bytes(), &session)
+		assert.NotEmpty(t, session.Token)
+		assert.NotEmpty(t, session.Refresh_token)
+		assert.Equal(t, payload.Addr, session.Addr)
+	})
+
+	t.Run("Should not reuse a sign in nonce", func(t *testing.T) {
+		payload := otu.GenerateSignInPayload("user1")
+		checkResponseCode(t, http.StatusCreated, otu.SignInAPI(payload).Code)
+		checkResponseCode(t, http.StatusForbidden, otu.SignInAPI(payload).Code)
+	})
+
+	t.Run("Should create a list with a session instead of a signature", func(t *testing.T) {
+		communityId := otu.AddCommunitiesWithUsers(1, "user1")[0]
+		session := otu.SignIn("user1")
+
+		payload := &models.ListPayload{List: *otu.GenerateBlockListStruct(communityId)}
+		response := otu.CreateListWithSessionAPI(payload, session.Token)
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("Should not grant a session another address' roles", func(t *testing.T) {
+		communityId := otu.AddCommunitiesWithUsers(1, "user1")[0]
+		session := otu.SignIn("user2")
+
+		payload := &models.ListPayload{List: *otu.GenerateBlockListStruct(communityId)}
+		response := otu.CreateListWithSessionAPI(payload, session.Token)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+	})
+
+	t.Run("Should refresh a session once per refresh token", func(t *testing.T) {
+		session := otu.SignIn("user1")
+
+		response := otu.RefreshSessionAPI(session.Refresh_token)
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var refreshed models.SessionResponse
+		json.Unmarshal(response.Body.Bytes(), &refreshed)
+		assert.NotEqual(t, session.Refresh_token, refreshed.Refresh_token)
+
+		response = otu.RefreshSessionAPI(session.Refresh_token)
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	})
+
+	t.Run("Should reject tokens of revoked sessions", func(t *testing.T) {
+		communityId := otu.AddCommunitiesWithUsers(1, "user1")[0]
+		session := otu.SignIn("user1")
+
+		checkResponseCode(t, http.StatusOK, otu.RevokeSessionAPI(session.Token).Code)
+
+		payload := &models.ListPayload{List: *otu.GenerateBlockListStruct(communityId)}
+		response := otu.CreateListWithSessionAPI(payload, session.Token)
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+		response = otu.RefreshSessionAPI(session.Refresh_token)
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	})
+}
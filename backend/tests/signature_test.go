@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKey struct {
+	key    *flow.AccountKey
+	signer crypto.Signer
+}
+
+func newTestKey(t *testing.T, index int, sigAlgo crypto.SignatureAlgorithm, hashAlgo crypto.HashAlgorithm, weight int) testKey {
+	seed := make([]byte, crypto.MinSeedLength)
+	seed[0] = byte(index + 1)
+	privateKey, err := crypto.GeneratePrivateKey(sigAlgo, seed)
+	assert.Nil(t, err)
+
+	signer, err := crypto.NewInMemorySigner(privateKey, hashAlgo)
+	assert.Nil(t, err)
+
+	return testKey{
+		key: &flow.AccountKey{
+			Index:     index,
+			PublicKey: privateKey.PublicKey(),
+			SigAlgo:   sigAlgo,
+			HashAlgo:  hashAlgo,
+			Weight:    weight,
+		},
+		signer: signer,
+	}
+}
+
+func (k testKey) sign(t *testing.T, tag []byte, message string) shared.CompositeSignature {
+	messageBytes, _ := hex.DecodeString(message)
+	signature, err := k.signer.Sign(append(append([]byte{}, tag...), messageBytes...))
+	assert.Nil(t, err)
+	return shared.CompositeSignature{Key_id: uint(k.key.Index), Signature: hex.EncodeToString(signature)}
+}
+
+func TestVerifyAccountSignatures(t *testing.T) {
+	message := hex.EncodeToString([]byte("cast a vote"))
+
+	t.Run("Should verify P-256 and secp256k1 keys with SHA2 and SHA3", func(t *testing.T) {
+		keys := []testKey{
+			newTestKey(t, 0, crypto.ECDSA_P256, crypto.SHA3_256, 1000),
+			newTestKey(t, 1, crypto.ECDSA_P256, crypto.SHA2_256, 1000),
+			newTestKey(t, 2, crypto.ECDSA_secp256k1, crypto.SHA3_256, 1000),
+			newTestKey(t, 3, crypto.ECDSA_secp256k1, crypto.SHA2_256, 1000),
+		}
+		accountKeys := []*flow.AccountKey{keys[0].key, keys[1].key, keys[2].key, keys[3].key}
+
+		for _, k := range keys {
+			sigs := []shared.CompositeSignature{k.sign(t, flow.UserDomainTag[:], message)}
+			valid, err := shared.VerifyAccountSignatures(accountKeys, message, sigs, "USER")
+			assert.Nil(t, err)
+			assert.True(t, valid)
+		}
+	})
+
+	t.Run("Should require the key weights to sum to 1000", func(t *testing.T) {
+		a := newTestKey(t, 0, crypto.ECDSA_P256, crypto.SHA3_256, 500)
+		b := newTestKey(t, 1, crypto.ECDSA_P256, crypto.SHA3_256, 500)
+		accountKeys := []*flow.AccountKey{a.key, b.key}
+
+		one := []shared.CompositeSignature{a.sign(t, flow.UserDomainTag[:], message)}
+		valid, err := shared.VerifyAccountSignatures(accountKeys, message, one, "USER")
+		assert.Nil(t, err)
+		assert.False(t, valid)
+
+		both := append(one, b.sign(t, flow.UserDomainTag[:], message))
+		valid, err = shared.VerifyAccountSignatures(accountKeys, message, both, "USER")
+		assert.Nil(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("Should count each key's weight once", func(t *testing.T) {
+		k := newTestKey(t, 0, crypto.ECDSA_P256, crypto.SHA3_256, 500)
+		sig := k.sign(t, flow.UserDomainTag[:], message)
+
+		sigs := []shared.CompositeSignature{sig, sig}
+		valid, err := shared.VerifyAccountSignatures([]*flow.AccountKey{k.key}, message, sigs, "USER")
+		assert.Nil(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("Should skip revoked keys", func(t *testing.T) {
+		k := newTestKey(t, 0, crypto.ECDSA_P256, crypto.SHA3_256, 1000)
+		k.key.Revoked = true
+
+		sigs := []shared.CompositeSignature{k.sign(t, flow.UserDomainTag[:], message)}
+		valid, err := shared.VerifyAccountSignatures([]*flow.AccountKey{k.key}, message, sigs, "USER")
+		assert.Nil(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("Should use the domain tag of the message type", func(t *testing.T) {
+		k := newTestKey(t, 0, crypto.ECDSA_P256, crypto.SHA3_256, 1000)
+		accountKeys := []*flow.AccountKey{k.key}
+
+		sigs := []shared.CompositeSignature{k.sign(t, flow.TransactionDomainTag[:], message)}
+		valid, err := shared.VerifyAccountSignatures(accountKeys, message, sigs, "TRANSACTION")
+		assert.Nil(t, err)
+		assert.True(t, valid)
+
+		valid, err = shared.VerifyAccountSignatures(accountKeys, message, sigs, "USER")
+		assert.Nil(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("Should cache account keys until the TTL expires", func(t *testing.T) {
+		cache := shared.NewAccountKeyCache(50 * time.Millisecond)
+		fetches := 0
+		fetch := func() ([]*flow.AccountKey, error) {
+			fetches++
+			return []*flow.AccountKey{}, nil
+		}
+
+		_, cached, _ := cache.Get("0x01", fetch)
+		assert.False(t, cached)
+		_, cached, _ = cache.Get("0x01", fetch)
+		assert.True(t, cached)
+		assert.Equal(t, 1, fetches)
+
+		time.Sleep(60 * time.Millisecond)
+		_, cached, _ = cache.Get("0x01", fetch)
+		assert.False(t, cached)
+		assert.Equal(t, 2, fetches)
+	})
+
+	t.Run("Should evict expired account keys", func(t *testing.T) {
+		cache := shared.NewAccountKeyCache(50 * time.Millisecond)
+		fetch := func() ([]*flow.AccountKey, error) {
+			return []*flow.AccountKey{}, nil
+		}
+
+		cache.Get("0x01", fetch)
+		cache.Get("0x02", fetch)
+		assert.Equal(t, 2, cache.Len())
+
+		time.Sleep(60 * time.Millisecond)
+		cache.Get("0x03", fetch)
+		assert.Equal(t, 1, cache.Len())
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancePrefetcher(t *testing.T) {
+	clearTable("communities")
+	clearTable("proposals")
+	clearTable("balances")
+	clearTable("lists")
+
+	// serve balances from fixtures instead of the emulator
+	adapter := otu.A.FlowAdapter
+	otu.A.FlowAdapter = newFakeChainReader(t)
+	defer func() { otu.A.FlowAdapter = adapter }()
+
+	communityId := otu.AddCommunities(1, "dao")[0]
+	allow := "allow"
+	list := models.List{
+		Community_id: communityId,
+		Addresses:    []string{fixtureAddr, "0xf8d6e0586b0a20c7", fixtureAddr},
+		List_type:    &allow,
+	}
+	assert.Nil(t, list.CreateList(otu.A.DB))
+
+	_, proposals := otu.AddProposalsForStrategy(communityId, "token-weighted-default", 1)
+	p := *proposals[0]
+
+	t.Run("Should store the allowlist's balances", func(t *testing.T) {
+		addresses := []string{fixtureAddr, "0xf8d6e0586b0a20c7"}
+		assert.Nil(t, otu.A.BalancePrefetcher.Run(p, addresses))
+
+		assert.Nil(t, p.GetProposalById(otu.A.DB))
+		assert.Equal(t, models.SnapshotComplete, *p.Snapshot_status)
+		assert.Equal(t, 2, *p.Snapshot_total)
+		assert.Equal(t, 2, *p.Snapshot_fetched)
+		assert.True(t, p.HasPrefetchedBalances())
+
+		b := models.Balance{Addr: fixtureAddr, BlockHeight: *p.Block_height, Source: p.Balance_source}
+		assert.Nil(t, b.GetBalanceByAddressAndBlockHeight(otu.A.DB))
+		assert.Equal(t, uint64(10*100000000), b.PrimaryAccountBalance)
+	})
+
+	t.Run("Should skip balances already stored", func(t *testing.T) {
+		assert.Nil(t, otu.A.BalancePrefetcher.Run(p, []string{fixtureAddr}))
+
+		var count int
+		err := otu.A.DB.Conn.QueryRow(otu.A.DB.Context,
+			"SELECT COUNT(*) FROM balances WHERE addr = $1", fixtureAddr).Scan(&count)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Should not reuse a balance stored for another source", func(t *testing.T) {
+		composite := models.BalanceSource("composite-weighted", shared.Contract{})
+		other := p
+		other.Balance_source = &composite
+		assert.Nil(t, otu.A.BalancePrefetcher.Run(other, []string{fixtureAddr}))
+
+		var count int
+		err := otu.A.DB.Conn.QueryRow(otu.A.DB.Context,
+			"SELECT COUNT(*) FROM balances WHERE addr = $1", fixtureAddr).Scan(&count)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+
+		b := models.Balance{Addr: fixtureAddr, BlockHeight: *p.Block_height, Source: &composite}
+		assert.Nil(t, b.GetBalanceByAddressAndBlockHeight(otu.A.DB))
+	})
+
+	t.Run("Should only prefetch opted in strategies with an allowlist", func(t *testing.T) {
+		var c models.Community
+		assert.Nil(t, c.GetCommunityByProposalId(otu.A.DB, p.ID))
+		strategy, err := c.GetStrategy(*p.Strategy)
+		assert.Nil(t, err)
+
+		addresses, err := otu.A.BalancePrefetcher.Addresses(p, strategy)
+		assert.Nil(t, err)
+		assert.Nil(t, addresses)
+
+		prefetch := true
+		strategy.Prefetch_balances = &prefetch
+		addresses, err = otu.A.BalancePrefetcher.Addresses(p, strategy)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{fixtureAddr, "0xf8d6e0586b0a20c7"}, addresses)
+	})
+}
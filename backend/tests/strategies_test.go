@@ -2,14 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
 	"github.com/DapperCollectives/CAST/backend/main/strategies"
 	utils "github.com/DapperCollectives/CAST/backend/tests/test_utils"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,16 +20,19 @@ type Strategy interface {
 	TallyVotes(votes []*models.VoteWithBalance, p *models.ProposalResults, proposal *models.Proposal) (models.ProposalResults, error)
 	GetVotes(votes []*models.VoteWithBalance, proposal *models.Proposal) ([]*models.VoteWithBalance, error)
 	GetVoteWeightForBalance(vote *models.VoteWithBalance, proposal *models.Proposal) (float64, error)
-	InitStrategy(f *shared.FlowAdapter, db *shared.Database)
+	InitStrategy(f shared.ChainReader, db *shared.Database)
 	FetchBalance(b *models.Balance, p *models.Proposal) (*models.Balance, error)
 	RequiresSnapshot() bool
 }
 
 var strategyMap = map[string]Strategy{
 	"token-weighted-default":        &strategies.TokenWeightedDefault{},
+	"quadratic-token-weighted":      &strategies.QuadraticTokenWeighted{},
 	"staked-token-weighted-default": &strategies.StakedTokenWeightedDefault{},
+	"total-token-weighted-default":  &strategies.TotalTokenWeightedDefault{},
 	"one-address-one-vote":          &strategies.OneAddressOneVote{},
 	"balance-of-nfts":               &strategies.BalanceOfNfts{},
+	"composite-weighted":            &strategies.CompositeWeighted{},
 }
 
 /* Token Weighted Default */
@@ -116,6 +122,29 @@ func TestTokenWeightedDefaultStrategy(t *testing.T) {
 			assert.Equal(t, _expectedWeight, *v.Weight)
 		}
 	})
+
+	t.Run("Test Fetching Votes For Address ignores balances at other block heights", func(t *testing.T) {
+		_vote := (votes)[0]
+		staleBalance := 99 * *_vote.PrimaryAccountBalance
+		balance := models.Balance{
+			Addr:                  _vote.Addr,
+			PrimaryAccountBalance: staleBalance,
+			BlockHeight:           *_vote.BlockHeight + 1,
+		}
+		if err := balance.CreateBalance(otu.A.DB); err != nil {
+			t.Fatalf("Error adding balance: %s", err)
+		}
+
+		response := otu.GetVotesForAddressAPI(_vote.Addr, []int{proposalId, proposalIdTwo})
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body utils.PaginatedResponseWithVotes
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		assert.Equal(t, 1, len(body.Data))
+		_expectedWeight := float64(*_vote.PrimaryAccountBalance) * math.Pow(10, -8)
+		assert.Equal(t, _expectedWeight, *body.Data[0].Weight)
+	})
 }
 
 /* Balance of NFT */
@@ -191,6 +220,54 @@ func TestBalanceOfNFTsStrategy(t *testing.T) {
 		assert.Equal(t, expectedWeight, *vote.Weight)
 	})
 
+	t.Run("Test NFTs Are Counted At The Snapshot Block Height", func(t *testing.T) {
+		addr := otu.ResolveUser(1)
+		scriptPath := shared.ScriptGetNFTIds
+
+		blockHeight, err := otu.A.FlowAdapter.GetCurrentBlockHeight()
+		assert.Nil(t, err)
+
+		// an NFT received after the snapshot doesn't count
+		otu.MintNFT(shared.MintParams{
+			Recipient:            "user1",
+			Name:                 "user1",
+			Description:          "minted after the snapshot",
+			Cuts:                 []float64{0.8},
+			RoyaltyDescriptions:  []string{"minted after the snapshot"},
+			RoyaltyBeneficiaries: []string{"0xf8d6e0586b0a20c7"},
+		})
+
+		snapshotIds, err := otu.A.FlowAdapter.GetNFTIds(addr, contract, scriptPath, uint64(blockHeight))
+		assert.Nil(t, err)
+		latestIds, err := otu.A.FlowAdapter.GetNFTIds(addr, contract, scriptPath, 0)
+		assert.Nil(t, err)
+
+		assert.Equal(t, len(latestIds)-1, len(snapshotIds))
+	})
+
+	t.Run("Test NFTs Are Not Read At The Latest Block When The Snapshot Is Gone", func(t *testing.T) {
+		addr := otu.ResolveUser(1)
+		scriptPath := shared.ScriptGetNFTIds
+
+		blockHeight, err := otu.A.FlowAdapter.GetCurrentBlockHeight()
+		assert.Nil(t, err)
+		unavailable := uint64(blockHeight) + 1000000
+
+		_, err = otu.A.FlowAdapter.GetNFTIds(addr, contract, scriptPath, unavailable)
+		assert.True(t, errors.Is(err, shared.ErrSnapshotUnavailable))
+
+		// strategies can opt in to the latest block
+		fallback := true
+		withFallback := *contract
+		withFallback.Latest_block_fallback = &fallback
+
+		fallbackIds, err := otu.A.FlowAdapter.GetNFTIds(addr, &withFallback, scriptPath, unavailable)
+		assert.Nil(t, err)
+		latestIds, err := otu.A.FlowAdapter.GetNFTIds(addr, contract, scriptPath, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, len(latestIds), len(fallbackIds))
+	})
+
 	// t.Run("Attempt to cheat the NFT strategy", func(t *testing.T) {
 	// 	proposalWithChoices := models.NewProposalResults(proposalId, choices)
 	// 	_ = otu.TallyResultsForBalanceOfNfts(votes, proposalWithChoices)
@@ -226,6 +303,77 @@ func TestBalanceOfNFTsStrategy(t *testing.T) {
 	// })
 }
 
+/* Trait Weighted NFTs */
+func TestTraitWeightedNFTsStrategy(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("balances")
+	clearTable("nfts")
+
+	communityId, community := otu.AddCommunitiesWithNFTContract(1, "user1")
+	proposalIds, _ := otu.AddProposalsForStrategy(communityId[0], "trait-weighted-nfts", 1)
+
+	var contract = &shared.Contract{
+		Name:        community.Contract_name,
+		Addr:        community.Contract_addr,
+		Public_path: community.Public_path,
+	}
+
+	_, err := otu.GenerateListOfVotesWithNFTs(proposalIds[0], 1, contract)
+	if err != nil {
+		t.Error(err)
+	}
+
+	double := 2.0
+
+	t.Run("Test Reading NFT Traits", func(t *testing.T) {
+		nfts, err := otu.A.FlowAdapter.GetNFTTraits(otu.ResolveUser(1), contract, 0)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, nfts)
+
+		// ExampleNFT gives every NFT the trait foo: bar
+		for _, traits := range nfts {
+			assert.Equal(t, "bar", traits["foo"])
+		}
+	})
+
+	t.Run("Test Filtering NFTs By Trait", func(t *testing.T) {
+		traits := map[string]string{"foo": "bar", "tier": "rare"}
+
+		weight, ok := shared.NFTTraitWeight(traits, []shared.TraitFilter{
+			{Name: "foo", Values: []string{"bar", "baz"}},
+		})
+		assert.True(t, ok)
+		assert.Equal(t, 1.0, weight)
+
+		_, ok = shared.NFTTraitWeight(traits, []shared.TraitFilter{
+			{Name: "foo", Values: []string{"baz"}},
+		})
+		assert.False(t, ok)
+
+		_, ok = shared.NFTTraitWeight(traits, []shared.TraitFilter{
+			{Name: "color", Values: []string{"red"}},
+		})
+		assert.False(t, ok)
+	})
+
+	t.Run("Test Weighting NFTs By Trait", func(t *testing.T) {
+		traits := map[string]string{"foo": "bar", "tier": "rare"}
+		filters := []shared.TraitFilter{
+			{Name: "foo", Values: []string{"bar"}},
+			{Name: "tier", Values: []string{"rare"}, Multiplier: &double},
+			{Name: "color", Multiplier: &double},
+		}
+
+		// the color multiplier doesn't apply since the NFT has no color
+		weight, ok := shared.NFTTraitWeight(traits, filters)
+		assert.True(t, ok)
+		assert.Equal(t, 2.0, weight)
+	})
+}
+
 /* Staked Token Weighted Default */
 func TestStakedTokenWeightedDefaultStrategy(t *testing.T) {
 	clearTable("communities")
@@ -252,6 +400,7 @@ func TestStakedTokenWeightedDefaultStrategy(t *testing.T) {
 		if err != nil {
 			t.Errorf("Error tallying votes: %v", err)
 		}
+		_results.ComputeOutcome(proposals[0])
 
 		// Fetch Proposal Results
 		response := otu.GetProposalResultsAPI(proposalId)
@@ -308,6 +457,354 @@ func TestStakedTokenWeightedDefaultStrategy(t *testing.T) {
 	})
 }
 
+/* Quadratic Token Weighted */
+func TestQuadraticTokenWeightedStrategy(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("balances")
+
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalIds, proposals := otu.AddProposalsForStrategy(communityId, "quadratic-token-weighted", 1)
+	proposalId := proposalIds[0]
+	choices := proposals[0].Choices
+	votes := otu.GenerateListOfVotes(proposalId, 10)
+	otu.AddDummyVotesAndBalances(votes)
+
+	t.Run("Test Tallying Results", func(t *testing.T) {
+		strategyName := "quadratic-token-weighted"
+
+		s := strategyMap[strategyName]
+		proposalWithChoices := models.NewProposalResults(proposalId, choices)
+		_results, err := s.TallyVotes(votes, proposalWithChoices, proposals[0])
+		if err != nil {
+			t.Errorf("Error tallying votes: %v", err)
+		}
+
+		response := otu.GetProposalResultsAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var results models.ProposalResults
+		json.Unmarshal(response.Body.Bytes(), &results)
+
+		assert.Equal(t, _results.Results_float["a"], results.Results_float["a"])
+		assert.Equal(t, _results.Results_float["b"], results.Results_float["b"])
+	})
+
+	t.Run("Test Fetching Votes for Proposal", func(t *testing.T) {
+		response := otu.GetVotesForProposalAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body utils.PaginatedResponseWithVotes
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		// Validate vote weights are the square root of the balance
+		for i, v := range body.Data {
+			_vote := (votes)[i]
+			expectedWeight := math.Sqrt(float64(*_vote.PrimaryAccountBalance) * math.Pow(10, -8))
+			assert.Equal(t, expectedWeight, *v.Weight)
+		}
+	})
+
+	t.Run("Test Max Weight Caps Quadratic Weight", func(t *testing.T) {
+		maxWeight := 2.0
+		proposal := *proposals[0]
+		proposal.Max_weight = &maxWeight
+
+		s := strategyMap["quadratic-token-weighted"]
+		weight, err := s.GetVoteWeightForBalance(votes[len(votes)-1], &proposal)
+		if err != nil {
+			t.Errorf("Error getting vote weight: %v", err)
+		}
+
+		assert.Equal(t, maxWeight, weight)
+	})
+}
+
+/* Composite Weighted */
+func TestCompositeWeightedStrategy(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("balances")
+
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalIds, proposals := otu.AddProposalsForStrategy(communityId, "composite-weighted", 1)
+	proposalId := proposalIds[0]
+	choices := proposals[0].Choices
+	votes := otu.GenerateListOfVotes(proposalId, 3)
+
+	// balance is FLOW held plus 10 per NFT held
+	for i, vote := range votes {
+		flow := shared.FromFixedPoint(*vote.PrimaryAccountBalance, shared.DefaultDecimals)
+		nfts := float64(i)
+		components := []models.BalanceComponent{
+			{Name: "FlowToken", Type: shared.ComponentFT, Balance: flow, Multiplier: 1, Weight: flow},
+			{Name: "ExampleNFT", Type: shared.ComponentNFT, Balance: nfts, Multiplier: 10, Weight: nfts * 10},
+		}
+		total := shared.ToFixedPoint(flow+nfts*10, shared.DefaultDecimals)
+		vote.PrimaryAccountBalance = &total
+		vote.Components = &components
+	}
+	otu.AddDummyVotesAndBalances(votes)
+
+	t.Run("Test Tallying Results", func(t *testing.T) {
+		s := strategyMap["composite-weighted"]
+		proposalWithChoices := models.NewProposalResults(proposalId, choices)
+		_results, err := s.TallyVotes(votes, proposalWithChoices, proposals[0])
+		if err != nil {
+			t.Errorf("Error tallying votes: %v", err)
+		}
+
+		// 1 + 0, 2 + 10 and 3 + 20
+		total := _results.Results_float["a"] + _results.Results_float["b"]
+		assert.Equal(t, 36.0, total)
+	})
+
+	t.Run("Test Fetching Votes for Proposal", func(t *testing.T) {
+		response := otu.GetVotesForProposalAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var body utils.PaginatedResponseWithVotes
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		for i, v := range body.Data {
+			_vote := votes[i]
+			expectedWeight := shared.FromFixedPoint(*_vote.PrimaryAccountBalance, shared.DefaultDecimals)
+			assert.Equal(t, expectedWeight, *v.Weight)
+			assert.Equal(t, *_vote.Components, *v.Components)
+		}
+	})
+
+	t.Run("Should not weigh votes with another strategy's balance at the same height", func(t *testing.T) {
+		source := models.BalanceSource("token-weighted-default", shared.Contract{})
+		tokenBalance := models.Balance{
+			Addr:                  votes[0].Addr,
+			PrimaryAccountBalance: 1,
+			BlockHeight:           *proposals[0].Block_height,
+			Source:                &source,
+		}
+		assert.Nil(t, tokenBalance.CreateBalance(otu.A.DB))
+
+		stored, err := models.GetAllVotesForProposal(otu.A.DB, proposalId, "composite-weighted")
+		assert.Nil(t, err)
+		assert.Equal(t, len(votes), len(stored))
+		for _, v := range stored {
+			assert.NotEqual(t, uint64(1), *v.PrimaryAccountBalance)
+		}
+	})
+
+	t.Run("Should only share balances between proposals with the same source", func(t *testing.T) {
+		flow := shared.Contract{Name: &[]string{"FlowToken"}[0]}
+		composite := models.BalanceSource("composite-weighted", flow)
+
+		assert.NotEqual(t, composite, models.BalanceSource("token-weighted-default", flow))
+		threshold := 5.0
+		flow.Threshold = &threshold
+		assert.Equal(t, composite, models.BalanceSource("composite-weighted", flow))
+	})
+}
+
+/* Token Decimals */
+func TestTokenDecimals(t *testing.T) {
+	decimals := 6
+	proposal := utils.DefaultProposalStruct
+	proposal.Decimals = &decimals
+	votes := otu.GenerateListOfVotes(1, 3)
+	s := strategyMap["token-weighted-default"]
+
+	t.Run("Should convert balances using the contract's decimals", func(t *testing.T) {
+		for _, vote := range votes {
+			weight, err := s.GetVoteWeightForBalance(vote, &proposal)
+			assert.Nil(t, err)
+			assert.Equal(t, float64(*vote.PrimaryAccountBalance)/1000000, weight)
+		}
+	})
+
+	t.Run("Should cap tallied balances at the max weight", func(t *testing.T) {
+		maxWeight := 150.0
+		capped := proposal
+		capped.Max_weight = &maxWeight
+
+		// balances are 100, 200 and 300 tokens at 6 decimals
+		for _, vote := range votes {
+			vote.Choice = "a"
+		}
+
+		results := models.NewProposalResults(1, capped.Choices)
+		_results, err := s.TallyVotes(votes, results, &capped)
+		assert.Nil(t, err)
+		assert.Equal(t, 100.0+150.0+150.0, _results.Results_float["a"])
+	})
+
+	t.Run("Should rescale FLOW balances stored with 7 decimals", func(t *testing.T) {
+		clearTable("balances")
+
+		// stored before per-contract decimals: 1 unlocked, 2 locked and 3 staked FLOW
+		legacy := models.Balance{
+			Addr:                    "0x0000000000000001",
+			PrimaryAccountBalance:   100000000,
+			SecondaryAccountBalance: 20000000,
+			StakingBalance:          30000000,
+			BlockHeight:             1,
+		}
+		assert.Nil(t, legacy.CreateBalance(otu.A.DB))
+
+		current := models.Balance{
+			Addr:                    "0x0000000000000002",
+			PrimaryAccountBalance:   100000000,
+			SecondaryAccountBalance: 200000000,
+			StakingBalance:          300000000,
+			BlockHeight:             1,
+			FlowBalance:             &shared.FlowBalance{Unlocked: 100000000, Locked: 200000000, NodeStaked: 300000000},
+		}
+		assert.Nil(t, current.CreateBalance(otu.A.DB))
+
+		migration, err := os.ReadFile("./migrations/000060_rescale_legacy_flow_balances.up.sql")
+		assert.Nil(t, err)
+		_, err = otu.A.DB.Conn.Exec(otu.A.DB.Context, string(migration))
+		assert.Nil(t, err)
+
+		total := strategyMap["total-token-weighted-default"]
+		flowProposal := utils.DefaultProposalStruct
+		flowProposal.Max_weight = nil
+		for _, addr := range []string{legacy.Addr, current.Addr} {
+			b := models.Balance{Addr: addr, BlockHeight: 1}
+			assert.Nil(t, b.GetBalanceByAddressAndBlockHeight(otu.A.DB))
+
+			vote := &models.VoteWithBalance{
+				PrimaryAccountBalance:   &b.PrimaryAccountBalance,
+				SecondaryAccountBalance: &b.SecondaryAccountBalance,
+				StakingBalance:          &b.StakingBalance,
+			}
+			weight, err := total.GetVoteWeightForBalance(vote, &flowProposal)
+			assert.Nil(t, err)
+			assert.Equal(t, 6.0, weight, addr)
+		}
+	})
+
+	t.Run("Should default to 8 decimals", func(t *testing.T) {
+		assert.Equal(t, shared.DefaultDecimals, utils.DefaultProposalStruct.TokenDecimals())
+		assert.Equal(t, uint64(150000000), shared.ToFixedPoint(1.5, shared.DefaultDecimals))
+		assert.Equal(t, 1.5, shared.FromFixedPoint(150000000, shared.DefaultDecimals))
+	})
+}
+
+func TestFlowBalanceBuckets(t *testing.T) {
+	flow := func(amount float64) uint64 {
+		return shared.ToFixedPoint(amount, shared.DefaultDecimals)
+	}
+	flowBalance := &shared.FlowBalance{
+		Unlocked:   flow(10),
+		Locked:     flow(20),
+		NodeStaked: flow(30),
+		Delegated:  flow(40),
+		Rewards:    flow(5),
+		Unstaking:  flow(1),
+	}
+
+	t.Run("Should sum the selected buckets", func(t *testing.T) {
+		assert.Equal(t, flow(76), flowBalance.Sum(shared.StakedBuckets))
+		assert.Equal(t, flow(106), flowBalance.Sum(shared.FlowBuckets))
+		assert.Equal(t, flow(70), flowBalance.Sum([]string{shared.BucketNodeStaked, shared.BucketDelegated}))
+	})
+
+	t.Run("Should parse script amounts without rounding", func(t *testing.T) {
+		balance, err := shared.ParseFixedPoint("12345678.12345678", shared.DefaultDecimals)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1234567812345678), balance)
+
+		balance, err = shared.ParseFixedPoint("0.1", shared.DefaultDecimals)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(10000000), balance)
+
+		balance, err = shared.ParseFixedPoint("1.23456789", 6)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1234567), balance)
+
+		_, err = shared.ParseFixedPoint("lots", shared.DefaultDecimals)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should store breakdowns fetched in token amounts as fixed point", func(t *testing.T) {
+		clearTable("balances")
+
+		addr := "0x0000000000000003"
+		_, err := otu.A.DB.Conn.Exec(otu.A.DB.Context, `
+			INSERT INTO balances(id, addr, primary_account_balance, secondary_address, secondary_account_balance,
+				staking_balance, script_result, stakes, block_height, flow_balance)
+			VALUES($1, $2, 1000000000, '0x0', 2000000000, 7600000000, 'SUCCESS', '{}', 1, $3)
+		`, uuid.New(), addr, `{"unlocked": 10, "locked": 20, "nodeStaked": 30, "delegated": 40, "rewards": 5, "unstaking": 1.00000001}`)
+		assert.Nil(t, err)
+
+		migration, err := os.ReadFile("./migrations/000063_store_flow_balance_fixed_point.up.sql")
+		assert.Nil(t, err)
+		_, err = otu.A.DB.Conn.Exec(otu.A.DB.Context, string(migration))
+		assert.Nil(t, err)
+
+		b := models.Balance{Addr: addr, BlockHeight: 1}
+		assert.Nil(t, b.GetBalanceByAddressAndBlockHeight(otu.A.DB))
+		assert.Equal(t, flow(10), b.FlowBalance.Unlocked)
+		assert.Equal(t, uint64(100000001), b.FlowBalance.Unstaking)
+		assert.Equal(t, b.StakingBalance+1, b.FlowBalance.Sum(shared.StakedBuckets))
+	})
+
+	t.Run("Should weigh staked votes by the proposal's buckets", func(t *testing.T) {
+		proposal := utils.DefaultProposalStruct
+		buckets := []string{shared.BucketNodeStaked, shared.BucketDelegated}
+		proposal.Balance_buckets = &buckets
+
+		staking := flowBalance.Sum(shared.StakedBuckets)
+		vote := &models.VoteWithBalance{
+			StakingBalance: &staking,
+			FlowBalance:    flowBalance,
+		}
+
+		s := strategyMap["staked-token-weighted-default"]
+		weight, err := s.GetVoteWeightForBalance(vote, &proposal)
+		assert.Nil(t, err)
+		assert.Equal(t, 70.0, weight)
+
+		// without a bucket selection the full staking balance counts
+		weight, err = s.GetVoteWeightForBalance(vote, &utils.DefaultProposalStruct)
+		assert.Nil(t, err)
+		assert.Equal(t, 76.0, weight)
+	})
+
+	t.Run("Should tally non-stakers when the proposal counts unstaked buckets", func(t *testing.T) {
+		proposal := utils.DefaultProposalStruct
+		proposal.Max_weight = nil
+		buckets := []string{shared.BucketUnlocked, shared.BucketLocked}
+		proposal.Balance_buckets = &buckets
+
+		var zero uint64 = 0
+		unstaked := &shared.FlowBalance{Unlocked: flow(10), Locked: flow(20)}
+		primary := unstaked.Unlocked
+		secondary := unstaked.Locked
+		vote := &models.VoteWithBalance{
+			Vote:                    models.Vote{Choice: "a"},
+			PrimaryAccountBalance:   &primary,
+			SecondaryAccountBalance: &secondary,
+			StakingBalance:          &zero,
+			FlowBalance:             unstaked,
+		}
+
+		for _, name := range []string{"staked-token-weighted-default", "total-token-weighted-default"} {
+			s := strategyMap[name]
+			weight, err := s.GetVoteWeightForBalance(vote, &proposal)
+			assert.Nil(t, err)
+			assert.Equal(t, 30.0, weight, name)
+
+			results := models.NewProposalResults(1, proposal.Choices)
+			_results, err := s.TallyVotes([]*models.VoteWithBalance{vote}, results, &proposal)
+			assert.Nil(t, err)
+			assert.InDelta(t, 30.0, _results.Results_float["a"], 0.000001, name)
+		}
+	})
+}
+
 /* One Token One Vote */
 // func TestOneTokenOneVoteStrategy(t *testing.T) {
 // 	clearTable("communities")
@@ -385,3 +882,148 @@ func TestStakedTokenWeightedDefaultStrategy(t *testing.T) {
 // 		}
 // 	})
 // }
+
+/* Ranked Choice */
+func TestRankedChoiceTally(t *testing.T) {
+	rankedChoice := models.RankedChoice
+	proposal := otu.GenerateProposalStruct("account", 1)
+	proposal.Voting_type = &rankedChoice
+	proposal.Max_weight = nil
+	proposal.Choices = []shared.Choice{
+		{Choice_text: "a"},
+		{Choice_text: "b"},
+		{Choice_text: "c"},
+	}
+
+	rankings := [][]string{
+		{"a", "b", "c"},
+		{"a", "b", "c"},
+		{"a", "b", "c"},
+		{"a", "b", "c"},
+		{"b", "c", "a"},
+		{"b", "c", "a"},
+		{"b", "c", "a"},
+		{"c", "b", "a"},
+		{"c", "b", "a"},
+	}
+
+	t.Run("Test one address one vote runoff eliminates the last place choice", func(t *testing.T) {
+		votes := otu.GenerateListOfMultipleChoiceVotes(1, rankings, []int{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+		s := strategyMap["one-address-one-vote"]
+		votes, err := s.GetVotes(votes, proposal)
+		if err != nil {
+			t.Errorf("Error getting vote weights: %v", err)
+		}
+
+		results := models.NewProposalResults(1, proposal.Choices)
+		results.TallyRankedChoice(votes, proposal)
+
+		assert.Equal(t, 2, len(results.Rounds))
+		assert.Equal(t, 4.0, results.Rounds[0].Results["a"])
+		assert.Equal(t, 3.0, results.Rounds[0].Results["b"])
+		assert.Equal(t, 2.0, results.Rounds[0].Results["c"])
+		assert.Equal(t, []string{"c"}, results.Rounds[0].Eliminated)
+		assert.Equal(t, 4, results.Results["a"])
+		assert.Equal(t, 5, results.Results["b"])
+		assert.Equal(t, 0, results.Results["c"])
+	})
+
+	t.Run("Test token weighted runoff uses strategy vote weights", func(t *testing.T) {
+		// a single large holder ranking "a" first holds a majority
+		balances := []int{20, 1, 1, 1, 1, 1, 1, 1, 1}
+		votes := otu.GenerateListOfMultipleChoiceVotes(1, rankings, balances)
+
+		s := strategyMap["token-weighted-default"]
+		votes, err := s.GetVotes(votes, proposal)
+		if err != nil {
+			t.Errorf("Error getting vote weights: %v", err)
+		}
+
+		results := models.NewProposalResults(1, proposal.Choices)
+		results.TallyRankedChoice(votes, proposal)
+
+		assert.Equal(t, 1, len(results.Rounds))
+		assert.Equal(t, 23.0, results.Results_float["a"])
+		assert.Equal(t, 3.0, results.Results_float["b"])
+		assert.Equal(t, 2.0, results.Results_float["c"])
+	})
+}
+
+/* Approval */
+func TestApprovalTally(t *testing.T) {
+	approval := models.Approval
+	proposal := otu.GenerateProposalStruct("account", 1)
+	proposal.Voting_type = &approval
+	proposal.Max_weight = nil
+	proposal.Choices = []shared.Choice{
+		{Choice_text: "a"},
+		{Choice_text: "b"},
+		{Choice_text: "c"},
+	}
+
+	approvals := [][]string{
+		{"a", "b"},
+		{"a"},
+		{"b", "c"},
+		{"a", "b", "c"},
+	}
+
+	t.Run("Test full weight is given to each approved choice", func(t *testing.T) {
+		votes := otu.GenerateListOfMultipleChoiceVotes(1, approvals, []int{1, 2, 3, 4})
+
+		s := strategyMap["token-weighted-default"]
+		votes, err := s.GetVotes(votes, proposal)
+		if err != nil {
+			t.Errorf("Error getting vote weights: %v", err)
+		}
+
+		results := models.NewProposalResults(1, proposal.Choices)
+		results.TallyApproval(votes, proposal)
+
+		assert.Equal(t, 3, results.Approvals["a"])
+		assert.Equal(t, 3, results.Approvals["b"])
+		assert.Equal(t, 2, results.Approvals["c"])
+		assert.Equal(t, 7.0, results.Results_float["a"])
+		assert.Equal(t, 8.0, results.Results_float["b"])
+		assert.Equal(t, 7.0, results.Results_float["c"])
+	})
+}
+
+/* Split */
+func TestSplitTally(t *testing.T) {
+	split := models.Split
+	proposal := otu.GenerateProposalStruct("account", 1)
+	proposal.Voting_type = &split
+	proposal.Max_weight = nil
+
+	allocations := []map[string]int{
+		{"a": 70, "b": 30},
+		{"b": 100},
+	}
+	votes := otu.GenerateListOfSplitVotes(1, allocations, []int{10, 5})
+
+	t.Run("Test token weighted tally divides balance by allocation", func(t *testing.T) {
+		s := strategyMap["token-weighted-default"]
+		results := models.NewProposalResults(1, proposal.Choices)
+		_results, err := s.TallyVotes(votes, results, proposal)
+		if err != nil {
+			t.Errorf("Error tallying votes: %v", err)
+		}
+
+		assert.InDelta(t, 7.0, _results.Results_float["a"], 0.000001)
+		assert.InDelta(t, 8.0, _results.Results_float["b"], 0.000001)
+	})
+
+	t.Run("Test one address one vote tally divides the vote by allocation", func(t *testing.T) {
+		s := strategyMap["one-address-one-vote"]
+		results := models.NewProposalResults(1, proposal.Choices)
+		_results, err := s.TallyVotes(votes, results, proposal)
+		if err != nil {
+			t.Errorf("Error tallying votes: %v", err)
+		}
+
+		assert.InDelta(t, 0.7, _results.Results_float["a"], 0.000001)
+		assert.InDelta(t, 1.3, _results.Results_float["b"], 0.000001)
+	})
+}
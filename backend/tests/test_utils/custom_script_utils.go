@@ -0,0 +1,79 @@
+package test_utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+)
+
+//////////////////
+// Custom Scripts
+//////////////////
+
+var DefaultCustomScriptSrc = `import NonFungibleToken from "NON_FUNGIBLE_TOKEN_ADDRESS"
+
+pub fun main(address: Address): [UInt64] {
+    let collectionRef = getAccount(address)
+        .getCapability(/public/"COLLECTION_PUBLIC_PATH")
+        .borrow<&{NonFungibleToken.CollectionPublic}>()
+        ?? panic("Could not borrow capability from public collection")
+
+    return collectionRef.getIDs()
+}`
+
+var DefaultCustomScriptStruct = models.CustomScript{
+	Key:  "example-nft-holders",
+	Name: "ExampleNFT Holders",
+	Src:  DefaultCustomScriptSrc,
+}
+
+var DefaultCustomScriptContract = shared.Contract{
+	Name:        &exampleNFTName,
+	Addr:        &exampleNFTAddr,
+	Public_path: &exampleNFTPublicPath,
+}
+
+func (otu *OverflowTestUtils) GenerateCustomScriptPayload(
+	signer string,
+	communityId int,
+	script models.CustomScript,
+) *models.CustomScriptPayload {
+	var timestamp = fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+	compositeSigs := otu.GenerateCompositeSignatures(signer, timestamp)
+	contract := DefaultCustomScriptContract
+
+	payload := models.CustomScriptPayload{
+		CustomScript: script,
+		Contract:     &contract,
+	}
+	payload.Community_id = communityId
+	payload.Composite_signatures = compositeSigs
+	payload.Timestamp = timestamp
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", signer))
+	payload.Signing_addr = fmt.Sprintf("0x%s", account.Address().String())
+
+	return &payload
+}
+
+func (otu *OverflowTestUtils) CreateCustomScriptAPI(payload *models.CustomScriptPayload, dryRun bool) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	url := "/communities/" + strconv.Itoa(payload.Community_id) + "/scripts"
+	if dryRun {
+		url = url + "?dryRun=true"
+	}
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) GetCustomScriptsForCommunityAPI(communityId int) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/communities/"+strconv.Itoa(communityId)+"/scripts", nil)
+	return otu.ExecuteRequest(req)
+}
@@ -0,0 +1,62 @@
+package test_utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+)
+
+func (otu *OverflowTestUtils) GenerateDelegationPayload(delegator string, delegate string) *models.DelegationPayload {
+	timestamp := fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+	compositeSignatures := otu.GenerateCompositeSignatures(delegator, timestamp)
+
+	payload := models.DelegationPayload{
+		Delegation: models.Delegation{
+			Delegator_addr:       otu.accountAddress(delegator),
+			Delegate_addr:        otu.accountAddress(delegate),
+			Composite_signatures: compositeSignatures,
+		},
+		Timestamp: timestamp,
+	}
+
+	return &payload
+}
+
+func (otu *OverflowTestUtils) CreateDelegationAPI(communityId int, payload *models.DelegationPayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/communities/"+strconv.Itoa(communityId)+"/delegations", bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) RevokeDelegationAPI(communityId int, payload *models.DelegationPayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(
+		"DELETE",
+		"/communities/"+strconv.Itoa(communityId)+"/delegations/"+payload.Delegator_addr,
+		bytes.NewBuffer(json),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) GetDelegationsForCommunityAPI(communityId int) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/communities/"+strconv.Itoa(communityId)+"/delegations", nil)
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) GetDelegationForAddressAPI(communityId int, addr string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/communities/"+strconv.Itoa(communityId)+"/delegations/"+addr, nil)
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) accountAddress(accountName string) string {
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", accountName))
+	return fmt.Sprintf("0x%s", account.Address().String())
+}
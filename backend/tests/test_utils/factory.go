@@ -35,9 +35,9 @@ func (otu *OverflowTestUtils) AddDummyVotesAndBalances(votes []*models.VoteWithB
 
 		//Insert Balance
 		_, err = otu.A.DB.Conn.Exec(otu.A.DB.Context, `
-			INSERT INTO balances(id, addr, primary_account_balance, secondary_address, secondary_account_balance, staking_balance, script_result, stakes, block_height)
-			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		`, uuid.New(), vote.Addr, vote.PrimaryAccountBalance, "0x0", 0, vote.StakingBalance, "SUCCESS", []string{}, 9)
+			INSERT INTO balances(id, addr, primary_account_balance, secondary_address, secondary_account_balance, staking_balance, script_result, stakes, block_height, components)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, uuid.New(), vote.Addr, vote.PrimaryAccountBalance, "0x0", 0, vote.StakingBalance, "SUCCESS", []string{}, 9, vote.Components)
 		if err != nil {
 			log.Error().Err(err).Msg("AddDummyVotesAndBalances database error - balances.")
 			return err
@@ -246,6 +246,24 @@ func (otu *OverflowTestUtils) AddActiveProposals(cId int, count int) []int {
 	return retIds
 }
 
+func (otu *OverflowTestUtils) AddActiveProposalsWithVotingType(cId int, votingType string, count int) []int {
+	if count < 1 {
+		count = 1
+	}
+	retIds := []int{}
+	for i := 0; i < count; i++ {
+		proposal := otu.GenerateProposalStruct("account", cId)
+		proposal.Start_time = time.Now().UTC().AddDate(0, -1, 0)
+		proposal.Voting_type = &votingType
+		if err := proposal.CreateProposal(otu.A.DB); err != nil {
+			fmt.Printf("Error in otu.AddActiveProposalsWithVotingType.")
+		}
+
+		retIds = append(retIds, proposal.ID)
+	}
+	return retIds
+}
+
 func (otu *OverflowTestUtils) AddActiveProposalsWithStartTimeNow(cId int, count int) []int {
 	if count < 1 {
 		count = 1
@@ -0,0 +1,46 @@
+package test_utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+)
+
+//////////////
+// Nonces
+//////////////
+
+func (otu *OverflowTestUtils) CreateNonceAPI(action, resource string) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(models.Nonce{Action: action, Resource: resource})
+	req, _ := http.NewRequest("POST", "/nonces", bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) CreateNonce(action, resource string) string {
+	response := otu.CreateNonceAPI(action, resource)
+	var nonce models.Nonce
+	json.Unmarshal(response.Body.Bytes(), &nonce)
+	return nonce.Nonce
+}
+
+// Signs the action on the resource with a fresh nonce.
+func (otu *OverflowTestUtils) SignAction(signer, action, resource string) shared.TimestampSignaturePayload {
+	nonce := otu.CreateNonce(action, resource)
+	timestamp := fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+	message := shared.ActionMessage(action, resource, nonce, timestamp)
+
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", signer))
+	return shared.TimestampSignaturePayload{
+		Composite_signatures: otu.GenerateCompositeSignatures(signer, message),
+		Signing_addr:         fmt.Sprintf("0x%s", account.Address().String()),
+		Timestamp:            timestamp,
+		Nonce:                nonce,
+	}
+}
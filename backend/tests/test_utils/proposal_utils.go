@@ -21,6 +21,7 @@ var (
 	proposalBody                = "<html>something</html>"
 	published                   = "published"
 	tokenWeightedDefault        = "token-weighted-default"
+	singleChoice                = models.SingleChoice
 	blockHeight          uint64 = 9
 
 	DefaultProposalStruct = models.Proposal{
@@ -34,6 +35,7 @@ var (
 		Strategy:     &tokenWeightedDefault,
 		Status:       &published,
 		Block_height: &blockHeight,
+		Voting_type:  &singleChoice,
 	}
 )
 
@@ -0,0 +1,65 @@
+package test_utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+)
+
+//////////////
+// Roles
+//////////////
+
+func (otu *OverflowTestUtils) GenerateCommunityRolePayload(signer string, role *models.CommunityRole) *models.CommunityRolePayload {
+	var timestamp = fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+	compositeSigs := otu.GenerateCompositeSignatures(signer, timestamp)
+
+	payload := models.CommunityRolePayload{
+		CommunityRole: *role,
+	}
+	payload.Composite_signatures = compositeSigs
+	payload.Timestamp = timestamp
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", signer))
+	payload.Signing_addr = fmt.Sprintf("0x%s", account.Address().String())
+
+	return &payload
+}
+
+func (otu *OverflowTestUtils) GetCommunityRolesAPI(communityId int) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/communities/"+strconv.Itoa(communityId)+"/roles", nil)
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) CreateCommunityRoleAPI(communityId int, payload *models.CommunityRolePayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/communities/"+strconv.Itoa(communityId)+"/roles", bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) UpdateCommunityRoleAPI(communityId int, name string, payload *models.CommunityRolePayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("PUT", "/communities/"+strconv.Itoa(communityId)+"/roles/"+name, bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) DeleteCommunityRoleAPI(communityId int, name string, payload *models.CommunityRolePayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("DELETE", "/communities/"+strconv.Itoa(communityId)+"/roles/"+name, bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+// Has the admin signer grant the role to the account.
+func (otu *OverflowTestUtils) GrantRole(admin, account string, communityId int, role string) *httptest.ResponseRecorder {
+	user := otu.GenerateCommunityUserStruct(account, role)
+	user.Community_id = communityId
+	return otu.CreateCommunityUserAPI(communityId, otu.GenerateCommunityUserPayload(admin, user))
+}
@@ -0,0 +1,68 @@
+package test_utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+)
+
+//////////////
+// Sessions
+//////////////
+
+func (otu *OverflowTestUtils) GenerateSignInPayload(signer string) *models.SignInPayload {
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", signer))
+	addr := fmt.Sprintf("0x%s", account.Address().String())
+	nonce := otu.CreateNonce(models.ActionSignIn, addr)
+	timestamp := fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+
+	message := shared.SignInMessage(addr, nonce, timestamp)
+	return &models.SignInPayload{
+		Addr:                 addr,
+		Nonce:                nonce,
+		Timestamp:            timestamp,
+		Composite_signatures: otu.GenerateCompositeSignatures(signer, message),
+	}
+}
+
+func (otu *OverflowTestUtils) SignInAPI(payload *models.SignInPayload) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/auth/sign-in", bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) RefreshSessionAPI(refreshToken string) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(models.RefreshSessionPayload{Refresh_token: refreshToken})
+	req, _ := http.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) RevokeSessionAPI(token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/auth/revoke", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return otu.ExecuteRequest(req)
+}
+
+// Signs in and returns the session.
+func (otu *OverflowTestUtils) SignIn(signer string) models.SessionResponse {
+	response := otu.SignInAPI(otu.GenerateSignInPayload(signer))
+	var session models.SessionResponse
+	json.Unmarshal(response.Body.Bytes(), &session)
+	return session
+}
+
+func (otu *OverflowTestUtils) CreateListWithSessionAPI(payload *models.ListPayload, token string) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/communities/%d/lists", payload.Community_id), bytes.NewBuffer(json))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return otu.ExecuteRequest(req)
+}
@@ -42,6 +42,60 @@ func (otu *OverflowTestUtils) GenerateListOfVotes(proposalId int, count int) []*
 	return votes
 }
 
+func (otu *OverflowTestUtils) GenerateListOfMultipleChoiceVotes(
+	proposalId int,
+	ballots [][]string,
+	balances []int,
+) []*models.VoteWithBalance {
+	votes := make([]*models.VoteWithBalance, len(ballots))
+	for i, ballot := range ballots {
+		addr := "0x" + strconv.Itoa(i+1)
+		v := models.Vote{
+			Proposal_id: proposalId, Addr: addr, Choice: ballot[0], Choices: ballot,
+		}
+
+		// Balance is 1 FLOW * balances[i]
+		dummyBal := createDummyBalance(100000000 * balances[i])
+
+		vote := &models.VoteWithBalance{
+			Vote:                  v,
+			PrimaryAccountBalance: &dummyBal.Primary,
+			StakingBalance:        &dummyBal.Staking,
+			BlockHeight:           &dummyBal.BlockHeight,
+		}
+		votes[i] = vote
+	}
+
+	return votes
+}
+
+func (otu *OverflowTestUtils) GenerateListOfSplitVotes(
+	proposalId int,
+	allocations []map[string]int,
+	balances []int,
+) []*models.VoteWithBalance {
+	votes := make([]*models.VoteWithBalance, len(allocations))
+	for i, allocation := range allocations {
+		addr := "0x" + strconv.Itoa(i+1)
+		v := models.Vote{
+			Proposal_id: proposalId, Addr: addr, Allocations: allocation,
+		}
+
+		// Balance is 1 FLOW * balances[i]
+		dummyBal := createDummyBalance(100000000 * balances[i])
+
+		vote := &models.VoteWithBalance{
+			Vote:                  v,
+			PrimaryAccountBalance: &dummyBal.Primary,
+			StakingBalance:        &dummyBal.Staking,
+			BlockHeight:           &dummyBal.BlockHeight,
+		}
+		votes[i] = vote
+	}
+
+	return votes
+}
+
 func (otu *OverflowTestUtils) GenerateCheatVote(proposalId int, count int) *[]models.VoteWithBalance {
 	votes := make([]models.VoteWithBalance, count)
 	choices := []string{"a", "b"}
@@ -107,11 +161,12 @@ func (otu *OverflowTestUtils) GenerateListOfVotesWithNFTs(
 			Proposal_id: proposalId, Addr: addr, Choice: choice,
 		}
 
-		scriptPath := "./main/cadence/scripts/get_nfts_ids.cdc"
+		scriptPath := shared.ScriptGetNFTIds
 		nftIds, err := otu.Adapter.GetNFTIds(
 			addr,
 			contract,
 			scriptPath,
+			0,
 		)
 		if err != nil {
 			return nil, err
@@ -157,11 +212,12 @@ func (otu *OverflowTestUtils) GenerateSingleVoteWithNFT(
 		Proposal_id: proposalId, Addr: addr, Choice: choice,
 	}
 
-	scriptPath := "./main/cadence/scripts/get_nfts_ids.cdc"
+	scriptPath := shared.ScriptGetNFTIds
 	nftIds, err := otu.Adapter.GetNFTIds(
 		addr,
 		contract,
 		scriptPath,
+		0,
 	)
 	if err != nil {
 		return nil, err
@@ -14,7 +14,7 @@ type OverflowTestUtils struct {
 	O       *overflow.OverflowState
 	T       *testing.T
 	A       *server.App
-	Adapter *shared.FlowAdapter
+	Adapter shared.ChainReader
 }
 
 func (otu *OverflowTestUtils) SetTest(t *testing.T) *OverflowTestUtils {
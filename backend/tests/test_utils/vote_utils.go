@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
@@ -63,6 +64,22 @@ func (otu *OverflowTestUtils) CreateVoteAPI(proposalId int, payload *models.Vote
 	return otu.ExecuteRequest(req)
 }
 
+func (otu *OverflowTestUtils) UpdateVoteAPI(proposalId int, payload *models.Vote) *httptest.ResponseRecorder {
+	json, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(
+		"PUT",
+		"/proposals/"+strconv.Itoa(proposalId)+"/votes/"+payload.Addr,
+		bytes.NewBuffer(json),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	return otu.ExecuteRequest(req)
+}
+
+func (otu *OverflowTestUtils) GetVoteHistoryAPI(proposalId int, addr string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/proposals/"+strconv.Itoa(proposalId)+"/votes/"+addr+"/history", nil)
+	return otu.ExecuteRequest(req)
+}
+
 func (otu *OverflowTestUtils) GenerateValidVotePayload(accountName string, proposalId int, choice string) *models.Vote {
 	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
 	hexChoice := hex.EncodeToString([]byte(choice))
@@ -76,3 +93,37 @@ func (otu *OverflowTestUtils) GenerateValidVotePayload(accountName string, propo
 
 	return &vote
 }
+
+func (otu *OverflowTestUtils) GenerateValidMultipleChoiceVotePayload(accountName string, proposalId int, choices []string) *models.Vote {
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	var hexChoices []string
+	for _, choice := range choices {
+		hexChoices = append(hexChoices, hex.EncodeToString([]byte(choice)))
+	}
+	message := strconv.Itoa(proposalId) + ":" + strings.Join(hexChoices, ",") + ":" + fmt.Sprint(timestamp)
+	compositeSignatures := otu.GenerateCompositeSignatures(accountName, message)
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", accountName))
+	address := fmt.Sprintf("0x%s", account.Address().String())
+
+	vote := models.Vote{Proposal_id: proposalId, Addr: address, Choice: choices[0], Choices: choices,
+		Composite_signatures: compositeSignatures, Message: message}
+
+	return &vote
+}
+
+func (otu *OverflowTestUtils) GenerateValidSplitVotePayload(accountName string, proposalId int, allocations map[string]int) *models.Vote {
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	var hexAllocations []string
+	for choice, percentage := range allocations {
+		hexAllocations = append(hexAllocations, hex.EncodeToString([]byte(choice))+"="+strconv.Itoa(percentage))
+	}
+	message := strconv.Itoa(proposalId) + ":" + strings.Join(hexAllocations, ",") + ":" + fmt.Sprint(timestamp)
+	compositeSignatures := otu.GenerateCompositeSignatures(accountName, message)
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", accountName))
+	address := fmt.Sprintf("0x%s", account.Address().String())
+
+	vote := models.Vote{Proposal_id: proposalId, Addr: address, Allocations: allocations,
+		Composite_signatures: compositeSignatures, Message: message}
+
+	return &vote
+}
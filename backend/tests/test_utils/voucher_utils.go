@@ -0,0 +1,58 @@
+package test_utils
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DapperCollectives/CAST/backend/main/models"
+	"github.com/DapperCollectives/CAST/backend/main/shared"
+	"github.com/onflow/flow-go-sdk"
+)
+
+//////////////
+// Vouchers
+//////////////
+
+// Builds a voucher for a transaction the account signs as its own payer,
+// the way non-custodial wallets sign the envelope.
+func (otu *OverflowTestUtils) GenerateVoucher(accountName string, arguments ...string) *shared.Voucher {
+	account, _ := otu.O.State.Accounts().ByName(fmt.Sprintf("emulator-%s", accountName))
+	address := fmt.Sprintf("0x%s", account.Address().String())
+
+	args := make([]map[string]string, len(arguments))
+	for i, arg := range arguments {
+		args[i] = map[string]string{"type": "String", "value": arg}
+	}
+
+	voucher := shared.Voucher{
+		Cadence:      "transaction { prepare(signer: AuthAccount) {} }",
+		ComputeLimit: 9999,
+		Arguments:    args,
+		Payer:        address,
+		Authorizers:  []string{address},
+		ProposalKey:  shared.ProposalKey{Address: address},
+	}
+
+	message, _ := hex.DecodeString(shared.EncodeMessageFromVoucher(&voucher))
+	signer, _ := account.Key().Signer(context.Background())
+	signature, _ := signer.Sign(append(flow.TransactionDomainTag[:], message...))
+	voucher.EnvelopeSigs = []shared.PayloadSig{{Address: address, KeyId: 0, Sig: hex.EncodeToString(signature)}}
+
+	return &voucher
+}
+
+// A vote cast through a voucher, its message is the first transaction argument.
+func (otu *OverflowTestUtils) GenerateVoucherVotePayload(accountName string, proposalId int, choice string) *models.Vote {
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	hexChoice := hex.EncodeToString([]byte(choice))
+	message := strconv.Itoa(proposalId) + ":" + hexChoice + ":" + fmt.Sprint(timestamp)
+	voucher := otu.GenerateVoucher(accountName, hex.EncodeToString([]byte(message)))
+
+	vote := models.Vote{Proposal_id: proposalId, Addr: voucher.Authorizers[0], Choice: choice,
+		Composite_signatures: shared.GetUserCompositeSignatureFromVoucher(voucher), Voucher: voucher}
+
+	return &vote
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/DapperCollectives/CAST/backend/main/models"
 	"github.com/DapperCollectives/CAST/backend/main/shared"
@@ -91,6 +93,8 @@ func TestGetVotes(t *testing.T) {
 		}
 
 		defaultResults := models.NewProposalResults(proposalId, p.Choices)
+		// without a quorum the proposal meets quorum, but has no winner
+		defaultResults.Quorum_met = true
 		response := otu.GetProposalResultsAPI(proposalId)
 		CheckResponseCode(t, http.StatusOK, response.Code)
 
@@ -128,4 +132,306 @@ func TestCreateVote(t *testing.T) {
 		assert.Equal(t, proposalId, createdVote.Proposal_id)
 		assert.Equal(t, 1, createdVote.ID)
 	})
+
+	t.Run("should reject a message with a timestamp that isn't a number", func(t *testing.T) {
+		proposalId := otu.AddActiveProposals(otu.AddCommunities(1, "dao")[0], 1)[0]
+		votePayload := otu.GenerateValidVotePayload("user2", proposalId, "a")
+		votePayload.Message = strconv.Itoa(proposalId) + ":" + hex.EncodeToString([]byte("a")) + ":soon"
+		votePayload.Composite_signatures = otu.GenerateCompositeSignatures("user2", votePayload.Message)
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+}
+
+func TestCreateRankedChoiceVote(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposalsWithVotingType(communityId, models.RankedChoice, 1)[0]
+
+	t.Run("should successfully create a ranked choice vote", func(t *testing.T) {
+		ranking := []string{"b", "a"}
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user1", proposalId, ranking)
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		response = otu.GetVoteForProposalByAccountNameAPI(proposalId, "user1")
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var createdVote models.Vote
+		json.Unmarshal(response.Body.Bytes(), &createdVote)
+
+		assert.Equal(t, "b", createdVote.Choice)
+		assert.Equal(t, ranking, createdVote.Choices)
+	})
+
+	t.Run("should reject a ballot that does not rank every choice", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user2", proposalId, []string{"a"})
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should reject a ballot that ranks a choice twice", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user3", proposalId, []string{"a", "a"})
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should reject a ranking that differs from the signed ballot", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user4", proposalId, []string{"b", "a"})
+		votePayload.Choice = "a"
+		votePayload.Choices = []string{"a", "b"}
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+}
+
+func TestCreateApprovalVote(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposalsWithVotingType(communityId, models.Approval, 1)[0]
+
+	t.Run("should successfully create a vote approving multiple choices", func(t *testing.T) {
+		approved := []string{"a", "b"}
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user1", proposalId, approved)
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		response = otu.GetVoteForProposalByAccountNameAPI(proposalId, "user1")
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var createdVote models.Vote
+		json.Unmarshal(response.Body.Bytes(), &createdVote)
+
+		assert.Equal(t, approved, createdVote.Choices)
+	})
+
+	t.Run("should successfully create a vote approving a single choice", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user2", proposalId, []string{"b"})
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+	})
+
+	t.Run("should reject a ballot that approves a choice twice", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user3", proposalId, []string{"a", "a"})
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should reject approved choices that differ from the signed ballot", func(t *testing.T) {
+		votePayload := otu.GenerateValidMultipleChoiceVotePayload("user4", proposalId, []string{"a"})
+		votePayload.Choices = []string{"a", "b"}
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should mark every approver of the winning choice as winning", func(t *testing.T) {
+		proposal := models.Proposal{ID: proposalId}
+		assert.Nil(t, proposal.GetProposalById(otu.A.DB))
+
+		votes, err := models.GetAllVotesForProposal(otu.A.DB, proposalId, *proposal.Strategy)
+		assert.Nil(t, err)
+
+		winningChoice := "b"
+		results := models.ProposalResults{Proposal_id: proposalId, Passed: true, Winning_choice: &winningChoice}
+		assert.Nil(t, models.AddWinningVoteAchievement(otu.A.DB, votes, proposal, results))
+
+		// user1 approved b after a, user2 approved only b
+		for _, name := range []string{"user1", "user2"} {
+			response := otu.GetVoteForProposalByAccountNameAPI(proposalId, name)
+			CheckResponseCode(t, http.StatusOK, response.Code)
+
+			var vote models.Vote
+			json.Unmarshal(response.Body.Bytes(), &vote)
+			assert.True(t, vote.IsWinning)
+		}
+	})
+}
+
+func TestCreateSplitVote(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposalsWithVotingType(communityId, models.Split, 1)[0]
+
+	t.Run("should successfully create a vote split across choices", func(t *testing.T) {
+		allocations := map[string]int{"a": 70, "b": 30}
+		votePayload := otu.GenerateValidSplitVotePayload("user1", proposalId, allocations)
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		response = otu.GetVoteForProposalByAccountNameAPI(proposalId, "user1")
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var createdVote models.Vote
+		json.Unmarshal(response.Body.Bytes(), &createdVote)
+
+		assert.Equal(t, "a", createdVote.Choice)
+		assert.Equal(t, allocations, createdVote.Allocations)
+	})
+
+	t.Run("should reject a split that does not sum to 100", func(t *testing.T) {
+		allocations := map[string]int{"a": 70, "b": 20}
+		votePayload := otu.GenerateValidSplitVotePayload("user2", proposalId, allocations)
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should reject allocations that differ from the signed ballot", func(t *testing.T) {
+		votePayload := otu.GenerateValidSplitVotePayload("user3", proposalId, map[string]int{"a": 100})
+		votePayload.Allocations = map[string]int{"b": 100}
+
+		response := otu.CreateVoteAPI(proposalId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("should reject a choice that differs from the signed ballot", func(t *testing.T) {
+		singleChoiceId := otu.AddActiveProposals(communityId, 1)[0]
+		votePayload := otu.GenerateValidVotePayload("user3", singleChoiceId, "a")
+		votePayload.Choice = "b"
+
+		response := otu.CreateVoteAPI(singleChoiceId, votePayload)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+}
+
+func TestClosedProposalResults(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	clearTable("proposal_results")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+	otu.CreateVoteAPI(proposalId, otu.GenerateValidVotePayload("user1", proposalId, "a"))
+	otu.UpdateProposalEndTime(proposalId, time.Now().UTC())
+
+	var finalResults models.ProposalResults
+
+	t.Run("Should store and pin results when a closed proposal is first requested", func(t *testing.T) {
+		response := otu.GetProposalResultsAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &finalResults)
+
+		assert.NotNil(t, finalResults.Cid)
+		assert.True(t, finalResults.Achievements_done)
+
+		stored := models.ProposalResults{Proposal_id: proposalId}
+		err := stored.GetLatestProposalResultsById(otu.A.DB)
+		assert.Nil(t, err)
+		assert.Equal(t, finalResults.Results, stored.Results)
+		assert.Equal(t, *finalResults.Cid, *stored.Cid)
+	})
+
+	t.Run("Should serve the stored results on later requests", func(t *testing.T) {
+		// votes added after close must not change the final tally
+		otu.AddVotes(proposalId, 2)
+
+		response := otu.GetProposalResultsAPI(proposalId)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var results models.ProposalResults
+		json.Unmarshal(response.Body.Bytes(), &results)
+
+		assert.Equal(t, finalResults.Results, results.Results)
+		assert.Equal(t, *finalResults.Cid, *results.Cid)
+	})
+}
+
+func TestUpdateVote(t *testing.T) {
+	clearTable("communities")
+	clearTable("community_users")
+	clearTable("proposals")
+	clearTable("votes")
+	communityId := otu.AddCommunities(1, "dao")[0]
+	proposalId := otu.AddActiveProposals(communityId, 1)[0]
+
+	firstVote := otu.GenerateValidVotePayload("user1", proposalId, "a")
+	response := otu.CreateVoteAPI(proposalId, firstVote)
+	CheckResponseCode(t, http.StatusCreated, response.Code)
+
+	var created models.Vote
+	json.Unmarshal(response.Body.Bytes(), &created)
+
+	t.Run("Should recast a vote while the proposal is live", func(t *testing.T) {
+		response := otu.UpdateVoteAPI(proposalId, otu.GenerateValidVotePayload("user1", proposalId, "b"))
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var updated models.Vote
+		json.Unmarshal(response.Body.Bytes(), &updated)
+		assert.Equal(t, created.ID, updated.ID)
+		assert.Equal(t, "b", updated.Choice)
+		assert.NotNil(t, updated.Updated_at)
+		assert.Equal(t, created.IsEarly, updated.IsEarly)
+	})
+
+	t.Run("Should only count the latest vote", func(t *testing.T) {
+		votes, err := models.GetAllVotesForProposal(otu.A.DB, proposalId, "token-weighted-default")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(votes))
+		assert.Equal(t, "b", votes[0].Choice)
+	})
+
+	t.Run("Should keep the replaced vote in the history", func(t *testing.T) {
+		response := otu.GetVoteHistoryAPI(proposalId, firstVote.Addr)
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		var history []models.VoteHistory
+		json.Unmarshal(response.Body.Bytes(), &history)
+		assert.Equal(t, 1, len(history))
+		assert.Equal(t, created.ID, history[0].Vote_id)
+		assert.Equal(t, "a", history[0].Choice)
+		assert.Equal(t, *created.Cid, *history[0].Cid)
+	})
+
+	t.Run("Should not replay a ballot that was already cast", func(t *testing.T) {
+		response := otu.UpdateVoteAPI(proposalId, firstVote)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should not replay a voucher ballot under a different message", func(t *testing.T) {
+		firstVoucherVote := otu.GenerateVoucherVotePayload("user3", proposalId, "a")
+		response := otu.CreateVoteAPI(proposalId, firstVoucherVote)
+		CheckResponseCode(t, http.StatusCreated, response.Code)
+
+		response = otu.UpdateVoteAPI(proposalId, otu.GenerateVoucherVotePayload("user3", proposalId, "b"))
+		CheckResponseCode(t, http.StatusOK, response.Code)
+
+		// the message in the body is not what the voucher signed
+		firstVoucherVote.Message = "replayed"
+		response = otu.UpdateVoteAPI(proposalId, firstVoucherVote)
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should not update a vote that was never cast", func(t *testing.T) {
+		response := otu.UpdateVoteAPI(proposalId, otu.GenerateValidVotePayload("user2", proposalId, "a"))
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("Should not update a vote once the proposal has ended", func(t *testing.T) {
+		otu.UpdateProposalEndTime(proposalId, time.Now().UTC())
+
+		response := otu.UpdateVoteAPI(proposalId, otu.GenerateValidVotePayload("user1", proposalId, "a"))
+		CheckResponseCode(t, http.StatusBadRequest, response.Code)
+	})
 }